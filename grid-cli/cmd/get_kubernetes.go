@@ -3,12 +3,16 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	command "github.com/threefoldtech/tfgrid-sdk-go/grid-cli/internal/cmd"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-cli/internal/config"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/deployer"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // getKubernetesCmd represents the get kubernetes command
@@ -17,6 +21,19 @@ var getKubernetesCmd = &cobra.Command{
 	Short: "Get deployed kubernetes",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		kubeconfig, err := cmd.Flags().GetBool("kubeconfig")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		sshKeyFile, err := cmd.Flags().GetString("ssh-key")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		knownHostsFile, err := cmd.Flags().GetString("known-hosts")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
 		cfg, err := config.GetUserConfig()
 		if err != nil {
 			log.Fatal().Err(err).Send()
@@ -31,6 +48,26 @@ var getKubernetesCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal().Err(err).Send()
 		}
+
+		if kubeconfig {
+			privateKey, err := os.ReadFile(sshKeyFile)
+			if err != nil {
+				log.Fatal().Err(err).Send()
+			}
+
+			hostKeyCallback, err := knownhosts.New(knownHostsFile)
+			if err != nil {
+				log.Fatal().Err(err).Send()
+			}
+
+			config, err := cluster.Kubeconfig(cmd.Context(), string(privateKey), hostKeyCallback)
+			if err != nil {
+				log.Fatal().Err(err).Send()
+			}
+			fmt.Print(config)
+			return
+		}
+
 		s, err := json.MarshalIndent(cluster, "", "\t")
 		if err != nil {
 			log.Fatal().Err(err).Send()
@@ -41,4 +78,14 @@ var getKubernetesCmd = &cobra.Command{
 
 func init() {
 	getCmd.AddCommand(getKubernetesCmd)
+
+	var defaultSSHKey, defaultKnownHosts string
+	if home, err := os.UserHomeDir(); err == nil {
+		defaultSSHKey = filepath.Join(home, ".ssh", "id_rsa")
+		defaultKnownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	getKubernetesCmd.Flags().Bool("kubeconfig", false, "print the cluster kubeconfig fetched from the master instead of the cluster")
+	getKubernetesCmd.Flags().String("ssh-key", defaultSSHKey, "path to the private ssh key of the cluster, used with --kubeconfig")
+	getKubernetesCmd.Flags().String("known-hosts", defaultKnownHosts, "path to the known hosts file verifying the master host key, used with --kubeconfig")
 }
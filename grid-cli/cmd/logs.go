@@ -0,0 +1,130 @@
+// Package cmd for parsing command line arguments
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	command "github.com/threefoldtech/tfgrid-sdk-go/grid-cli/internal/cmd"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-cli/internal/config"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/deployer"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Follow the logs of a deployed vm",
+	Long: `Follow the logs of a deployed vm by streaming them to a local collector.
+The vm node connects to the collector through the advertised address, which is usually
+the mycelium or yggdrasil address of this machine.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		vmName, err := cmd.Flags().GetString("vm")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		if vmName == "" {
+			vmName = args[0]
+		}
+		listen, err := cmd.Flags().GetString("listen")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		advertise, err := cmd.Flags().GetString("advertise")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		if output == "" && advertise == "" {
+			log.Fatal().Msg("either --advertise or --output must be set")
+		}
+
+		cfg, err := config.GetUserConfig()
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		t, err := deployer.NewTFPluginClient(cfg.Mnemonics, deployer.WithNetwork(cfg.Network), deployer.WithRMBTimeout(100))
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		dl, err := command.GetVM(cmd.Context(), t, args[0])
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		for _, vm := range dl.Vms {
+			if vm.Name == vmName && vm.ConsoleURL != "" {
+				log.Info().Msgf("vm console: %s", vm.ConsoleURL)
+			}
+		}
+
+		// the logs are streamed to an external output and followed there
+		if output != "" {
+			if err := t.AttachZlog(cmd.Context(), &dl, vmName, output); err != nil {
+				log.Fatal().Err(err).Send()
+			}
+			log.Info().Msgf("vm %s logs are streamed to %s", vmName, output)
+			return
+		}
+
+		if err := followLogs(cmd.Context(), t, &dl, vmName, listen, advertise); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+// followLogs streams the vm logs to a local log collector and prints them until interrupted,
+// the zlog is always detached before returning since the collector is closed with the command
+func followLogs(ctx context.Context, t deployer.TFPluginClient, dl *workloads.Deployment, vmName, listen, advertise string) error {
+	collector, err := deployer.NewLogCollector(listen, advertise)
+	if err != nil {
+		return err
+	}
+	defer collector.Close()
+
+	// interrupting while attaching cancels it instead of killing the command
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := t.AttachZlog(ctx, dl, vmName, collector.Output()); err != nil {
+		return err
+	}
+	log.Info().Msgf("following vm %s logs, press ctrl+c to stop", vmName)
+
+follow:
+	for {
+		select {
+		case line, ok := <-collector.Lines():
+			if !ok {
+				break follow
+			}
+			fmt.Println(line)
+		case <-ctx.Done():
+			break follow
+		}
+	}
+
+	// the zlog is detached even after the command is interrupted
+	return t.DetachZlog(context.Background(), dl, vmName, collector.Output())
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().String("vm", "", "name of the vm in the deployment, defaults to the deployment name")
+	logsCmd.Flags().String("listen", ":8090", "address the local log collector listens on")
+	logsCmd.Flags().String("advertise", "", "address the vm node connects to the log collector on, for example [<mycelium ip>]:8090")
+	logsCmd.Flags().String("output", "", "stream the logs to an external redis, ws or wss url instead of following them")
+	logsCmd.MarkFlagsMutuallyExclusive("advertise", "output")
+}
@@ -0,0 +1,16 @@
+// Package cmd for parsing command line arguments
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report usage of Threefold grid resources",
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+}
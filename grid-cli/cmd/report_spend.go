@@ -0,0 +1,134 @@
+// Package cmd for parsing command line arguments
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-cli/internal/config"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/deployer"
+)
+
+const reportDateFormat = "2006-01-02"
+
+// reportSpendCmd represents the report spend command
+var reportSpendCmd = &cobra.Command{
+	Use:   "spend",
+	Short: "Report twin spend per project from contracts bills",
+	Run: func(cmd *cobra.Command, args []string) {
+		projects, err := cmd.Flags().GetStringSlice("project")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		if output != "table" && output != "csv" && output != "json" {
+			log.Fatal().Msgf("invalid output format %s, must be one of table, csv or json", output)
+		}
+		since, err := parseReportDate(cmd, "since")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		until, err := parseReportDate(cmd, "until")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		if !until.IsZero() {
+			// include the whole until day
+			until = until.AddDate(0, 0, 1)
+		}
+
+		cfg, err := config.GetUserConfig()
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		t, err := deployer.NewTFPluginClient(cfg.Mnemonics, deployer.WithNetwork(cfg.Network), deployer.WithRMBTimeout(100))
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		report, err := t.SpendReport(cmd.Context(), deployer.SpendReportOptions{
+			Projects: projects,
+			Since:    since,
+			Until:    until,
+		})
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		switch output {
+		case "csv":
+			err = report.WriteCSV(cmd.OutOrStdout())
+		case "json":
+			var data []byte
+			data, err = json.MarshalIndent(report, "", "\t")
+			if err == nil {
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			}
+		default:
+			printSpendReport(report, cmd.OutOrStdout())
+		}
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+func init() {
+	reportCmd.AddCommand(reportSpendCmd)
+
+	reportSpendCmd.Flags().StringSlice("project", []string{}, "projects to report, all projects are reported if not set")
+	reportSpendCmd.Flags().String("since", "", "report bills since this date (YYYY-MM-DD)")
+	reportSpendCmd.Flags().String("until", "", "report bills until this date including it (YYYY-MM-DD)")
+	reportSpendCmd.Flags().StringP("output", "o", "table", "output format: table, csv or json")
+}
+
+func parseReportDate(cmd *cobra.Command, flag string) (time.Time, error) {
+	value, err := cmd.Flags().GetString(flag)
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+
+	date, err := time.Parse(reportDateFormat, value)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "invalid %s date %s", flag, value)
+	}
+
+	return date, nil
+}
+
+func printSpendReport(report deployer.SpendReport, writer io.Writer) {
+	fmt.Fprintf(writer, "TFT price: %.4f USD\n", report.TFTPrice)
+
+	for _, project := range report.Projects {
+		fmt.Fprintln(writer)
+		fmt.Fprintf(writer, "Project %s: %.7f TFT (%.2f USD)\n", project.Project, project.TFT, project.USD)
+
+		nodeTable := tabwriter.NewWriter(writer, 0, 0, 4, ' ', 0)
+		fmt.Fprintln(nodeTable, "Node ID\tTFT\tUSD")
+		for _, node := range project.Nodes {
+			fmt.Fprintf(nodeTable, "%d\t%.7f\t%.2f\n", node.NodeID, node.TFT, node.USD)
+		}
+		nodeTable.Flush()
+
+		fmt.Fprintln(writer)
+		dayTable := tabwriter.NewWriter(writer, 0, 0, 4, ' ', 0)
+		fmt.Fprintln(dayTable, "Day\tTFT\tUSD")
+		for _, day := range project.Days {
+			fmt.Fprintf(dayTable, "%s\t%.7f\t%.2f\n", day.Day, day.TFT, day.USD)
+		}
+		dayTable.Flush()
+	}
+
+	fmt.Fprintln(writer)
+	fmt.Fprintf(writer, "Total: %.7f TFT (%.2f USD)\n", report.TFT, report.USD)
+}
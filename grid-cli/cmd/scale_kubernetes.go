@@ -0,0 +1,155 @@
+// Package cmd for parsing command line arguments
+package cmd
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	command "github.com/threefoldtech/tfgrid-sdk-go/grid-cli/internal/cmd"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-cli/internal/config"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/deployer"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+)
+
+// scaleKubernetesCmd represents the scale kubernetes command
+var scaleKubernetesCmd = &cobra.Command{
+	Use:   "scale",
+	Short: "scale a node pool of a kubernetes cluster",
+	Long: `Scale a node pool of a kubernetes cluster to the given number of workers.
+Workers of a pool are named after the pool followed by their index, workers added by
+the add command belong to the worker pool. Node pools are not stored on the grid so the
+pool specs and sizes limits apply to this scaling only.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, err := cmd.Flags().GetString("name")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		poolName, err := cmd.Flags().GetString("pool")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		size, err := cmd.Flags().GetInt("size")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		minSize, err := cmd.Flags().GetInt("min")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		maxSize, err := cmd.Flags().GetInt("max")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		farm, err := cmd.Flags().GetUint64("farm")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		cpu, err := cmd.Flags().GetUint8("cpu")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		memory, err := cmd.Flags().GetUint64("memory")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		disk, err := cmd.Flags().GetUint64("disk")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		ipv4, err := cmd.Flags().GetBool("ipv4")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		ipv6, err := cmd.Flags().GetBool("ipv6")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		ygg, err := cmd.Flags().GetBool("ygg")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		mycelium, err := cmd.Flags().GetBool("mycelium")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		cfg, err := config.GetUserConfig()
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		t, err := deployer.NewTFPluginClient(cfg.Mnemonics, deployer.WithNetwork(cfg.Network), deployer.WithRMBTimeout(100))
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		cluster, err := command.GetK8sCluster(cmd.Context(), t, name)
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		pool := workloads.K8sNodePool{
+			Name:    poolName,
+			MinSize: minSize,
+			MaxSize: maxSize,
+			Worker: workloads.K8sNode{
+				VM: &workloads.VM{
+					CPU:       cpu,
+					MemoryMB:  memory * 1024,
+					PublicIP:  ipv4,
+					PublicIP6: ipv6,
+					Planetary: ygg,
+				},
+				DiskSizeGB: disk,
+			},
+		}
+		if farm != 0 {
+			pool.NodeFilter = types.NodeFilter{FarmIDs: []uint64{farm}}
+		}
+		if mycelium {
+			pool.Worker.MyceliumIPSeed, err = workloads.RandomMyceliumIPSeed()
+			if err != nil {
+				log.Fatal().Err(err).Send()
+			}
+		}
+		cluster.NodePools = append(cluster.NodePools, pool)
+
+		log.Info().Msgf("scaling node pool %s from %d to %d workers", poolName, len(cluster.PoolWorkers(poolName)), size)
+		err = t.K8sDeployer.Scale(cmd.Context(), &cluster, poolName, size)
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+
+		for _, worker := range cluster.PoolWorkers(poolName) {
+			log.Info().Msgf("%s wireguard ip: %s", worker.Name, worker.IP)
+		}
+	},
+}
+
+func init() {
+	updateKubernetesCmd.AddCommand(scaleKubernetesCmd)
+
+	scaleKubernetesCmd.Flags().StringP("name", "n", "", "name of the kubernetes cluster")
+	err := scaleKubernetesCmd.MarkFlagRequired("name")
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	scaleKubernetesCmd.Flags().IntP("size", "s", 0, "number of workers of the node pool")
+	err = scaleKubernetesCmd.MarkFlagRequired("size")
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	scaleKubernetesCmd.Flags().String("pool", "worker", "name of the node pool")
+	scaleKubernetesCmd.Flags().Int("min", 0, "minimum number of workers of the node pool")
+	scaleKubernetesCmd.Flags().Int("max", 0, "maximum number of workers of the node pool, 0 for no limit")
+	scaleKubernetesCmd.Flags().Uint64("farm", 0, "farm id new workers should be deployed on, 0 for any farm")
+	scaleKubernetesCmd.Flags().Uint8("cpu", 1, "new workers number of cpu units")
+	scaleKubernetesCmd.Flags().Uint64("memory", 1, "new workers memory size in gb")
+	scaleKubernetesCmd.Flags().Uint64("disk", 2, "new workers disk size in gb")
+	scaleKubernetesCmd.Flags().Bool("ipv4", false, "assign public ipv4 for new workers")
+	scaleKubernetesCmd.Flags().Bool("ipv6", false, "assign public ipv6 for new workers")
+	scaleKubernetesCmd.Flags().Bool("ygg", true, "assign yggdrasil ip for new workers")
+	scaleKubernetesCmd.Flags().Bool("mycelium", true, "assign mycelium ip for new workers")
+}
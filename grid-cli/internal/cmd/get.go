@@ -10,16 +10,6 @@ import (
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
 )
 
-func checkIfExistAndAppend(t deployer.TFPluginClient, node uint32, contractID uint64) {
-	for _, n := range t.State.CurrentNodeDeployments[node] {
-		if n == contractID {
-			return
-		}
-	}
-
-	t.State.CurrentNodeDeployments[node] = append(t.State.CurrentNodeDeployments[node], contractID)
-}
-
 // GetVM gets a vm with its project name
 func GetVM(ctx context.Context, t deployer.TFPluginClient, name string) (workloads.Deployment, error) {
 	projectName := name
@@ -52,7 +42,7 @@ func GetVM(ctx context.Context, t deployer.TFPluginClient, name string) (workloa
 		}
 
 		nodeID = contract.NodeID
-		checkIfExistAndAppend(t, nodeID, contractID)
+		t.State.StoreContractIDs(nodeID, contractID)
 
 	}
 
@@ -89,7 +79,7 @@ func GetK8sCluster(ctx context.Context, t deployer.TFPluginClient, name string)
 			return workloads.K8sCluster{}, err
 		}
 
-		checkIfExistAndAppend(t, contract.NodeID, contractID)
+		t.State.StoreContractIDs(contract.NodeID, contractID)
 		nodeIDs = append(nodeIDs, contract.NodeID)
 	}
 
@@ -104,7 +94,7 @@ func GetGatewayName(ctx context.Context, t deployer.TFPluginClient, name string)
 	}
 	var nodeID uint32
 	for node, contractID := range nodeContractIDs {
-		t.State.CurrentNodeDeployments[node] = []uint64{contractID}
+		t.State.StoreContractIDs(node, contractID)
 		nodeID = node
 	}
 
@@ -119,7 +109,7 @@ func GetGatewayFQDN(ctx context.Context, t deployer.TFPluginClient, name string)
 	}
 	var nodeID uint32
 	for node, contractID := range nodeContractIDs {
-		t.State.CurrentNodeDeployments[node] = []uint64{contractID}
+		t.State.StoreContractIDs(node, contractID)
 		nodeID = node
 	}
 
@@ -135,7 +125,7 @@ func GetDeployment(ctx context.Context, t deployer.TFPluginClient, name string)
 
 	var nodeID uint32
 	for node, contractID := range nodeContractIDs {
-		checkIfExistAndAppend(t, node, contractID)
+		t.State.StoreContractIDs(node, contractID)
 		nodeID = node
 	}
 
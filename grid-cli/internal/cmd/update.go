@@ -84,7 +84,7 @@ func DeleteWorkerKubernetesCluster(ctx context.Context, t deployer.TFPluginClien
 	}
 
 	for _, node := range removedNodes {
-		delete(t.State.CurrentNodeDeployments, node)
+		t.State.RemoveContractIDs(node, slices.Clone(t.State.CurrentNodeDeployments[node])...)
 	}
 
 	log.Info().Msg("updating cluster")
@@ -2,32 +2,48 @@ package calculator
 
 import (
 	"math"
+	"sort"
 
+	"github.com/pkg/errors"
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
+	proxy "github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/client"
 )
 
 const defaultPricingPolicyID = uint32(1)
 
 // Calculator struct for calculating the cost of resources
 type Calculator struct {
-	substrateConn subi.SubstrateExt
-	identity      substrate.Identity
+	pricing         PricingSource
+	substrateConn   subi.SubstrateExt
+	identity        substrate.Identity
+	gridProxyClient proxy.Client
 }
 
-// NewCalculator creates a new Calculator
-func NewCalculator(substrateConn subi.SubstrateExt, identity substrate.Identity) Calculator {
-	return Calculator{substrateConn: substrateConn, identity: identity}
+// NewCalculator creates a new Calculator using tfchain prices, the grid proxy client is only needed to estimate deployments
+func NewCalculator(substrateConn subi.SubstrateExt, identity substrate.Identity, gridProxyClient proxy.Client) Calculator {
+	return NewCalculatorWithPricingSource(NewChainPricingSource(substrateConn), substrateConn, identity, gridProxyClient)
+}
+
+// NewCalculatorWithPricingSource creates a new Calculator using the given prices,
+// the substrate connection is only needed for the account balance discounts and the nodes rented by the identity twin
+func NewCalculatorWithPricingSource(pricing PricingSource, substrateConn subi.SubstrateExt, identity substrate.Identity, gridProxyClient proxy.Client) Calculator {
+	return Calculator{pricing: pricing, substrateConn: substrateConn, identity: identity, gridProxyClient: gridProxyClient}
+}
+
+// TFTPrice returns the TFT price in USD
+func (c *Calculator) TFTPrice() (float64, error) {
+	return c.pricing.TFTPrice()
 }
 
 // CalculateCost calculates the cost in $ per month of the given resources without a discount
 func (c *Calculator) CalculateCost(cru, mru, hru, sru int64, publicIP, certified bool) (float64, error) {
-	tftPrice, err := c.substrateConn.GetTFTPrice()
+	tftPrice, err := c.pricing.TFTPrice()
 	if err != nil {
 		return 0, err
 	}
 
-	pricingPolicy, err := c.substrateConn.GetPricingPolicy(defaultPricingPolicyID)
+	pricingPolicy, err := c.pricing.PricingPolicy(defaultPricingPolicyID)
 	if err != nil {
 		return 0, err
 	}
@@ -45,18 +61,23 @@ func (c *Calculator) CalculateCost(cru, mru, hru, sru int64, publicIP, certified
 		certifiedFactor = 1.25
 	}
 
-	costPerMonth := (cu*float64(pricingPolicy.CU.Value) + su*float64(pricingPolicy.SU.Value) + ipv4*float64(pricingPolicy.IPU.Value)) * certifiedFactor * 24 * 30
-	return costPerMonth / float64(tftPrice) / 1000, nil
+	costPerMonth := (cu*float64(pricingPolicy.CU) + su*float64(pricingPolicy.SU) + ipv4*float64(pricingPolicy.IPU)) * certifiedFactor * 24 * 30
+	return costPerMonth / (tftPrice * 1000) / 1000, nil
 }
 
 // CalculateDiscount calculates the discount of a given cost
 func (c *Calculator) CalculateDiscount(cost float64) (dedicatedPrice, sharedPrice float64, err error) {
-	tftPrice, err := c.substrateConn.GetTFTPrice()
+	tftPrice, err := c.pricing.TFTPrice()
 	if err != nil {
 		return
 	}
 
-	pricingPolicy, err := c.substrateConn.GetPricingPolicy(defaultPricingPolicyID)
+	pricingPolicy, err := c.pricing.PricingPolicy(defaultPricingPolicyID)
+	if err != nil {
+		return
+	}
+
+	discountPackages, err := c.pricing.DiscountPackages()
 	if err != nil {
 		return
 	}
@@ -69,49 +90,38 @@ func (c *Calculator) CalculateDiscount(cost float64) (dedicatedPrice, sharedPric
 	dedicatedPrice = cost - cost*(discount/100)
 
 	// discount for Twin Balance in TFT
+	if c.substrateConn == nil {
+		err = errors.New("a substrate connection is needed to get the account balance")
+		return
+	}
+
 	accountBalance, err := c.substrateConn.GetBalance(c.identity)
 	if err != nil {
 		return
 	}
-	balance := float64(tftPrice) / 1000 * float64(accountBalance.Free.Int64()) * 10000000
-
-	discountPackages := map[string]map[string]float64{
-		"none": {
-			"duration": 0,
-			"discount": 0,
-		},
-		"default": {
-			"duration": 1.5,
-			"discount": 20,
-		},
-		"bronze": {
-			"duration": 3,
-			"discount": 30,
-		},
-		"silver": {
-			"duration": 6,
-			"discount": 40,
-		},
-		"gold": {
-			"duration": 18,
-			"discount": 60,
-		},
-	}
+	balance := tftPrice * float64(accountBalance.Free.Int64()) * 10000000
 
-	// check which package will be used according to the balance
-	dedicatedPackage := "none"
-	sharedPackage := "none"
+	// check which package will be used according to the balance, packages with longer durations are checked last
+	packages := make([]string, 0, len(discountPackages))
 	for pkg := range discountPackages {
-		if balance > dedicatedPrice*discountPackages[pkg]["duration"] {
-			dedicatedPackage = pkg
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return discountPackages[packages[i]].Duration < discountPackages[packages[j]].Duration
+	})
+
+	var dedicatedDiscount, sharedDiscount float64
+	for _, pkg := range packages {
+		if balance > dedicatedPrice*discountPackages[pkg].Duration {
+			dedicatedDiscount = discountPackages[pkg].Discount
 		}
-		if balance > sharedPrice*discountPackages[pkg]["duration"] {
-			sharedPackage = pkg
+		if balance > sharedPrice*discountPackages[pkg].Duration {
+			sharedDiscount = discountPackages[pkg].Discount
 		}
 	}
 
-	dedicatedPrice = (dedicatedPrice - dedicatedPrice*(discountPackages[dedicatedPackage]["discount"]/100)) / 1e7
-	sharedPrice = (sharedPrice - sharedPrice*(discountPackages[sharedPackage]["discount"]/100)) / 1e7
+	dedicatedPrice = (dedicatedPrice - dedicatedPrice*(dedicatedDiscount/100)) / 1e7
+	sharedPrice = (sharedPrice - sharedPrice*(sharedDiscount/100)) / 1e7
 
 	return
 }
@@ -20,7 +20,7 @@ func TestCalculator(t *testing.T) {
 	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
 	assert.NoError(t, err)
 
-	calculator := NewCalculator(sub, identity)
+	calculator := NewCalculator(sub, identity, nil)
 
 	sub.EXPECT().GetTFTPrice().Return(types.U32(1), nil).AnyTimes()
 	sub.EXPECT().GetPricingPolicy(1).Return(substrate.PricingPolicy{
@@ -59,7 +59,7 @@ func TestSubstrateErrors(t *testing.T) {
 	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
 	assert.NoError(t, err)
 
-	calculator := NewCalculator(sub, identity)
+	calculator := NewCalculator(sub, identity, nil)
 
 	t.Run("test tft price error", func(t *testing.T) {
 		sub.EXPECT().GetTFTPrice().Return(types.U32(1), errors.New("error")).AnyTimes()
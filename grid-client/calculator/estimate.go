@@ -0,0 +1,390 @@
+package calculator
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+)
+
+// line items descriptions
+const (
+	ItemComputeUnits = "compute units"
+	ItemStorageUnits = "storage units"
+	ItemPublicIPs    = "public ipv4"
+	ItemNodeRent     = "node rent"
+	ItemExtraFee     = "node extra fee"
+	ItemUniqueName   = "unique name"
+)
+
+const (
+	// pricing policies values are in 1e-7 USD per hour
+	unitValueToUSD = 1e-7
+	hoursPerMonth  = 24 * 30
+	// extra fees are in mUSD per month
+	extraFeeToUSD     = 1e-3
+	certifiedFactor   = 1.25
+	certificationType = "Certified"
+)
+
+// LineItem is a single cost of an estimate
+type LineItem struct {
+	NodeID      uint32  `json:"node_id,omitempty"`
+	Description string  `json:"description"`
+	Units       float64 `json:"units"`
+	// MonthlyCost is the cost of the item in USD per month
+	MonthlyCost float64 `json:"monthly_cost"`
+}
+
+// Estimate is the monthly cost estimation of a deployment with its line items
+type Estimate struct {
+	Items []LineItem `json:"items"`
+	// MonthlyCost is the total cost in USD per month
+	MonthlyCost float64 `json:"monthly_cost"`
+	// MonthlyCostTFT is the total cost in TFT per month with the current TFT price
+	MonthlyCostTFT float64 `json:"monthly_cost_tft"`
+}
+
+// NodeWorkloads is the workloads deployed on a node
+type NodeWorkloads struct {
+	NodeID    uint32
+	Workloads []zos.Workload
+}
+
+// estimation caches the grid data needed by a single estimate
+type estimation struct {
+	*Calculator
+	ctx      context.Context
+	twinID   uint32
+	nodes    map[uint32]types.NodeWithNestedCapacity
+	policies map[uint32]PricingPolicy
+}
+
+// EstimateDeployment estimates the monthly cost of a deployment, a k8s cluster or a gateway with a line item for every cost,
+// the farms pricing policies, the nodes certification, dedicated nodes rent and extra fees are included
+func (c *Calculator) EstimateDeployment(ctx context.Context, deployment interface{}) (Estimate, error) {
+	var nodes []NodeWorkloads
+	var names []string
+
+	switch dl := deployment.(type) {
+	case workloads.Deployment:
+		return c.EstimateDeployment(ctx, &dl)
+	case workloads.K8sCluster:
+		return c.EstimateDeployment(ctx, &dl)
+	case workloads.GatewayNameProxy:
+		return c.EstimateDeployment(ctx, &dl)
+	case workloads.GatewayFQDNProxy:
+		return c.EstimateDeployment(ctx, &dl)
+
+	case *workloads.Deployment:
+		zosDl, err := dl.ZosDeployment(0)
+		if err != nil {
+			return Estimate{}, errors.Wrapf(err, "failed to generate deployment %s workloads", dl.Name)
+		}
+		nodes = append(nodes, NodeWorkloads{NodeID: dl.NodeID, Workloads: zosDl.Workloads})
+
+	case *workloads.K8sCluster:
+		if dl.Master == nil {
+			return Estimate{}, errors.New("k8s cluster has no master")
+		}
+		nodes = append(nodes, NodeWorkloads{NodeID: dl.Master.NodeID, Workloads: zosWorkloads(dl.Master.MasterZosWorkload(dl)...)})
+		for _, master := range dl.Masters {
+			nodes = append(nodes, NodeWorkloads{NodeID: master.NodeID, Workloads: zosWorkloads(master.JoiningMasterZosWorkload(dl)...)})
+		}
+		for _, worker := range dl.Workers {
+			nodes = append(nodes, NodeWorkloads{NodeID: worker.NodeID, Workloads: zosWorkloads(worker.WorkerZosWorkload(dl)...)})
+		}
+
+	case *workloads.GatewayNameProxy:
+		nodes = append(nodes, NodeWorkloads{NodeID: dl.NodeID, Workloads: zosWorkloads(dl.ZosWorkload())})
+		names = append(names, dl.Name)
+
+	case *workloads.GatewayFQDNProxy:
+		nodes = append(nodes, NodeWorkloads{NodeID: dl.NodeID, Workloads: zosWorkloads(dl.ZosWorkload())})
+
+	default:
+		return Estimate{}, errors.Errorf("cannot estimate the cost of %T", deployment)
+	}
+
+	estimate, err := c.EstimateNodeWorkloads(ctx, nodes...)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	if len(names) == 0 {
+		return estimate, nil
+	}
+
+	policy, err := c.pricingPolicy(defaultPricingPolicyID)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	for range names {
+		estimate.add(LineItem{
+			Description: ItemUniqueName,
+			Units:       1,
+			MonthlyCost: float64(policy.UniqueName) * hoursPerMonth * unitValueToUSD,
+		})
+	}
+
+	return estimate, c.setTFTCost(&estimate)
+}
+
+// EstimateNodeWorkloads estimates the monthly cost of the given workloads on their nodes
+func (c *Calculator) EstimateNodeWorkloads(ctx context.Context, nodes ...NodeWorkloads) (Estimate, error) {
+	if c.gridProxyClient == nil {
+		return Estimate{}, errors.New("a grid proxy client is needed to estimate deployments")
+	}
+
+	e := estimation{
+		Calculator: c,
+		ctx:        ctx,
+		nodes:      map[uint32]types.NodeWithNestedCapacity{},
+		policies:   map[uint32]PricingPolicy{},
+	}
+
+	// workloads on the same node are billed together
+	nodesCapacity := map[uint32]*zos.Capacity{}
+	for _, node := range nodes {
+		if _, ok := nodesCapacity[node.NodeID]; !ok {
+			nodesCapacity[node.NodeID] = &zos.Capacity{}
+		}
+
+		for _, wl := range node.Workloads {
+			wlCap, err := wl.Capacity()
+			if err != nil {
+				return Estimate{}, errors.Wrapf(err, "failed to get workload %s capacity", wl.Name)
+			}
+			nodesCapacity[node.NodeID].Add(&wlCap)
+		}
+	}
+
+	nodeIDs := make([]uint32, 0, len(nodesCapacity))
+	for nodeID := range nodesCapacity {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool { return nodeIDs[i] < nodeIDs[j] })
+
+	var estimate Estimate
+	for _, nodeID := range nodeIDs {
+		items, err := e.estimateNode(nodeID, *nodesCapacity[nodeID])
+		if err != nil {
+			return Estimate{}, errors.Wrapf(err, "failed to estimate node %d cost", nodeID)
+		}
+
+		for _, item := range items {
+			estimate.add(item)
+		}
+	}
+
+	return estimate, c.setTFTCost(&estimate)
+}
+
+// estimateNode estimates the cost of the given capacity on a node
+func (e *estimation) estimateNode(nodeID uint32, cap zos.Capacity) ([]LineItem, error) {
+	node, err := e.node(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := e.farmPricingPolicy(uint32(node.FarmID))
+	if err != nil {
+		return nil, err
+	}
+
+	factor := 1.0
+	if node.CertificationType == certificationType {
+		factor = certifiedFactor
+	}
+
+	var items []LineItem
+	if cap.IPV4U != 0 {
+		ips := float64(cap.IPV4U)
+		items = append(items, LineItem{
+			NodeID:      nodeID,
+			Description: ItemPublicIPs,
+			Units:       ips,
+			MonthlyCost: ips * float64(policy.IPU) * factor * hoursPerMonth * unitValueToUSD,
+		})
+	}
+
+	dedicated := node.Dedicated || node.InDedicatedFarm
+	rented := node.Rented || node.RentedByTwinID != 0
+	if rented {
+		twinID, err := e.twin()
+		if err != nil {
+			return nil, err
+		}
+
+		if node.RentedByTwinID != uint(twinID) {
+			return nil, errors.Errorf("node %d is rented by twin %d", nodeID, node.RentedByTwinID)
+		}
+
+		// the capacity of a rented node is paid by its rent contract
+		return items, nil
+	}
+
+	if dedicated {
+		// a dedicated node must be rented, the whole node capacity is paid with the dedicated nodes discount
+		total := node.Capacity.Total
+		rent := computeUnits(float64(total.CRU), toGB(uint64(total.MRU)))*float64(policy.CU) +
+			storageUnits(toGB(uint64(total.HRU)), toGB(uint64(total.SRU)))*float64(policy.SU)
+		rent *= factor * (1 - float64(policy.DedicatedNodesDiscount)/100)
+
+		items = append(items, LineItem{
+			NodeID:      nodeID,
+			Description: ItemNodeRent,
+			Units:       1,
+			MonthlyCost: rent * hoursPerMonth * unitValueToUSD,
+		})
+
+		if node.ExtraFee != 0 {
+			items = append(items, LineItem{
+				NodeID:      nodeID,
+				Description: ItemExtraFee,
+				Units:       1,
+				MonthlyCost: float64(node.ExtraFee) * extraFeeToUSD,
+			})
+		}
+
+		return items, nil
+	}
+
+	cu := computeUnits(float64(cap.CRU), toGB(cap.MRU))
+	su := storageUnits(toGB(cap.HRU), toGB(cap.SRU))
+
+	return append(items,
+		LineItem{
+			NodeID:      nodeID,
+			Description: ItemComputeUnits,
+			Units:       cu,
+			MonthlyCost: cu * float64(policy.CU) * factor * hoursPerMonth * unitValueToUSD,
+		},
+		LineItem{
+			NodeID:      nodeID,
+			Description: ItemStorageUnits,
+			Units:       su,
+			MonthlyCost: su * float64(policy.SU) * factor * hoursPerMonth * unitValueToUSD,
+		},
+	), nil
+}
+
+func (e *estimation) node(nodeID uint32) (types.NodeWithNestedCapacity, error) {
+	if node, ok := e.nodes[nodeID]; ok {
+		return node, nil
+	}
+
+	node, err := e.gridProxyClient.Node(e.ctx, nodeID)
+	if err != nil {
+		return types.NodeWithNestedCapacity{}, errors.Wrapf(err, "could not get node %d data from the grid proxy", nodeID)
+	}
+
+	e.nodes[nodeID] = node
+	return node, nil
+}
+
+func (e *estimation) farmPricingPolicy(farmID uint32) (PricingPolicy, error) {
+	if policy, ok := e.policies[farmID]; ok {
+		return policy, nil
+	}
+
+	farmID64 := uint64(farmID)
+	farms, _, err := e.gridProxyClient.Farms(e.ctx, types.FarmFilter{FarmID: &farmID64}, types.Limit{Page: 1, Size: 1})
+	if err != nil {
+		return PricingPolicy{}, errors.Wrapf(err, "could not get farm %d data from the grid proxy", farmID)
+	}
+
+	if len(farms) == 0 {
+		return PricingPolicy{}, errors.Errorf("farm %d not returned from the proxy", farmID)
+	}
+
+	policyID := uint32(farms[0].PricingPolicyID)
+	if policyID == 0 {
+		policyID = defaultPricingPolicyID
+	}
+
+	policy, err := e.pricingPolicy(policyID)
+	if err != nil {
+		return PricingPolicy{}, err
+	}
+
+	e.policies[farmID] = policy
+	return policy, nil
+}
+
+func (e *estimation) twin() (uint32, error) {
+	if e.twinID != 0 {
+		return e.twinID, nil
+	}
+
+	if e.identity == nil || e.substrateConn == nil {
+		return 0, errors.New("an identity and a substrate connection are needed to estimate the cost on rented nodes")
+	}
+
+	twinID, err := e.substrateConn.GetTwinByPubKey(e.identity.PublicKey())
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get twin of the calculator identity")
+	}
+
+	e.twinID = twinID
+	return twinID, nil
+}
+
+func (c *Calculator) pricingPolicy(policyID uint32) (PricingPolicy, error) {
+	policy, err := c.pricing.PricingPolicy(policyID)
+	if err != nil {
+		return PricingPolicy{}, errors.Wrapf(err, "failed to get pricing policy %d", policyID)
+	}
+
+	return policy, nil
+}
+
+func (c *Calculator) setTFTCost(estimate *Estimate) error {
+	tftPrice, err := c.pricing.TFTPrice()
+	if err != nil {
+		return errors.Wrap(err, "failed to get tft price")
+	}
+
+	if tftPrice <= 0 {
+		return errors.New("tft price must be positive")
+	}
+
+	estimate.MonthlyCostTFT = estimate.MonthlyCost / tftPrice
+	return nil
+}
+
+func (e *Estimate) add(item LineItem) {
+	e.Items = append(e.Items, item)
+	e.MonthlyCost += item.MonthlyCost
+}
+
+func zosWorkloads(wls ...gridtypes.Workload) []zos.Workload {
+	res := make([]zos.Workload, 0, len(wls))
+	for _, wl := range wls {
+		res = append(res, zos.NewWorkloadFromZosWorkload(wl))
+	}
+	return res
+}
+
+func toGB(bytes uint64) float64 {
+	return float64(bytes) / float64(zos.Gigabyte)
+}
+
+// computeUnits calculates the compute units of the given cpus and memory in GB
+func computeUnits(cru, mru float64) float64 {
+	cu1 := max(mru/4, cru/2)
+	cu2 := max(mru/8, cru)
+	cu3 := max(mru/2, cru/4)
+
+	return min(cu1, cu2, cu3)
+}
+
+// storageUnits calculates the storage units of the given hdd and ssd storage in GB
+func storageUnits(hru, sru float64) float64 {
+	return hru/1200 + sru/200
+}
@@ -0,0 +1,162 @@
+package calculator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	proxyTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	zosTypes "github.com/threefoldtech/zos/pkg/gridtypes/zos"
+)
+
+func TestEstimateDeployment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	gridProxy := mocks.NewMockClient(ctrl)
+	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
+	require.NoError(t, err)
+
+	calculator := NewCalculator(sub, identity, gridProxy)
+
+	// tft price is 0.02 USD
+	sub.EXPECT().GetTFTPrice().Return(types.U32(20), nil).AnyTimes()
+	sub.EXPECT().GetPricingPolicy(uint32(1)).Return(substrate.PricingPolicy{
+		CU:                     substrate.Policy{Value: 100000},
+		SU:                     substrate.Policy{Value: 50000},
+		IPU:                    substrate.Policy{Value: 10000},
+		UniqueName:             substrate.Policy{Value: 2000},
+		DedicatedNodesDiscount: 50,
+	}, nil).AnyTimes()
+	sub.EXPECT().GetPricingPolicy(uint32(2)).Return(substrate.PricingPolicy{
+		SU: substrate.Policy{Value: 100000},
+	}, nil).AnyTimes()
+	sub.EXPECT().GetTwinByPubKey(identity.PublicKey()).Return(uint32(7), nil).AnyTimes()
+
+	farms := map[uint64]int{1: 0, 2: 2}
+	gridProxy.EXPECT().
+		Farms(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, filter proxyTypes.FarmFilter, _ proxyTypes.Limit) ([]proxyTypes.Farm, int, error) {
+			return []proxyTypes.Farm{{FarmID: int(*filter.FarmID), PricingPolicyID: farms[*filter.FarmID]}}, 1, nil
+		}).AnyTimes()
+
+	nodes := map[uint32]proxyTypes.NodeWithNestedCapacity{
+		1: {NodeID: 1, FarmID: 1},
+		2: {NodeID: 2, FarmID: 1, CertificationType: "Certified"},
+		3: {NodeID: 3, FarmID: 2},
+		4: {
+			NodeID:    4,
+			FarmID:    1,
+			Rentable:  true,
+			Dedicated: true,
+			ExtraFee:  5000,
+			Capacity: proxyTypes.CapacityResult{Total: proxyTypes.Capacity{
+				CRU: 4,
+				MRU: 16 * gridtypes.Gigabyte,
+				SRU: 400 * gridtypes.Gigabyte,
+			}},
+		},
+		5: {NodeID: 5, FarmID: 1, Rented: true, RentedByTwinID: 7},
+		6: {NodeID: 6, FarmID: 1, Rented: true, RentedByTwinID: 8},
+	}
+	gridProxy.EXPECT().
+		Node(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, nodeID uint32) (proxyTypes.NodeWithNestedCapacity, error) {
+			return nodes[nodeID], nil
+		}).AnyTimes()
+
+	diskDeployment := func(node uint32) workloads.Deployment {
+		return workloads.NewDeployment("dl", node, "", nil, "", []workloads.Disk{{Name: "disk", SizeGB: 200}}, nil, nil, nil, nil, nil)
+	}
+
+	t.Run("shared node", func(t *testing.T) {
+		estimate, err := calculator.EstimateDeployment(context.Background(), diskDeployment(1))
+		require.NoError(t, err)
+
+		require.Len(t, estimate.Items, 2)
+		assert.Equal(t, ItemComputeUnits, estimate.Items[0].Description)
+		assert.Zero(t, estimate.Items[0].MonthlyCost)
+		assert.Equal(t, ItemStorageUnits, estimate.Items[1].Description)
+		assert.InDelta(t, 1, estimate.Items[1].Units, 1e-9)
+
+		assert.InDelta(t, 3.6, estimate.MonthlyCost, 1e-9)
+		assert.InDelta(t, 180, estimate.MonthlyCostTFT, 1e-9)
+	})
+
+	t.Run("certified node", func(t *testing.T) {
+		dl := diskDeployment(2)
+		estimate, err := calculator.EstimateDeployment(context.Background(), &dl)
+		require.NoError(t, err)
+		assert.InDelta(t, 4.5, estimate.MonthlyCost, 1e-9)
+	})
+
+	t.Run("farm pricing policy", func(t *testing.T) {
+		estimate, err := calculator.EstimateDeployment(context.Background(), diskDeployment(3))
+		require.NoError(t, err)
+		assert.InDelta(t, 7.2, estimate.MonthlyCost, 1e-9)
+	})
+
+	t.Run("dedicated node", func(t *testing.T) {
+		estimate, err := calculator.EstimateDeployment(context.Background(), diskDeployment(4))
+		require.NoError(t, err)
+
+		require.Len(t, estimate.Items, 2)
+		assert.Equal(t, LineItem{NodeID: 4, Description: ItemNodeRent, Units: 1, MonthlyCost: estimate.Items[0].MonthlyCost}, estimate.Items[0])
+		assert.InDelta(t, 18, estimate.Items[0].MonthlyCost, 1e-9)
+		assert.Equal(t, LineItem{NodeID: 4, Description: ItemExtraFee, Units: 1, MonthlyCost: 5}, estimate.Items[1])
+		assert.InDelta(t, 23, estimate.MonthlyCost, 1e-9)
+	})
+
+	t.Run("rented node", func(t *testing.T) {
+		estimate, err := calculator.EstimateDeployment(context.Background(), diskDeployment(5))
+		require.NoError(t, err)
+		assert.Empty(t, estimate.Items)
+		assert.Zero(t, estimate.MonthlyCost)
+
+		_, err = calculator.EstimateDeployment(context.Background(), diskDeployment(6))
+		assert.Error(t, err)
+	})
+
+	t.Run("public ips", func(t *testing.T) {
+		ip := zos.Workload{
+			Name:    "ip",
+			Type:    zos.PublicIPType,
+			Version: 0,
+			Data:    zos.MustMarshal(zos.PublicIP{V4: true}),
+		}
+
+		estimate, err := calculator.EstimateNodeWorkloads(context.Background(), NodeWorkloads{NodeID: 5, Workloads: []zos.Workload{ip}})
+		require.NoError(t, err)
+		require.Len(t, estimate.Items, 1)
+		assert.Equal(t, ItemPublicIPs, estimate.Items[0].Description)
+		assert.InDelta(t, 0.72, estimate.MonthlyCost, 1e-9)
+	})
+
+	t.Run("gateway name", func(t *testing.T) {
+		gw := workloads.GatewayNameProxy{NodeID: 1, Name: "name", Backends: []zosTypes.Backend{"http://1.1.1.1:9000"}}
+		estimate, err := calculator.EstimateDeployment(context.Background(), gw)
+		require.NoError(t, err)
+		assert.Equal(t, ItemUniqueName, estimate.Items[len(estimate.Items)-1].Description)
+		assert.InDelta(t, 0.144, estimate.MonthlyCost, 1e-9)
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		_, err := calculator.EstimateDeployment(context.Background(), workloads.Disk{})
+		assert.Error(t, err)
+	})
+
+	t.Run("no grid proxy client", func(t *testing.T) {
+		calculator := NewCalculator(sub, identity, nil)
+		_, err := calculator.EstimateDeployment(context.Background(), diskDeployment(1))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,177 @@
+package calculator
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
+)
+
+// PricingPolicy is a grid pricing policy, units values are in 1e-7 USD per hour
+type PricingPolicy struct {
+	ID         uint32 `json:"id"`
+	CU         uint32 `json:"cu"`
+	SU         uint32 `json:"su"`
+	NU         uint32 `json:"nu"`
+	IPU        uint32 `json:"ipu"`
+	UniqueName uint32 `json:"unique_name"`
+	DomainName uint32 `json:"domain_name"`
+	// DedicatedNodesDiscount is the discount percentage of dedicated nodes
+	DedicatedNodesDiscount uint8 `json:"dedicated_nodes_discount"`
+}
+
+// DiscountPackage is a discount applied if the account balance covers the cost for the package duration
+type DiscountPackage struct {
+	// Duration is in months
+	Duration float64 `json:"duration"`
+	// Discount is a percentage
+	Discount float64 `json:"discount"`
+}
+
+// DefaultDiscountPackages are the grid discount packages
+var DefaultDiscountPackages = map[string]DiscountPackage{
+	"none":    {Duration: 0, Discount: 0},
+	"default": {Duration: 1.5, Discount: 20},
+	"bronze":  {Duration: 3, Discount: 30},
+	"silver":  {Duration: 6, Discount: 40},
+	"gold":    {Duration: 18, Discount: 60},
+}
+
+// PricingSource provides the grid prices used by the calculator
+type PricingSource interface {
+	// TFTPrice returns the TFT price in USD
+	TFTPrice() (float64, error)
+	PricingPolicy(policyID uint32) (PricingPolicy, error)
+	DiscountPackages() (map[string]DiscountPackage, error)
+}
+
+// ChainPricingSource gets the prices from tfchain
+type ChainPricingSource struct {
+	substrateConn subi.SubstrateExt
+}
+
+// NewChainPricingSource creates a new pricing source backed by tfchain
+func NewChainPricingSource(substrateConn subi.SubstrateExt) ChainPricingSource {
+	return ChainPricingSource{substrateConn: substrateConn}
+}
+
+// TFTPrice returns the TFT price in USD
+func (s ChainPricingSource) TFTPrice() (float64, error) {
+	price, err := s.substrateConn.GetTFTPrice()
+	if err != nil {
+		return 0, err
+	}
+
+	// tfchain price is in mUSD
+	return float64(price) / 1000, nil
+}
+
+// PricingPolicy returns a pricing policy from tfchain
+func (s ChainPricingSource) PricingPolicy(policyID uint32) (PricingPolicy, error) {
+	policy, err := s.substrateConn.GetPricingPolicy(policyID)
+	if err != nil {
+		return PricingPolicy{}, err
+	}
+
+	return PricingPolicy{
+		ID:                     uint32(policy.ID),
+		CU:                     uint32(policy.CU.Value),
+		SU:                     uint32(policy.SU.Value),
+		NU:                     uint32(policy.NU.Value),
+		IPU:                    uint32(policy.IPU.Value),
+		UniqueName:             uint32(policy.UniqueName.Value),
+		DomainName:             uint32(policy.DomainName.Value),
+		DedicatedNodesDiscount: uint8(policy.DedicatedNodesDiscount),
+	}, nil
+}
+
+// DiscountPackages returns the default discount packages as they are not stored on tfchain
+func (s ChainPricingSource) DiscountPackages() (map[string]DiscountPackage, error) {
+	return DefaultDiscountPackages, nil
+}
+
+// PricingSnapshot is a static pricing source, e.g. loaded from a json file for offline calculations
+type PricingSnapshot struct {
+	// TFT price in USD
+	TFT             float64                    `json:"tft_price"`
+	PricingPolicies map[uint32]PricingPolicy   `json:"pricing_policies"`
+	Discounts       map[string]DiscountPackage `json:"discount_packages"`
+}
+
+// NewPricingSnapshot takes a snapshot of the given pricing policies from a pricing source
+func NewPricingSnapshot(source PricingSource, policyIDs ...uint32) (PricingSnapshot, error) {
+	tftPrice, err := source.TFTPrice()
+	if err != nil {
+		return PricingSnapshot{}, errors.Wrap(err, "failed to get tft price")
+	}
+
+	packages, err := source.DiscountPackages()
+	if err != nil {
+		return PricingSnapshot{}, errors.Wrap(err, "failed to get discount packages")
+	}
+
+	snapshot := PricingSnapshot{
+		TFT:             tftPrice,
+		PricingPolicies: map[uint32]PricingPolicy{},
+		Discounts:       packages,
+	}
+
+	for _, id := range policyIDs {
+		policy, err := source.PricingPolicy(id)
+		if err != nil {
+			return PricingSnapshot{}, errors.Wrapf(err, "failed to get pricing policy %d", id)
+		}
+		snapshot.PricingPolicies[id] = policy
+	}
+
+	return snapshot, nil
+}
+
+// ParsePricingSnapshot parses a json pricing snapshot
+func ParsePricingSnapshot(data []byte) (PricingSnapshot, error) {
+	var snapshot PricingSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return PricingSnapshot{}, errors.Wrap(err, "failed to parse pricing snapshot")
+	}
+
+	if snapshot.TFT <= 0 {
+		return PricingSnapshot{}, errors.New("pricing snapshot tft price must be positive")
+	}
+
+	if snapshot.Discounts == nil {
+		snapshot.Discounts = DefaultDiscountPackages
+	}
+
+	return snapshot, nil
+}
+
+// LoadPricingSnapshot loads a json pricing snapshot from a file
+func LoadPricingSnapshot(path string) (PricingSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PricingSnapshot{}, errors.Wrapf(err, "failed to read pricing snapshot %s", path)
+	}
+
+	return ParsePricingSnapshot(data)
+}
+
+// TFTPrice returns the snapshot TFT price in USD
+func (s PricingSnapshot) TFTPrice() (float64, error) {
+	return s.TFT, nil
+}
+
+// PricingPolicy returns a pricing policy from the snapshot
+func (s PricingSnapshot) PricingPolicy(policyID uint32) (PricingPolicy, error) {
+	policy, ok := s.PricingPolicies[policyID]
+	if !ok {
+		return PricingPolicy{}, errors.Errorf("pricing policy %d is not in the pricing snapshot", policyID)
+	}
+
+	return policy, nil
+}
+
+// DiscountPackages returns the snapshot discount packages
+func (s PricingSnapshot) DiscountPackages() (map[string]DiscountPackage, error) {
+	return s.Discounts, nil
+}
@@ -0,0 +1,84 @@
+package calculator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+)
+
+const pricingSnapshot = `{
+	"tft_price": 0.001,
+	"pricing_policies": {
+		"1": {"id": 1, "cu": 2, "su": 2, "ipu": 2}
+	}
+}`
+
+func TestPricingSnapshot(t *testing.T) {
+	t.Run("offline calculator", func(t *testing.T) {
+		snapshot, err := ParsePricingSnapshot([]byte(pricingSnapshot))
+		require.NoError(t, err)
+		assert.Equal(t, DefaultDiscountPackages, snapshot.Discounts)
+
+		calculator := NewCalculatorWithPricingSource(snapshot, nil, nil, nil)
+
+		cost, err := calculator.CalculateCost(8, 32, 0, 50, true, true)
+		require.NoError(t, err)
+		assert.Equal(t, 16.2, cost)
+
+		// the discount needs the account balance
+		_, _, err = calculator.CalculateDiscount(cost)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing pricing policy", func(t *testing.T) {
+		calculator := NewCalculatorWithPricingSource(PricingSnapshot{TFT: 1}, nil, nil, nil)
+
+		_, err := calculator.CalculateCost(1, 1, 1, 1, false, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid snapshot", func(t *testing.T) {
+		_, err := ParsePricingSnapshot([]byte(`{"tft_price": 0}`))
+		assert.Error(t, err)
+
+		_, err = ParsePricingSnapshot([]byte(`{`))
+		assert.Error(t, err)
+	})
+
+	t.Run("snapshot from chain", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		sub := mocks.NewMockSubstrateExt(ctrl)
+		sub.EXPECT().GetTFTPrice().Return(types.U32(20), nil)
+		sub.EXPECT().GetPricingPolicy(uint32(1)).Return(substrate.PricingPolicy{
+			ID:                     1,
+			CU:                     substrate.Policy{Value: 100000},
+			SU:                     substrate.Policy{Value: 50000},
+			DedicatedNodesDiscount: 50,
+		}, nil)
+
+		snapshot, err := NewPricingSnapshot(NewChainPricingSource(sub), 1)
+		require.NoError(t, err)
+		assert.Equal(t, 0.02, snapshot.TFT)
+		assert.Equal(t, PricingPolicy{ID: 1, CU: 100000, SU: 50000, DedicatedNodesDiscount: 50}, snapshot.PricingPolicies[1])
+
+		data, err := json.Marshal(snapshot)
+		require.NoError(t, err)
+
+		path := filepath.Join(t.TempDir(), "pricing.json")
+		require.NoError(t, os.WriteFile(path, data, 0644))
+
+		loaded, err := LoadPricingSnapshot(path)
+		require.NoError(t, err)
+		assert.Equal(t, snapshot, loaded)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +15,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/calculator"
 	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
@@ -50,6 +52,13 @@ type Deployer struct {
 	ncPool          client.NodeClientGetter
 	revertOnFailure bool
 	substrateConn   subi.SubstrateExt
+	eventSink       EventSink
+	// workers is the number of nodes deployed concurrently
+	workers int
+	// maxMonthlyCost is the max estimated monthly cost in USD of deployments, zero means no limit
+	maxMonthlyCost float64
+	// replaceDataLoss allows replacing contracts of deployments with data workloads
+	replaceDataLoss bool
 }
 
 // NewDeployer returns a new deployer
@@ -64,10 +73,15 @@ func NewDeployer(
 		tfPluginClient.NcPool,
 		revertOnFailure,
 		tfPluginClient.SubstrateConn,
+		tfPluginClient.eventSink,
+		tfPluginClient.deployWorkers,
+		tfPluginClient.maxMonthlyCost,
+		tfPluginClient.replaceDataLoss,
 	}
 }
 
-// Deploy deploys or updates a new deployment given the old deployments' IDs
+// Deploy deploys or updates a new deployment given the old deployments' IDs,
+// if revertOnFailure is set a failed deployment is rolled back and a *RollbackError is returned
 func (d *Deployer) Deploy(ctx context.Context,
 	oldDeploymentIDs map[uint32]uint64,
 	newDeployments map[uint32]zos.Deployment,
@@ -83,6 +97,14 @@ func (d *Deployer) Deploy(ctx context.Context,
 		}
 	}
 
+	var nodes []calculator.NodeWorkloads
+	for node, dl := range newDeployments {
+		nodes = append(nodes, calculator.NodeWorkloads{NodeID: node, Workloads: dl.Workloads})
+	}
+	if err := d.checkMaxMonthlyCost(ctx, nodes...); err != nil {
+		return oldDeploymentIDs, err
+	}
+
 	// ignore oldErr until we need oldDeployments
 	currentDeployments, err := d.deploy(ctx, oldDeploymentIDs, newDeployments, newDeploymentSolutionProvider, d.revertOnFailure)
 
@@ -91,11 +113,12 @@ func (d *Deployer) Deploy(ctx context.Context,
 			return currentDeployments, errors.Wrapf(err, "failed to fetch deployment objects to revert deployments: %s; try again", oldErr)
 		}
 
-		currentDls, rerr := d.deploy(ctx, currentDeployments, oldDeployments, newDeploymentSolutionProvider, false)
+		currentDls, report, rerr := d.rollback(ctx, oldDeploymentIDs, currentDeployments, oldDeployments, newDeploymentSolutionProvider)
 		if rerr != nil {
 			return currentDls, errors.Wrapf(err, "failed to revert deployments: %s; try again", rerr)
 		}
-		return currentDls, err
+		d.emit(Event{Type: EventRollback, Err: err, Rollback: &report})
+		return currentDls, &RollbackError{Err: err, Report: report}
 	}
 
 	return currentDeployments, err
@@ -119,143 +142,359 @@ func (d *Deployer) deploy(
 			if err != nil && !strings.Contains(err.Error(), "ContractNotExists") {
 				return currentDeployments, errors.Wrap(err, "failed to delete deployment")
 			}
+			d.emit(Event{Type: EventContractCanceled, NodeID: node, ContractID: contractID})
 			delete(currentDeployments, node)
 		}
 	}
 
-	// creations
-	for node, dl := range newDeployments {
-		if _, ok := oldDeployments[node]; !ok {
-			client, err := d.ncPool.GetNodeClient(d.substrateConn, node)
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "failed to get node client")
-			}
+	// creations and updates are applied on the nodes concurrently, creations are done first
+	// so new nodes (e.g. new network nodes) exist before the old nodes are updated
+	var mu sync.Mutex
+	storeContract := func(node uint32, contractID uint64) {
+		mu.Lock()
+		defer mu.Unlock()
+		currentDeployments[node] = contractID
+	}
 
-			if err := dl.Sign(d.twinID, d.identity); err != nil {
-				return currentDeployments, errors.Wrap(err, "error signing deployment")
-			}
+	var creations, updates []uint32
+	for node := range newDeployments {
+		if _, ok := oldDeployments[node]; ok {
+			updates = append(updates, node)
+		} else {
+			creations = append(creations, node)
+		}
+	}
 
-			if err := dl.Valid(); err != nil {
-				return currentDeployments, errors.Wrap(err, "deployment is invalid")
-			}
+	err = d.forEachNode(creations, func(node uint32) error {
+		contractID, err := d.createDeployment(ctx, node, newDeployments[node], newDeploymentSolutionProvider[node])
+		if contractID != 0 {
+			storeContract(node, contractID)
+		}
+		return err
+	})
+	if err != nil {
+		return currentDeployments, err
+	}
 
-			hash, err := dl.ChallengeHash()
-			log.Debug().Bytes("HASH", hash)
+	err = d.forEachNode(updates, func(node uint32) error {
+		contractID, err := d.applyDeploymentUpdate(ctx, node, oldDeployments[node], newDeployments[node], newDeploymentSolutionProvider[node])
+		if contractID != 0 {
+			storeContract(node, contractID)
+		}
+		return err
+	})
 
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "failed to create hash")
-			}
+	return currentDeployments, err
+}
 
-			hashHex := hex.EncodeToString(hash)
+// forEachNode runs fn for the given nodes using the deployer workers, all nodes are processed
+// even if some of them failed and their errors are aggregated
+func (d *Deployer) forEachNode(nodes []uint32, fn func(node uint32) error) error {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
 
-			publicIPCount, err := CountDeploymentPublicIPs(dl)
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "failed to count deployment public IPs")
-			}
-			log.Debug().Uint32("Number of public ips", publicIPCount)
+	workers := d.workers
+	if workers <= 0 {
+		workers = 1
+	}
 
-			contractID, err := d.substrateConn.CreateNodeContract(d.identity, node, dl.Metadata, hashHex, publicIPCount, newDeploymentSolutionProvider[node])
-			log.Debug().Uint64("CreateNodeContract returned id", contractID)
-			if err != nil {
-				return currentDeployments, errors.Wrapf(err, "failed to create contract on node %d", node)
+	var mu sync.Mutex
+	var errs []error
+
+	var group errgroup.Group
+	group.SetLimit(workers)
+	for _, node := range nodes {
+		node := node
+		group.Go(func() error {
+			if err := fn(node); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
 			}
+			return nil
+		})
+	}
+	_ = group.Wait()
 
-			dl.ContractID = contractID
-			err = client.DeploymentDeploy(ctx, dl)
-			if err != nil {
-				rerr := d.substrateConn.EnsureContractCanceled(d.identity, contractID)
-				if rerr != nil {
-					return currentDeployments, errors.Wrapf(err, "error cancelling contract: %s; you must cancel it manually (id: %d)", rerr, contractID)
-				}
-				return currentDeployments, errors.Wrapf(err, "error sending deployment to node %d", node)
+	if len(errs) == 1 {
+		return errs[0]
+	}
 
-			}
-			currentDeployments[node] = dl.ContractID
-			newWorkloadVersions := make(map[string]uint32)
-			for _, w := range dl.Workloads {
-				newWorkloadVersions[w.Name] = 0
-			}
-			err = d.Wait(ctx, client, dl.ContractID, newWorkloadVersions)
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "error waiting deployment")
-			}
+	var multiErr error
+	for _, err := range errs {
+		multiErr = multierror.Append(multiErr, err)
+	}
+	return multiErr
+}
+
+// createDeployment creates the node contract of a new deployment, sends it to the node and waits for it,
+// it returns the created contract ID which is 0 if the contract was not created or was canceled
+func (d *Deployer) createDeployment(ctx context.Context, node uint32, dl zos.Deployment, solutionProvider *uint64) (uint64, error) {
+	client, err := d.ncPool.GetNodeClient(d.substrateConn, node)
+	if err != nil {
+		return 0, &NodeError{NodeID: node, Err: errors.Wrap(err, "failed to get node client")}
+	}
+
+	if err := dl.Sign(d.twinID, d.identity); err != nil {
+		return 0, errors.Wrap(err, "error signing deployment")
+	}
+
+	if err := dl.Valid(); err != nil {
+		return 0, errors.Wrap(err, "deployment is invalid")
+	}
+
+	hash, err := dl.ChallengeHash()
+	log.Debug().Bytes("HASH", hash)
+
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create hash")
+	}
+
+	hashHex := hex.EncodeToString(hash)
+
+	publicIPCount, err := CountDeploymentPublicIPs(dl)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count deployment public IPs")
+	}
+	log.Debug().Uint32("Number of public ips", publicIPCount)
+
+	contractID, err := d.substrateConn.CreateNodeContract(d.identity, node, dl.Metadata, hashHex, publicIPCount, solutionProvider)
+	log.Debug().Uint64("CreateNodeContract returned id", contractID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to create contract on node %d", node)
+	}
+	d.emit(Event{Type: EventContractCreated, NodeID: node, ContractID: contractID})
+
+	dl.ContractID = contractID
+	err = client.DeploymentDeploy(ctx, dl)
+	if err != nil {
+		rerr := d.substrateConn.EnsureContractCanceled(d.identity, contractID)
+		if rerr != nil {
+			return 0, errors.Wrapf(err, "error cancelling contract: %s; you must cancel it manually (id: %d)", rerr, contractID)
 		}
+		d.emit(Event{Type: EventContractCanceled, NodeID: node, ContractID: contractID})
+		return 0, &NodeError{NodeID: node, Err: errors.Wrapf(err, "error sending deployment to node %d", node)}
+
 	}
+	d.emit(Event{Type: EventDeploymentSent, NodeID: node, ContractID: contractID})
 
-	// updates
-	for node, dl := range newDeployments {
-		if oldDeploymentID, ok := oldDeployments[node]; ok {
-			client, err := d.ncPool.GetNodeClient(d.substrateConn, node)
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "failed to get node client")
-			}
+	newWorkloadVersions := make(map[string]uint32)
+	for _, w := range dl.Workloads {
+		newWorkloadVersions[w.Name] = 0
+	}
+	err = d.Wait(ctx, client, dl.ContractID, newWorkloadVersions)
+	if err != nil {
+		return contractID, &NodeError{NodeID: node, Err: errors.Wrap(err, "error waiting deployment")}
+	}
 
-			oldDl, err := client.DeploymentGet(ctx, oldDeploymentID)
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "failed to get old deployment to update it")
-			}
+	return contractID, nil
+}
 
-			matchOldVersions(&oldDl, &dl)
+// applyDeploymentUpdate updates the deployment of a node if it changed,
+// it returns the new contract ID if the deployment was moved to a new contract
+func (d *Deployer) applyDeploymentUpdate(ctx context.Context, node uint32, oldDeploymentID uint64, dl zos.Deployment, solutionProvider *uint64) (uint64, error) {
+	client, err := d.ncPool.GetNodeClient(d.substrateConn, node)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get node client")
+	}
 
-			oldDeploymentHash, err := HashDeployment(oldDl)
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "could not get deployment hash")
-			}
+	oldDl, err := client.DeploymentGet(ctx, oldDeploymentID)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get old deployment to update it")
+	}
 
-			newDeploymentHash, err := HashDeployment(dl)
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "could not get deployment hash")
-			}
+	matchOldVersions(&oldDl, &dl)
 
-			if oldDeploymentHash == newDeploymentHash && SameWorkloadsNames(dl, oldDl) {
-				continue
-			}
+	oldDeploymentHash, err := HashDeployment(oldDl)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not get deployment hash")
+	}
 
-			newWorkloadsVersions, err := assignVersions(&oldDl, &dl)
-			if err != nil {
-				return currentDeployments, errors.Wrapf(err, "failed to assign new versions to deployment with contract %d", oldDeploymentID)
-			}
+	newDeploymentHash, err := HashDeployment(dl)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not get deployment hash")
+	}
 
-			dl.ContractID = oldDl.ContractID
+	if oldDeploymentHash == newDeploymentHash && SameWorkloadsNames(dl, oldDl) {
+		return 0, nil
+	}
 
-			if err := dl.Sign(d.twinID, d.identity); err != nil {
-				return currentDeployments, errors.Wrap(err, "error signing deployment")
-			}
+	oldPublicIPCount, err := CountDeploymentPublicIPs(oldDl)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count old deployment public IPs")
+	}
 
-			if err := dl.Valid(); err != nil {
-				return currentDeployments, errors.Wrap(err, "deployment is invalid")
-			}
+	publicIPCount, err := CountDeploymentPublicIPs(dl)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count deployment public IPs")
+	}
 
-			log.Debug().Interface("deployment", dl)
-			hash, err := dl.ChallengeHash()
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "failed to create hash")
-			}
-			hashHex := hex.EncodeToString(hash)
-			log.Debug().Str("HASH", hashHex)
+	// the reserved public IPs of a contract can't be changed, so the deployment is moved to a new contract
+	if oldPublicIPCount != publicIPCount {
+		return d.replaceDeployment(ctx, client, node, oldDl, dl, publicIPCount, solutionProvider)
+	}
 
-			// TODO: Destroy and create if publicIPCount is changed
-			// publicIPCount, err := countDeploymentPublicIPs(dl)
-			contractID, err := d.substrateConn.UpdateNodeContract(d.identity, dl.ContractID, dl.Metadata, hashHex)
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "failed to update deployment")
-			}
-			dl.ContractID = contractID
-			err = client.DeploymentUpdate(ctx, dl)
-			if err != nil {
-				// cancel previous contract
-				return currentDeployments, errors.Wrapf(err, "failed to send deployment update request to node %d", node)
-			}
-			currentDeployments[node] = dl.ContractID
+	return 0, d.updateDeployment(ctx, client, node, oldDl, dl, nil)
+}
 
-			err = d.Wait(ctx, client, dl.ContractID, newWorkloadsVersions)
-			if err != nil {
-				return currentDeployments, errors.Wrap(err, "error waiting deployment")
-			}
+// updateDeployment updates a node deployment in place, workloads with changed hashes or given in
+// redeployWorkloads get the new deployment version so the node provisions them again
+func (d *Deployer) updateDeployment(
+	ctx context.Context,
+	nodeClient *client.NodeClient,
+	node uint32,
+	oldDl zos.Deployment,
+	dl zos.Deployment,
+	redeployWorkloads map[string]bool,
+) error {
+	newWorkloadsVersions, err := assignVersions(&oldDl, &dl)
+	if err != nil {
+		return errors.Wrapf(err, "failed to assign new versions to deployment with contract %d", oldDl.ContractID)
+	}
+
+	for idx, w := range dl.Workloads {
+		if redeployWorkloads[w.Name] {
+			dl.Workloads[idx].Version = dl.Version
+			newWorkloadsVersions[w.Name] = dl.Version
+		}
+	}
+
+	dl.ContractID = oldDl.ContractID
+
+	if err := dl.Sign(d.twinID, d.identity); err != nil {
+		return errors.Wrap(err, "error signing deployment")
+	}
+
+	if err := dl.Valid(); err != nil {
+		return errors.Wrap(err, "deployment is invalid")
+	}
+
+	log.Debug().Interface("deployment", dl)
+	hash, err := dl.ChallengeHash()
+	if err != nil {
+		return errors.Wrap(err, "failed to create hash")
+	}
+	hashHex := hex.EncodeToString(hash)
+	log.Debug().Str("HASH", hashHex)
+
+	contractID, err := d.substrateConn.UpdateNodeContract(d.identity, dl.ContractID, dl.Metadata, hashHex)
+	if err != nil {
+		return errors.Wrap(err, "failed to update deployment")
+	}
+	d.emit(Event{Type: EventContractUpdated, NodeID: node, ContractID: contractID})
+
+	dl.ContractID = contractID
+	err = nodeClient.DeploymentUpdate(ctx, dl)
+	if err != nil {
+		return errors.Wrapf(err, "failed to send deployment update request to node %d", node)
+	}
+	d.emit(Event{Type: EventDeploymentSent, NodeID: node, ContractID: contractID})
+
+	err = d.Wait(ctx, nodeClient, dl.ContractID, newWorkloadsVersions)
+	if err != nil {
+		return errors.Wrap(err, "error waiting deployment")
+	}
+
+	return nil
+}
+
+// ErrReplaceDataLoss is returned if replacing a deployment contract would lose the data of its workloads
+var ErrReplaceDataLoss = errors.New("replacing the deployment contract loses the data of its disks, volumes and zdbs")
+
+// replaceDeployment moves a node deployment to a new contract reserving the new public IPs count.
+// the new deployment is created and ready before the old contract is canceled, workloads are
+// created from scratch so the data of the old disks, volumes and zdbs is not carried over and
+// the replacement is refused unless the deployer allows it.
+// it returns the new contract ID, or zero if the old deployment is still in place
+func (d *Deployer) replaceDeployment(
+	ctx context.Context,
+	nodeClient *client.NodeClient,
+	node uint32,
+	oldDl zos.Deployment,
+	dl zos.Deployment,
+	publicIPCount uint32,
+	solutionProviderID *uint64,
+) (uint64, error) {
+	if lost := lostDataWorkloads(oldDl, dl); len(lost) != 0 {
+		if !d.replaceDataLoss {
+			return 0, errors.Wrapf(ErrReplaceDataLoss, "workloads %v of contract %d on node %d", lost, oldDl.ContractID, node)
+		}
+		log.Warn().Msgf("data of workloads %v on node %d is not carried over to the new contract", lost, node)
+	}
+
+	dl.ContractID = 0
+	dl.Version = 0
+	newWorkloadsVersions := make(map[string]uint32)
+	for idx, w := range dl.Workloads {
+		dl.Workloads[idx].Version = 0
+		newWorkloadsVersions[w.Name] = 0
+	}
+
+	if err := dl.Sign(d.twinID, d.identity); err != nil {
+		return 0, errors.Wrap(err, "error signing deployment")
+	}
+
+	if err := dl.Valid(); err != nil {
+		return 0, errors.Wrap(err, "deployment is invalid")
+	}
+
+	hash, err := dl.ChallengeHash()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create hash")
+	}
+	hashHex := hex.EncodeToString(hash)
+
+	contractID, err := d.substrateConn.CreateNodeContract(d.identity, node, dl.Metadata, hashHex, publicIPCount, solutionProviderID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to create contract to replace contract %d on node %d", oldDl.ContractID, node)
+	}
+	log.Debug().Uint64("old contract", oldDl.ContractID).Uint64("new contract", contractID).Msg("replacing deployment")
+	d.emit(Event{Type: EventContractCreated, NodeID: node, ContractID: contractID})
+
+	dl.ContractID = contractID
+	err = nodeClient.DeploymentDeploy(ctx, dl)
+	if err == nil {
+		d.emit(Event{Type: EventDeploymentSent, NodeID: node, ContractID: contractID})
+		err = d.Wait(ctx, nodeClient, dl.ContractID, newWorkloadsVersions)
+	}
+	if err != nil {
+		rerr := d.substrateConn.EnsureContractCanceled(d.identity, contractID)
+		if rerr != nil {
+			return 0, errors.Wrapf(err, "error cancelling contract: %s; you must cancel it manually (id: %d)", rerr, contractID)
 		}
+		d.emit(Event{Type: EventContractCanceled, NodeID: node, ContractID: contractID})
+		return 0, errors.Wrapf(err, "failed to replace deployment with contract %d on node %d", oldDl.ContractID, node)
 	}
 
-	return currentDeployments, nil
+	err = d.substrateConn.EnsureContractCanceled(d.identity, oldDl.ContractID)
+	if err != nil && !strings.Contains(err.Error(), "ContractNotExists") {
+		return contractID, errors.Wrapf(err, "failed to cancel replaced contract; you must cancel it manually (id: %d)", oldDl.ContractID)
+	}
+	d.emit(Event{Type: EventContractCanceled, NodeID: node, ContractID: oldDl.ContractID})
+
+	return contractID, nil
+}
+
+// lostDataWorkloads returns the disks, volumes and zdbs of the old deployment kept in the new one,
+// their data is lost if the deployment is moved to a new contract
+func lostDataWorkloads(oldDl zos.Deployment, dl zos.Deployment) []string {
+	kept := make(map[string]bool)
+	for _, wl := range dl.Workloads {
+		kept[wl.Name] = true
+	}
+
+	var lost []string
+	for _, wl := range oldDl.Workloads {
+		if !kept[wl.Name] {
+			continue
+		}
+
+		if wl.Type == zos.ZMountType || wl.Type == zos.VolumeType || wl.Type == zos.ZDBType {
+			lost = append(lost, wl.Name)
+		}
+	}
+	sort.Strings(lost)
+
+	return lost
 }
 
 // Cancel cancels an old deployment not given in the new deployments
@@ -266,6 +505,7 @@ func (d *Deployer) Cancel(ctx context.Context,
 	if err != nil {
 		return errors.Wrapf(err, "failed to delete deployment: %d", contractID)
 	}
+	d.emit(Event{Type: EventContractCanceled, ContractID: contractID})
 
 	return nil
 }
@@ -315,8 +555,10 @@ func (d *Deployer) Wait(
 ) error {
 	lastProgress := Progress{time.Now(), 0}
 	numberOfWorkloads := len(workloadVersions)
+	workloadStates := make(map[string]zos.ResultState)
+	attempt := 0
 
-	deploymentError := backoff.Retry(func() error {
+	deploymentError := backoff.RetryNotify(func() error {
 		stateOk := 0
 
 		deploymentChanges, err := nodeClient.DeploymentChanges(ctx, deploymentID)
@@ -326,6 +568,11 @@ func (d *Deployer) Wait(
 
 		for _, wl := range deploymentChanges {
 			if _, ok := workloadVersions[wl.Name]; ok && wl.Version == workloadVersions[wl.Name] {
+				if state, ok := workloadStates[wl.Name]; !ok || state != wl.Result.State {
+					workloadStates[wl.Name] = wl.Result.State
+					d.emit(Event{Type: EventWorkloadStateChanged, ContractID: deploymentID, Workload: wl.Name, State: wl.Result.State})
+				}
+
 				var errString string
 				switch wl.Result.State {
 				case zos.StateOk:
@@ -359,17 +606,32 @@ func (d *Deployer) Wait(
 
 		return errors.New("deployment in progress")
 	},
-		backoff.WithContext(getExponentialBackoff(3*time.Second, 1.25, 40*time.Second, 50*time.Minute), ctx))
+		backoff.WithContext(getExponentialBackoff(3*time.Second, 1.25, 40*time.Second, 50*time.Minute), ctx),
+		func(err error, _ time.Duration) {
+			attempt++
+			d.emit(Event{Type: EventRetry, ContractID: deploymentID, Attempt: attempt, Err: err})
+		})
 
 	return deploymentError
 }
 
-// BatchDeploy deploys a batch of deployments, successful deployments should have ContractID fields set
+// BatchDeploy deploys a batch of deployments, successful deployments should have ContractID fields set,
+// if the deployer reverts on failure a failure cancels all the created contracts and a *RollbackError is returned
 func (d *Deployer) BatchDeploy(
 	ctx context.Context,
 	deployments map[uint32][]zos.Deployment,
 	deploymentsSolutionProvider map[uint32][]*uint64,
 ) (map[uint32][]zos.Deployment, error) {
+	var nodes []calculator.NodeWorkloads
+	for node, dls := range deployments {
+		for _, dl := range dls {
+			nodes = append(nodes, calculator.NodeWorkloads{NodeID: node, Workloads: dl.Workloads})
+		}
+	}
+	if err := d.checkMaxMonthlyCost(ctx, nodes...); err != nil {
+		return map[uint32][]zos.Deployment{}, err
+	}
+
 	deploymentsSlice := make([]zos.Deployment, 0)
 	contractsData := make([]substrate.BatchCreateContractData, 0)
 
@@ -450,6 +712,13 @@ func (d *Deployer) BatchDeploy(
 		multiErr = multierror.Append(multiErr, err)
 	}
 
+	for i, contractID := range contracts {
+		if index != nil && *index == i {
+			break
+		}
+		d.emit(Event{Type: EventContractCreated, NodeID: contractsData[i].Node, ContractID: contractID})
+	}
+
 	failedContracts := make([]uint64, 0)
 	var wg sync.WaitGroup
 	for i, dl := range deploymentsSlice {
@@ -466,7 +735,7 @@ func (d *Deployer) BatchDeploy(
 			if err != nil {
 				mu.Lock()
 				multiErr = multierror.Append(multiErr, errors.Wrapf(err, "failed to get node %d client", node))
-				failedContracts = append(failedContracts, dl.ContractID)
+				failedContracts = append(failedContracts, contracts[i])
 				mu.Unlock()
 				return
 			}
@@ -480,6 +749,8 @@ func (d *Deployer) BatchDeploy(
 				mu.Unlock()
 				return
 			}
+			d.emit(Event{Type: EventDeploymentSent, NodeID: node, ContractID: dl.ContractID})
+
 			newWorkloadVersions := make(map[string]uint32)
 			for _, w := range dl.Workloads {
 				newWorkloadVersions[w.Name] = 0
@@ -497,6 +768,17 @@ func (d *Deployer) BatchDeploy(
 	}
 	wg.Wait()
 
+	if multiErr != nil && d.revertOnFailure {
+		report, err := d.rollbackBatch(deploymentsSlice, failedContracts)
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		} else {
+			d.emit(Event{Type: EventRollback, Err: multiErr, Rollback: &report})
+			multiErr = &RollbackError{Err: multiErr, Report: report}
+		}
+		failedContracts = nil
+	}
+
 	resDeployments := make(map[uint32][]zos.Deployment, len(deployments))
 	for i, dl := range deploymentsSlice {
 		resDeployments[contractsData[i].Node] = append(resDeployments[contractsData[i].Node], dl)
@@ -642,35 +924,37 @@ func (d *Deployer) Validate(ctx context.Context, oldDeployments map[uint32]zos.D
 		requiredIPs := int(publicIPCount)
 		nodeInfo := nodeMap[node]
 		if alreadyExists {
-			oldCap, err := Capacity(oldDl)
-			if err != nil {
-				return errors.Wrapf(err, "could not read old deployment %d of node %d capacity", oldDl.ContractID, node)
-			}
-			addCapacity(&nodeInfo.Capacity.Total, &oldCap)
 			contract, err := d.substrateConn.GetContract(oldDl.ContractID)
 			if err != nil {
 				return errors.Wrapf(err, "could not get node contract %d", oldDl.ContractID)
 			}
-			current := int(contract.PublicIPCount())
-			if requiredIPs > current {
-				return errors.Errorf(
-					"currently, it's not possible to increase the number of reserved public ips in a deployment, node: %d, current: %d, requested: %d",
-					node,
-					current,
-					requiredIPs,
-				)
+
+			// a deployment with a different public ips count is moved to a new contract,
+			// the old one keeps its ips and capacity until the new deployment is ready
+			if current := int(contract.PublicIPCount()); requiredIPs != current {
+				oldPublicIPCount, err := CountDeploymentPublicIPs(oldDl)
+				if err != nil {
+					return errors.Wrap(err, "failed to count old deployment public IPs")
+				}
+				farmIPs[nodeInfo.FarmID] -= int(oldPublicIPCount)
+			} else {
+				oldCap, err := Capacity(oldDl)
+				if err != nil {
+					return errors.Wrapf(err, "could not read old deployment %d of node %d capacity", oldDl.ContractID, node)
+				}
+				addCapacity(&nodeInfo.Capacity.Total, &oldCap)
 			}
 		}
 
 		farmIPs[nodeInfo.FarmID] -= requiredIPs
 		if farmIPs[nodeInfo.FarmID] < 0 {
-			return errors.Errorf("farm %d does not have enough public ips", nodeInfo.FarmID)
+			return &NodeError{NodeID: node, Err: errors.Errorf("farm %d does not have enough public ips", nodeInfo.FarmID)}
 		}
 		if HasWorkload(&dl, zos.GatewayFQDNProxyType) && nodeInfo.PublicConfig.Ipv4 == "" {
-			return errors.Errorf("node %d cannot deploy a fqdn workload as it does not have a public ipv4 configured", node)
+			return &NodeError{NodeID: node, Err: errors.Errorf("node %d cannot deploy a fqdn workload as it does not have a public ipv4 configured", node)}
 		}
 		if HasWorkload(&dl, zos.GatewayNameProxyType) && nodeInfo.PublicConfig.Domain == "" {
-			return errors.Errorf("node %d cannot deploy a gateway name workload as it does not have a domain configured", node)
+			return &NodeError{NodeID: node, Err: errors.Errorf("node %d cannot deploy a gateway name workload as it does not have a domain configured", node)}
 		}
 		mru := nodeInfo.Capacity.Total.MRU - nodeInfo.Capacity.Used.MRU
 		hru := nodeInfo.Capacity.Total.HRU - nodeInfo.Capacity.Used.HRU
@@ -683,7 +967,7 @@ func (d *Deployer) Validate(ctx context.Context, oldDeployments map[uint32]zos.D
 				MRU: uint64(mru),
 				SRU: uint64(sru),
 			}
-			return errors.Errorf("node %d does not have enough resources. needed: %v, free: %v", node, capacityPrettyPrint(needed), capacityPrettyPrint(free))
+			return &NodeError{NodeID: node, Err: errors.Errorf("node %d does not have enough resources. needed: %v, free: %v", node, capacityPrettyPrint(needed), capacityPrettyPrint(free))}
 		}
 	}
 	return nil
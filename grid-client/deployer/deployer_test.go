@@ -5,9 +5,12 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -519,3 +522,242 @@ func TestDeployer(t *testing.T) {
 		})
 	})
 }
+
+func TestDeployerReplaceContract(t *testing.T) {
+	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
+	require.NoError(t, err)
+	twinID := uint32(1)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cl := mocks.NewRMBMockClient(ctrl)
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	ncPool := mocks.NewMockNodeClientGetter(ctrl)
+
+	deployer := Deployer{
+		identity:      identity,
+		twinID:        twinID,
+		ncPool:        ncPool,
+		substrateConn: sub,
+	}
+
+	oldDl, err := deploymentWithNameGateway(identity, twinID, true, 1, backendURLWithTLSPassthrough)
+	require.NoError(t, err)
+	oldDl.ContractID = 100
+
+	ncPool.EXPECT().
+		GetNodeClient(sub, uint32(10)).
+		Return(client.NewNodeClient(13, cl, 10), nil).AnyTimes()
+
+	cl.EXPECT().
+		Call(gomock.Any(), uint32(13), "zos.deployment.get", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+			*result.(*zosTypes.Deployment) = oldDl
+			return nil
+		}).AnyTimes()
+
+	newDeployment := func() zosTypes.Deployment {
+		dl, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+		require.NoError(t, err)
+		dl.Workloads = append(dl.Workloads, workloads.ConstructPublicIPWorkload("ip", true, false))
+		return dl
+	}
+
+	t.Run("replace", func(t *testing.T) {
+		sub.EXPECT().
+			CreateNodeContract(identity, uint32(10), "", gomock.Any(), uint32(1), nil).
+			Return(uint64(300), nil)
+
+		var deployed zosTypes.Deployment
+		cl.EXPECT().
+			Call(gomock.Any(), uint32(13), "zos.deployment.deploy", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+				deployed = data.(zosTypes.Deployment)
+				for i := range deployed.Workloads {
+					deployed.Workloads[i].Result.State = zosTypes.StateOk
+				}
+				return nil
+			})
+
+		cl.EXPECT().
+			Call(gomock.Any(), uint32(13), "zos.deployment.changes", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+				*result.(*[]zosTypes.Workload) = deployed.Workloads
+				return nil
+			})
+
+		sub.EXPECT().EnsureContractCanceled(identity, uint64(100)).Return(nil)
+
+		contracts, err := deployer.deploy(
+			context.Background(),
+			map[uint32]uint64{10: 100},
+			map[uint32]zosTypes.Deployment{10: newDeployment()},
+			map[uint32]*uint64{10: nil},
+			false,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, map[uint32]uint64{10: 300}, contracts)
+		assert.Equal(t, uint64(300), deployed.ContractID)
+		assert.Equal(t, uint32(0), deployed.Version)
+		assert.Len(t, deployed.Workloads, 2)
+	})
+
+	t.Run("failed replace keeps old contract", func(t *testing.T) {
+		sub.EXPECT().
+			CreateNodeContract(identity, uint32(10), "", gomock.Any(), uint32(1), nil).
+			Return(uint64(400), nil)
+
+		cl.EXPECT().
+			Call(gomock.Any(), uint32(13), "zos.deployment.deploy", gomock.Any(), gomock.Any()).
+			Return(errors.New("node is busy"))
+
+		sub.EXPECT().EnsureContractCanceled(identity, uint64(400)).Return(nil)
+
+		contracts, err := deployer.deploy(
+			context.Background(),
+			map[uint32]uint64{10: 100},
+			map[uint32]zosTypes.Deployment{10: newDeployment()},
+			map[uint32]*uint64{10: nil},
+			false,
+		)
+		assert.Error(t, err)
+		assert.Equal(t, map[uint32]uint64{10: 100}, contracts)
+	})
+
+	t.Run("data loss is refused", func(t *testing.T) {
+		disk := workloads.Disk{Name: "disk", SizeGB: 1}
+
+		gatewayDl := oldDl
+		defer func() { oldDl = gatewayDl }()
+		oldDl.Workloads = append(append([]zosTypes.Workload{}, gatewayDl.Workloads...), disk.ZosWorkload())
+
+		dl := newDeployment()
+		dl.Workloads = append(dl.Workloads, disk.ZosWorkload())
+
+		contracts, err := deployer.deploy(
+			context.Background(),
+			map[uint32]uint64{10: 100},
+			map[uint32]zosTypes.Deployment{10: dl},
+			map[uint32]*uint64{10: nil},
+			false,
+		)
+		assert.ErrorIs(t, err, ErrReplaceDataLoss)
+		assert.Equal(t, map[uint32]uint64{10: 100}, contracts)
+
+		assert.Equal(t, []string{"disk"}, lostDataWorkloads(oldDl, dl))
+		// removed disks are not lost data
+		assert.Empty(t, lostDataWorkloads(oldDl, newDeployment()))
+	})
+}
+
+func TestDeployerConcurrentDeploy(t *testing.T) {
+	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
+	require.NoError(t, err)
+	twinID := uint32(1)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cl := mocks.NewRMBMockClient(ctrl)
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	ncPool := mocks.NewMockNodeClientGetter(ctrl)
+
+	d := Deployer{
+		identity:      identity,
+		twinID:        twinID,
+		ncPool:        ncPool,
+		substrateConn: sub,
+		workers:       3,
+	}
+
+	dl, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+	require.NoError(t, err)
+
+	nodes := []uint32{10, 20, 30}
+	for _, node := range nodes {
+		ncPool.EXPECT().
+			GetNodeClient(sub, node).
+			Return(client.NewNodeClient(node+3, cl, 10), nil).AnyTimes()
+	}
+
+	t.Run("nodes are deployed concurrently", func(t *testing.T) {
+		// every node waits for the deployments of all nodes to be sent, which would block sequential deployments
+		var sent sync.WaitGroup
+		sent.Add(len(nodes))
+		allSent := make(chan struct{})
+		go func() {
+			sent.Wait()
+			close(allSent)
+		}()
+
+		newDls := make(map[uint32]zosTypes.Deployment)
+		for _, node := range nodes {
+			newDls[node] = dl
+			sub.EXPECT().
+				CreateNodeContract(identity, node, "", gomock.Any(), uint32(0), nil).
+				Return(uint64(node*10), nil)
+
+			cl.EXPECT().
+				Call(gomock.Any(), node+3, "zos.deployment.deploy", gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+					sent.Done()
+					return nil
+				})
+
+			cl.EXPECT().
+				Call(gomock.Any(), node+3, "zos.deployment.changes", gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+					select {
+					case <-allSent:
+					case <-time.After(5 * time.Second):
+						return errors.New("deployments are not sent concurrently")
+					}
+
+					workloads := []zosTypes.Workload{dl.Workloads[0]}
+					workloads[0].Result.State = zosTypes.StateOk
+					*result.(*[]zosTypes.Workload) = workloads
+					return nil
+				})
+		}
+
+		contracts, err := d.deploy(context.Background(), nil, newDls, map[uint32]*uint64{}, false)
+		require.NoError(t, err)
+		assert.Equal(t, map[uint32]uint64{10: 100, 20: 200, 30: 300}, contracts)
+	})
+
+	t.Run("errors are aggregated per node", func(t *testing.T) {
+		newDls := make(map[uint32]zosTypes.Deployment)
+		for _, node := range nodes {
+			newDls[node] = dl
+		}
+
+		sub.EXPECT().
+			CreateNodeContract(identity, uint32(10), "", gomock.Any(), uint32(0), nil).
+			Return(uint64(100), nil)
+		cl.EXPECT().
+			Call(gomock.Any(), uint32(13), "zos.deployment.deploy", gomock.Any(), gomock.Any()).
+			Return(nil)
+		cl.EXPECT().
+			Call(gomock.Any(), uint32(13), "zos.deployment.changes", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+				workloads := []zosTypes.Workload{dl.Workloads[0]}
+				workloads[0].Result.State = zosTypes.StateOk
+				*result.(*[]zosTypes.Workload) = workloads
+				return nil
+			})
+
+		sub.EXPECT().
+			CreateNodeContract(identity, uint32(20), "", gomock.Any(), uint32(0), nil).
+			Return(uint64(0), errors.New("node 20 is down"))
+		sub.EXPECT().
+			CreateNodeContract(identity, uint32(30), "", gomock.Any(), uint32(0), nil).
+			Return(uint64(0), errors.New("node 30 is down"))
+
+		contracts, err := d.deploy(context.Background(), nil, newDls, map[uint32]*uint64{}, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "node 20 is down")
+		assert.Contains(t, err.Error(), "node 30 is down")
+		assert.Equal(t, map[uint32]uint64{10: 100}, contracts)
+	})
+}
@@ -4,13 +4,16 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"slices"
 	"sync"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	"golang.org/x/exp/maps"
 )
 
 // DeploymentDeployer for deploying a deployment
@@ -60,33 +63,14 @@ func (d *DeploymentDeployer) GenerateVersionlessDeployments(ctx context.Context,
 		wg.Add(1)
 		go func(dl *workloads.Deployment) {
 			defer wg.Done()
-			newDl := workloads.NewGridDeployment(d.tfPluginClient.TwinID, 0, []zos.Workload{})
-			for _, disk := range dl.Disks {
-				newDl.Workloads = append(newDl.Workloads, disk.ZosWorkload())
-			}
-			for _, volume := range dl.Volumes {
-				newDl.Workloads = append(newDl.Workloads, volume.ZosWorkload())
-			}
-			for _, zdb := range dl.Zdbs {
-				newDl.Workloads = append(newDl.Workloads, zdb.ZosWorkload())
-			}
-			for _, vm := range dl.Vms {
-				newDl.Workloads = append(newDl.Workloads, vm.ZosWorkload()...)
-			}
-			for _, vm := range dl.VmsLight {
-				newDl.Workloads = append(newDl.Workloads, vm.ZosWorkload()...)
-			}
-
-			for idx, q := range dl.QSFS {
-				qsfsWorkload, err := q.ZosWorkload()
-				if err != nil {
-					mu.Lock()
-					defer mu.Unlock()
-					errs = multierror.Append(errs, errors.Wrapf(err, "failed to generate QSFS %d in deployment '%s'", idx, dl.Name))
-					return
-				}
-				newDl.Workloads = append(newDl.Workloads, qsfsWorkload)
+			zosWorkloads, err := dl.ZosWorkloads()
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = multierror.Append(errs, errors.Wrapf(err, "failed to generate workloads of deployment '%s'", dl.Name))
+				return
 			}
+			newDl := workloads.NewGridDeployment(d.tfPluginClient.TwinID, 0, zosWorkloads)
 
 			mu.Lock()
 			defer mu.Unlock()
@@ -119,6 +103,7 @@ func (d *DeploymentDeployer) Deploy(ctx context.Context, dl *workloads.Deploymen
 		return fmt.Errorf("failed to generate the grid deployment")
 	}
 
+	oldContractID := dl.NodeDeploymentID[dl.NodeID]
 	dl.NodeDeploymentID, err = d.deployer.Deploy(
 		ctx, dl.NodeDeploymentID,
 		map[uint32]zos.Deployment{dl.NodeID: dlsPerNodes[dl.NodeID][0]},
@@ -128,13 +113,86 @@ func (d *DeploymentDeployer) Deploy(ctx context.Context, dl *workloads.Deploymen
 	// update deployment and plugin state
 	// error is not returned immediately before updating state because of untracked failed deployments
 	if contractID, ok := dl.NodeDeploymentID[dl.NodeID]; ok && contractID != 0 {
+		// the deployment is moved to a new contract if its public ips changed
+		if oldContractID != 0 && oldContractID != contractID {
+			d.tfPluginClient.State.RemoveContractIDs(dl.NodeID, oldContractID)
+		}
 		dl.ContractID = contractID
 		d.tfPluginClient.State.StoreContractIDs(dl.NodeID, dl.ContractID)
+		d.updateEmbeddedNetworkState(dl)
 	}
 
 	return err
 }
 
+// Plan returns the changes deploying the deployment would apply without deploying it
+func (d *DeploymentDeployer) Plan(ctx context.Context, dl *workloads.Deployment) (Plan, error) {
+	if err := d.Validate(ctx, []*workloads.Deployment{dl}); err != nil {
+		return Plan{}, fmt.Errorf("invalid deployment: %w", err)
+	}
+
+	dlsPerNodes, err := d.GenerateVersionlessDeployments(ctx, []*workloads.Deployment{dl})
+	if err != nil {
+		return Plan{}, errors.Wrap(err, "could not generate deployments data")
+	}
+
+	if len(dlsPerNodes[dl.NodeID]) == 0 {
+		return Plan{}, fmt.Errorf("failed to generate the grid deployment")
+	}
+
+	deployer := NewDeployer(*d.tfPluginClient, true)
+	return deployer.Plan(ctx, dl.NodeDeploymentID, map[uint32]zos.Deployment{dl.NodeID: dlsPerNodes[dl.NodeID][0]})
+}
+
+// Reconcile reports the drift between the deployment and the one deployed on its node,
+// if reapply is set the deployment is deployed again to fix the drift
+func (d *DeploymentDeployer) Reconcile(ctx context.Context, dl *workloads.Deployment, reapply bool) (DriftReport, error) {
+	if err := d.Validate(ctx, []*workloads.Deployment{dl}); err != nil {
+		return DriftReport{}, fmt.Errorf("invalid deployment: %w", err)
+	}
+
+	dlsPerNodes, err := d.GenerateVersionlessDeployments(ctx, []*workloads.Deployment{dl})
+	if err != nil {
+		return DriftReport{}, errors.Wrap(err, "could not generate deployments data")
+	}
+
+	if len(dlsPerNodes[dl.NodeID]) == 0 {
+		return DriftReport{}, fmt.Errorf("failed to generate the grid deployment")
+	}
+
+	oldDeploymentIDs := maps.Clone(dl.NodeDeploymentID)
+	deployer := NewDeployer(*d.tfPluginClient, true)
+	currentDeploymentIDs, report, err := deployer.Reconcile(
+		ctx, dl.NodeDeploymentID,
+		map[uint32]zos.Deployment{dl.NodeID: dlsPerNodes[dl.NodeID][0]},
+		map[uint32]*uint64{dl.NodeID: dl.SolutionProvider},
+		reapply,
+	)
+
+	if reapply {
+		dl.NodeDeploymentID = currentDeploymentIDs
+		dl.ContractID = currentDeploymentIDs[dl.NodeID]
+		updateStateContracts(d.tfPluginClient.State, oldDeploymentIDs, currentDeploymentIDs)
+	}
+
+	return report, err
+}
+
+// BatchPlan returns the changes deploying multiple deployments would apply without deploying them
+func (d *DeploymentDeployer) BatchPlan(ctx context.Context, dls []*workloads.Deployment) (Plan, error) {
+	if err := d.Validate(ctx, dls); err != nil {
+		return Plan{}, fmt.Errorf("invalid deployments: %w", err)
+	}
+
+	newDeployments, err := d.GenerateVersionlessDeployments(ctx, dls)
+	if err != nil {
+		return Plan{}, fmt.Errorf("could not generate grid deployments: %w", err)
+	}
+
+	deployer := NewDeployer(*d.tfPluginClient, true)
+	return deployer.BatchPlan(ctx, newDeployments)
+}
+
 // BatchDeploy deploys multiple deployments using the deployer
 func (d *DeploymentDeployer) BatchDeploy(ctx context.Context, dls []*workloads.Deployment) error {
 	newDeploymentsSolutionProvider := make(map[uint32][]*uint64)
@@ -185,6 +243,10 @@ func (d *DeploymentDeployer) Cancel(ctx context.Context, dl *workloads.Deploymen
 	d.tfPluginClient.State.RemoveContractIDs(dl.NodeID, dl.ContractID)
 	dl.ContractID = 0
 
+	if network, ok := dl.Network(); ok {
+		d.tfPluginClient.State.DeleteNetwork(network.GetName())
+	}
+
 	return nil
 }
 
@@ -202,6 +264,7 @@ func (d *DeploymentDeployer) updateStateFromDeployments(dl *workloads.Deployment
 				dl.NodeDeploymentID[dl.NodeID] = newDl.ContractID
 				dl.ContractID = newDl.ContractID
 				d.tfPluginClient.State.StoreContractIDs(dl.NodeID, dl.ContractID)
+				d.updateEmbeddedNetworkState(dl)
 			}
 		}
 	}
@@ -251,46 +314,60 @@ func (d *DeploymentDeployer) getUsedHostIDsOfNodeWithinNetwork(ctx context.Conte
 }
 
 func (d *DeploymentDeployer) calculateNetworksUsedIPs(ctx context.Context, dls []*workloads.Deployment) (map[string]map[uint32][]byte, error) {
-	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var errs error
 	usedHosts := make(map[string]map[uint32][]byte)
 
-	// calculate used host IDs per network
+	// networks used on each node
+	var nodes []uint32
+	nodeNetworks := make(map[uint32][]string)
 	for _, dl := range dls {
 		if len(dl.Vms) == 0 && len(dl.VmsLight) == 0 {
 			continue
 		}
 
-		mu.Lock()
+		// networks deployed within the deployment are only used by its vms
+		if _, ok := dl.Network(); ok {
+			continue
+		}
+
 		if _, ok := usedHosts[dl.NetworkName]; !ok {
 			usedHosts[dl.NetworkName] = make(map[uint32][]byte)
 		}
 
-		if _, ok := usedHosts[dl.NetworkName][dl.NodeID]; ok {
-			mu.Unlock()
+		if slices.Contains(nodeNetworks[dl.NodeID], dl.NetworkName) {
 			continue
 		}
-		mu.Unlock()
 
-		wg.Add(1)
-		go func(dl *workloads.Deployment) {
-			defer wg.Done()
-			usedHostIDs, err := d.getUsedHostIDsOfNodeWithinNetwork(ctx, dl.NodeID, dl.NetworkName)
+		if _, ok := nodeNetworks[dl.NodeID]; !ok {
+			nodes = append(nodes, dl.NodeID)
+		}
+		nodeNetworks[dl.NodeID] = append(nodeNetworks[dl.NodeID], dl.NetworkName)
+	}
+
+	// calculate used host IDs per network
+	nodesErrs := client.ForEachNode(ctx, d.tfPluginClient.NcPool, d.tfPluginClient.SubstrateConn, nodes, client.FanOutOptions{}, func(ctx context.Context, nodeID uint32, _ *client.NodeClient) error {
+		var nodeErrs error
+		for _, networkName := range nodeNetworks[nodeID] {
+			usedHostIDs, err := d.getUsedHostIDsOfNodeWithinNetwork(ctx, nodeID, networkName)
 			if err != nil {
-				mu.Lock()
-				defer mu.Unlock()
-				errs = multierror.Append(errs, errors.Wrapf(err, "failed to get used host ids for network %s node %d", dl.NetworkName, dl.NodeID))
-				return
+				nodeErrs = multierror.Append(nodeErrs, errors.Wrapf(err, "failed to get used host ids for network %s node %d", networkName, nodeID))
+				continue
 			}
 
 			mu.Lock()
-			defer mu.Unlock()
-			usedHosts[dl.NetworkName][dl.NodeID] = append(usedHosts[dl.NetworkName][dl.NodeID], usedHostIDs...)
-		}(dl)
+			usedHosts[networkName][nodeID] = append(usedHosts[networkName][nodeID], usedHostIDs...)
+			mu.Unlock()
+		}
+		return nodeErrs
+	})
+
+	for _, nodeID := range nodes {
+		if err, ok := nodesErrs[nodeID]; ok {
+			errs = multierror.Append(errs, err)
+		}
 	}
 
-	wg.Wait()
 	return usedHosts, errs
 }
 
@@ -312,6 +389,15 @@ func (d *DeploymentDeployer) assignPrivateIPs(ctx context.Context, dls []*worklo
 		network := d.tfPluginClient.State.Networks.GetNetwork(dl.NetworkName)
 		ipRange := network.GetNodeSubnet(dl.NodeID)
 
+		if embedded, ok := dl.Network(); ok {
+			nodeIPRange, err := d.prepareEmbeddedNetwork(ctx, dl, embedded)
+			if err != nil {
+				errs = multierror.Append(errs, errors.Wrapf(err, "failed to prepare network %s", dl.NetworkName))
+				continue
+			}
+			ipRange = nodeIPRange.String()
+		}
+
 		ip, ipRangeCIDR, err := net.ParseCIDR(ipRange)
 		if err != nil {
 			errs = multierror.Append(errs, errors.Wrapf(err, "invalid ip range %s", ipRange))
@@ -351,6 +437,48 @@ func (d *DeploymentDeployer) assignPrivateIPs(ctx context.Context, dls []*worklo
 	return newDls, errs
 }
 
+// updateEmbeddedNetworkState stores and persists the subnet of a network deployed within a deployment
+func (d *DeploymentDeployer) updateEmbeddedNetworkState(dl *workloads.Deployment) {
+	network, ok := dl.Network()
+	if !ok {
+		return
+	}
+
+	network.SetNodeDeploymentID(map[uint32]uint64{dl.NodeID: dl.ContractID})
+	d.tfPluginClient.State.Networks.UpdateNetworkSubnets(network.GetName(), network.GetNodesIPRange())
+	if err := d.tfPluginClient.State.Save(); err != nil {
+		log.Warn().Err(err).Msg("failed to persist state")
+	}
+}
+
+// prepareEmbeddedNetwork assigns the ip range, wireguard key and port of a network deployed within a deployment
+func (d *DeploymentDeployer) prepareEmbeddedNetwork(ctx context.Context, dl *workloads.Deployment, network workloads.Network) (zos.IPNet, error) {
+	nodes := []uint32{dl.NodeID}
+	network.SetNodes(nodes)
+
+	switch znet := network.(type) {
+	case *workloads.ZNetLight:
+		if err := znet.AssignNodesIPs(nodes); err != nil {
+			return zos.IPNet{}, errors.Wrap(err, "could not assign node ip range")
+		}
+	case *workloads.ZNet:
+		if err := znet.AssignNodesIPs(nodes); err != nil {
+			return zos.IPNet{}, errors.Wrap(err, "could not assign node ip range")
+		}
+
+		if err := znet.AssignNodesWGKey(nodes); err != nil {
+			return zos.IPNet{}, errors.Wrap(err, "could not assign node wireguard key")
+		}
+
+		err := znet.AssignNodesWGPort(ctx, d.tfPluginClient.SubstrateConn, d.tfPluginClient.NcPool, nodes, nil)
+		if err != nil {
+			return zos.IPNet{}, errors.Wrap(err, "could not assign node wireguard port")
+		}
+	}
+
+	return network.GetNodesIPRange()[dl.NodeID], nil
+}
+
 func (d *DeploymentDeployer) syncContract(dl *workloads.Deployment) error {
 	sub := d.tfPluginClient.SubstrateConn
 
@@ -8,6 +8,7 @@ import (
 	"log"
 	"math/big"
 	"net"
+	"path/filepath"
 	"testing"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
@@ -806,3 +807,27 @@ func ExampleDeploymentDeployer_Cancel() {
 	}
 	fmt.Println("deployment is canceled successfully")
 }
+
+func TestEmbeddedNetworkState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	tfPluginClient := TFPluginClient{State: state.NewState(nil, nil)}
+	assert.NoError(t, tfPluginClient.State.SetStore(state.NewFileStore(path)))
+	d := NewDeploymentDeployer(&tfPluginClient)
+
+	network := &workloads.ZNetLight{
+		Name:         "network",
+		Nodes:        []uint32{nodeID},
+		IPRange:      zosTypes.MustParseIPNet("10.20.0.0/16"),
+		NodesIPRange: map[uint32]zosTypes.IPNet{nodeID: zosTypes.MustParseIPNet("10.20.2.0/24")},
+	}
+	dl := workloads.NewDeploymentWithWorkloads("test", nodeID, "", nil, network.Name, network)
+	dl.ContractID = contractID
+
+	d.updateEmbeddedNetworkState(&dl)
+	assert.Equal(t, map[uint32]uint64{nodeID: contractID}, network.NodeDeploymentID)
+
+	stored, err := state.NewFileStore(path).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint32]string{nodeID: "10.20.2.0/24"}, stored.Networks[network.Name].Subnets)
+}
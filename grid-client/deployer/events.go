@@ -0,0 +1,76 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+// EventType is the type of a deployment event
+type EventType string
+
+const (
+	// EventContractCreated is emitted after a node contract is created
+	EventContractCreated EventType = "contract_created"
+	// EventContractUpdated is emitted after a node contract is updated
+	EventContractUpdated EventType = "contract_updated"
+	// EventContractCanceled is emitted after a node contract is canceled
+	EventContractCanceled EventType = "contract_canceled"
+	// EventDeploymentSent is emitted after a deployment is sent to its node
+	EventDeploymentSent EventType = "deployment_sent"
+	// EventWorkloadStateChanged is emitted when a new state of a workload is seen while waiting for a deployment
+	EventWorkloadStateChanged EventType = "workload_state_changed"
+	// EventRetry is emitted when waiting for a deployment is retried
+	EventRetry EventType = "retry"
+	// EventRollback is emitted after the changes of a failed deployment are rolled back
+	EventRollback EventType = "rollback"
+	// EventFailover is emitted when a deployment is moved to another node after its node failed
+	EventFailover EventType = "failover"
+)
+
+// Event is a deployment progress event
+type Event struct {
+	Type       EventType `json:"type"`
+	Time       time.Time `json:"time"`
+	NodeID     uint32    `json:"node_id,omitempty"`
+	ContractID uint64    `json:"contract_id,omitempty"`
+
+	// Workload and State are set for workload state events
+	Workload string          `json:"workload,omitempty"`
+	State    zos.ResultState `json:"state,omitempty"`
+
+	// Attempt is the attempt number of retry events
+	Attempt int `json:"attempt,omitempty"`
+	// Err is the error that caused a retry, a rollback or a failover
+	Err error `json:"-"`
+	// Rollback is the report of rollback events
+	Rollback *RollbackReport `json:"rollback,omitempty"`
+}
+
+// EventSink receives deployment events, it must be safe for concurrent use
+type EventSink interface {
+	Emit(event Event)
+}
+
+// EventSinkFunc is an adapter to use a function as an event sink
+type EventSinkFunc func(event Event)
+
+// Emit calls f(event)
+func (f EventSinkFunc) Emit(event Event) {
+	f(event)
+}
+
+// emit sends an event to the deployer event sink if any
+func (d *Deployer) emit(event Event) {
+	emitEvent(d.eventSink, event)
+}
+
+// emitEvent sends an event to the sink if any
+func emitEvent(sink EventSink, event Event) {
+	if sink == nil {
+		return
+	}
+
+	event.Time = time.Now()
+	sink.Emit(event)
+}
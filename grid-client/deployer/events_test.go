@@ -0,0 +1,95 @@
+package deployer
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
+	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+type eventRecorder struct {
+	lock   sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) Emit(event Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, event)
+}
+
+func TestDeployerEvents(t *testing.T) {
+	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
+	require.NoError(t, err)
+	twinID := uint32(1)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cl := mocks.NewRMBMockClient(ctrl)
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	ncPool := mocks.NewMockNodeClientGetter(ctrl)
+
+	recorder := &eventRecorder{}
+	d := Deployer{
+		identity:      identity,
+		twinID:        twinID,
+		ncPool:        ncPool,
+		substrateConn: sub,
+		eventSink:     recorder,
+	}
+
+	dl, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+	require.NoError(t, err)
+
+	ncPool.EXPECT().
+		GetNodeClient(sub, uint32(10)).
+		Return(client.NewNodeClient(13, cl, 10), nil)
+
+	sub.EXPECT().
+		CreateNodeContract(identity, uint32(10), "", gomock.Any(), uint32(0), nil).
+		Return(uint64(100), nil)
+
+	cl.EXPECT().
+		Call(gomock.Any(), uint32(13), "zos.deployment.deploy", gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	cl.EXPECT().
+		Call(gomock.Any(), uint32(13), "zos.deployment.changes", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+			workloads := []zosTypes.Workload{dl.Workloads[0]}
+			workloads[0].Result.State = zosTypes.StateOk
+			*result.(*[]zosTypes.Workload) = workloads
+			return nil
+		})
+
+	contracts, err := d.deploy(
+		context.Background(),
+		nil,
+		map[uint32]zosTypes.Deployment{10: dl},
+		map[uint32]*uint64{10: nil},
+		false,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, map[uint32]uint64{10: 100}, contracts)
+
+	require.Len(t, recorder.events, 3)
+	assert.Equal(t, EventContractCreated, recorder.events[0].Type)
+	assert.Equal(t, uint32(10), recorder.events[0].NodeID)
+	assert.Equal(t, uint64(100), recorder.events[0].ContractID)
+	assert.Equal(t, EventDeploymentSent, recorder.events[1].Type)
+	assert.Equal(t, EventWorkloadStateChanged, recorder.events[2].Type)
+	assert.Equal(t, "name", recorder.events[2].Workload)
+	assert.Equal(t, zosTypes.StateOk, recorder.events[2].State)
+
+	for _, event := range recorder.events {
+		assert.False(t, event.Time.IsZero())
+	}
+}
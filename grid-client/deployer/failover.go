@@ -0,0 +1,169 @@
+package deployer
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+)
+
+// DefaultFailoverAttempts is the default number of replacement nodes tried by a failover
+const DefaultFailoverAttempts = 3
+
+// NodeError is an error caused by a node, e.g. the node is down or has no enough capacity
+type NodeError struct {
+	NodeID uint32
+	Err    error
+}
+
+func (e *NodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// FailoverPolicy moves a new deployment to another node if deploying it failed because of its node
+type FailoverPolicy struct {
+	// Filter is used to find the replacement nodes, the failed nodes are excluded
+	Filter   types.NodeFilter
+	SSDDisks []uint64
+	HDDDisks []uint64
+	RootFS   []uint64
+	// Network is the network of the deployment, it is extended to the replacement node and the failed node is removed from it.
+	// it is not needed if the deployment has no network or the network is deployed within the deployment
+	Network workloads.Network
+	// MaxAttempts is the max number of replacement nodes to try, defaults to DefaultFailoverAttempts
+	MaxAttempts int
+}
+
+// failedNode returns the node that caused an error if any
+func failedNode(err error) (uint32, bool) {
+	var nodeErr *NodeError
+	if errors.As(err, &nodeErr) {
+		return nodeErr.NodeID, true
+	}
+
+	return 0, false
+}
+
+// DeployWithFailover deploys a new deployment, if the deployment fails because of its node
+// it is moved to another node matching the policy filter and deployed again
+func (d *DeploymentDeployer) DeployWithFailover(ctx context.Context, dl *workloads.Deployment, policy FailoverPolicy) error {
+	// existing deployments are not moved, their workloads data lives on their nodes
+	if dl.ContractID != 0 {
+		return d.Deploy(ctx, dl)
+	}
+
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultFailoverAttempts
+	}
+
+	excluded := slices.Clone(policy.Filter.Excluded)
+	for attempt := 0; ; attempt++ {
+		err := d.Deploy(ctx, dl)
+		node, ok := failedNode(err)
+		if err == nil || !ok || attempt == maxAttempts || dl.ContractID != 0 {
+			return err
+		}
+
+		excluded = append(excluded, uint64(node))
+		replacement, ferr := d.replacementNode(ctx, policy, excluded)
+		if ferr != nil {
+			return errors.Wrapf(err, "failed to find a replacement for node %d: %s", node, ferr)
+		}
+
+		log.Warn().Err(err).Msgf("deployment %s failed on node %d, moving it to node %d", dl.Name, node, replacement)
+		if merr := d.moveDeployment(ctx, dl, replacement, policy.Network); merr != nil {
+			return errors.Wrapf(err, "failed to move deployment to node %d: %s", replacement, merr)
+		}
+
+		emitEvent(d.tfPluginClient.eventSink, Event{Type: EventFailover, NodeID: replacement, Err: err})
+	}
+}
+
+// replacementNode finds a node matching the policy filter that is not excluded
+func (d *DeploymentDeployer) replacementNode(ctx context.Context, policy FailoverPolicy, excluded []uint64) (uint32, error) {
+	filter := policy.Filter
+	filter.Excluded = excluded
+
+	nodes, err := FilterNodes(ctx, *d.tfPluginClient, filter, policy.SSDDisks, policy.HDDDisks, policy.RootFS, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, node := range nodes {
+		if !slices.Contains(excluded, uint64(node.NodeID)) {
+			return uint32(node.NodeID), nil
+		}
+	}
+
+	return 0, ErrNoNodesMatchesResources
+}
+
+// moveDeployment moves a deployment and its network from its failed node to a new node,
+// its vms private ips are reassigned when the deployment is deployed
+func (d *DeploymentDeployer) moveDeployment(ctx context.Context, dl *workloads.Deployment, node uint32, network workloads.Network) error {
+	oldNode := dl.NodeID
+	dl.NodeID = node
+	dl.NodeDeploymentID = nil
+	dl.IPrange = ""
+
+	for idx := range dl.Vms {
+		dl.Vms[idx].NodeID = node
+		dl.Vms[idx].IP = ""
+	}
+
+	for idx := range dl.VmsLight {
+		dl.VmsLight[idx].NodeID = node
+		dl.VmsLight[idx].IP = ""
+	}
+
+	if embedded, ok := dl.Network(); ok {
+		myceliumKeys := embedded.GetMyceliumKeys()
+		if key, ok := myceliumKeys[oldNode]; ok {
+			embedded.SetMyceliumKeys(map[uint32][]byte{node: key})
+		}
+		nodesIPRange := embedded.GetNodesIPRange()
+		delete(nodesIPRange, oldNode)
+		embedded.SetNodesIPRange(nodesIPRange)
+		embedded.SetNodes([]uint32{node})
+		return nil
+	}
+
+	if dl.NetworkName == "" || (len(dl.Vms) == 0 && len(dl.VmsLight) == 0) {
+		return nil
+	}
+
+	if network == nil || network.GetName() != dl.NetworkName {
+		return errors.Errorf("network %s of the deployment is needed to move it", dl.NetworkName)
+	}
+
+	nodes := slices.DeleteFunc(slices.Clone(network.GetNodes()), func(n uint32) bool { return n == oldNode })
+	myceliumKeys := network.GetMyceliumKeys()
+	mycelium := len(myceliumKeys) != 0
+	delete(myceliumKeys, oldNode)
+	nodesIPRange := network.GetNodesIPRange()
+	delete(nodesIPRange, oldNode)
+
+	if !slices.Contains(nodes, node) {
+		if mycelium {
+			key, err := workloads.RandomMyceliumKey()
+			if err != nil {
+				return errors.Wrap(err, "failed to generate mycelium key")
+			}
+			myceliumKeys[node] = key
+		}
+		nodes = append(nodes, node)
+	}
+
+	network.SetMyceliumKeys(myceliumKeys)
+	network.SetNodesIPRange(nodesIPRange)
+	network.SetNodes(nodes)
+	return d.tfPluginClient.NetworkDeployer.Deploy(ctx, network)
+}
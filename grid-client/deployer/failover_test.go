@@ -0,0 +1,220 @@
+package deployer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/state"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	proxyTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+)
+
+func TestFailedNode(t *testing.T) {
+	nodeErr := &NodeError{NodeID: 3, Err: errors.New("node is down")}
+
+	node, ok := failedNode(errors.Wrap(multierror.Append(nil, nodeErr), "failed to deploy"))
+	assert.True(t, ok)
+	assert.Equal(t, uint32(3), node)
+
+	node, ok = failedNode(&RollbackError{Err: nodeErr})
+	assert.True(t, ok)
+	assert.Equal(t, uint32(3), node)
+
+	_, ok = failedNode(errors.New("invalid deployment"))
+	assert.False(t, ok)
+}
+
+func TestDeployWithFailover(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	ncPool := mocks.NewMockNodeClientGetter(ctrl)
+	gridProxy := mocks.NewMockClient(ctrl)
+	deployer := mocks.NewMockDeployer(ctrl)
+
+	var events []Event
+	tfPluginClient := TFPluginClient{
+		TwinID:          1,
+		SubstrateConn:   sub,
+		NcPool:          ncPool,
+		GridProxyClient: gridProxy,
+		State:           state.NewState(ncPool, sub),
+		eventSink:       EventSinkFunc(func(event Event) { events = append(events, event) }),
+	}
+	d := DeploymentDeployer{tfPluginClient: &tfPluginClient, deployer: deployer}
+
+	sub.EXPECT().GetBalance(gomock.Any()).Return(substrate.Balance{
+		Free: types.U128{Int: big.NewInt(100000000)},
+	}, nil).AnyTimes()
+
+	t.Run("moved to a replacement node", func(t *testing.T) {
+		events = nil
+		dl := workloads.NewDeployment("dl", 1, "", nil, "", []workloads.Disk{{Name: "disk", SizeGB: 1}}, nil, nil, nil, nil, nil)
+
+		gomock.InOrder(
+			deployer.EXPECT().
+				Deploy(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ map[uint32]uint64, dls map[uint32]zos.Deployment, _ map[uint32]*uint64) (map[uint32]uint64, error) {
+					assert.Contains(t, dls, uint32(1))
+					return map[uint32]uint64{}, &NodeError{NodeID: 1, Err: errors.New("node is down")}
+				}),
+			deployer.EXPECT().
+				Deploy(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ map[uint32]uint64, dls map[uint32]zos.Deployment, _ map[uint32]*uint64) (map[uint32]uint64, error) {
+					assert.Contains(t, dls, uint32(2))
+					return map[uint32]uint64{2: 10}, nil
+				}),
+		)
+
+		gridProxy.EXPECT().
+			Nodes(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, filter proxyTypes.NodeFilter, _ proxyTypes.Limit) ([]proxyTypes.Node, int, error) {
+				assert.Equal(t, []uint64{1}, filter.Excluded)
+				return []proxyTypes.Node{{NodeID: 2}}, 1, nil
+			}).Times(2)
+
+		err := d.DeployWithFailover(context.Background(), &dl, FailoverPolicy{})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint32(2), dl.NodeID)
+		assert.Equal(t, uint64(10), dl.ContractID)
+		require.Len(t, events, 1)
+		assert.Equal(t, EventFailover, events[0].Type)
+		assert.Equal(t, uint32(2), events[0].NodeID)
+	})
+
+	t.Run("deployment errors are not retried", func(t *testing.T) {
+		dl := workloads.NewDeployment("dl", 1, "", nil, "", []workloads.Disk{{Name: "disk", SizeGB: 1}}, nil, nil, nil, nil, nil)
+
+		deployer.EXPECT().
+			Deploy(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(map[uint32]uint64{}, errors.New("error creating contract"))
+
+		err := d.DeployWithFailover(context.Background(), &dl, FailoverPolicy{})
+		assert.Error(t, err)
+		assert.Equal(t, uint32(1), dl.NodeID)
+	})
+
+	t.Run("attempts are exhausted", func(t *testing.T) {
+		dl := workloads.NewDeployment("dl", 1, "", nil, "", []workloads.Disk{{Name: "disk", SizeGB: 1}}, nil, nil, nil, nil, nil)
+
+		deployer.EXPECT().
+			Deploy(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ map[uint32]uint64, dls map[uint32]zos.Deployment, _ map[uint32]*uint64) (map[uint32]uint64, error) {
+				for node := range dls {
+					return map[uint32]uint64{}, &NodeError{NodeID: node, Err: errors.New("node is down")}
+				}
+				return map[uint32]uint64{}, nil
+			}).Times(2)
+
+		gridProxy.EXPECT().
+			Nodes(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return([]proxyTypes.Node{{NodeID: 2}}, 1, nil).
+			Times(2)
+
+		err := d.DeployWithFailover(context.Background(), &dl, FailoverPolicy{MaxAttempts: 1})
+		var nodeErr *NodeError
+		require.ErrorAs(t, err, &nodeErr)
+		assert.Equal(t, uint32(2), nodeErr.NodeID)
+	})
+}
+
+func TestMoveDeployment(t *testing.T) {
+	d := DeploymentDeployer{}
+
+	network := &workloads.ZNetLight{
+		Name:         "network",
+		Nodes:        []uint32{1},
+		IPRange:      workloads.IPNet(10, 20, 0, 0, 16),
+		NodesIPRange: map[uint32]zos.IPNet{1: workloads.IPNet(10, 20, 2, 0, 24)},
+		MyceliumKeys: map[uint32][]byte{1: []byte("key")},
+	}
+	dl := workloads.NewDeploymentWithWorkloads("dl", 1, "", nil, "network", network)
+	dl.VmsLight = []workloads.VMLight{{Name: "vm", NodeID: 1, NetworkName: "network", IP: "10.20.2.2"}}
+
+	require.NoError(t, d.moveDeployment(context.Background(), &dl, 2, nil))
+
+	assert.Equal(t, uint32(2), dl.NodeID)
+	assert.Equal(t, uint32(2), dl.VmsLight[0].NodeID)
+	assert.Empty(t, dl.VmsLight[0].IP)
+	assert.Equal(t, []uint32{2}, network.Nodes)
+	assert.Empty(t, network.NodesIPRange)
+	assert.Equal(t, map[uint32][]byte{2: []byte("key")}, network.MyceliumKeys)
+
+	t.Run("network is needed", func(t *testing.T) {
+		dl := workloads.NewDeployment("dl", 1, "", nil, "network", nil, nil, []workloads.VM{{Name: "vm", NodeID: 1}}, nil, nil, nil)
+		assert.Error(t, d.moveDeployment(context.Background(), &dl, 2, nil))
+	})
+}
+
+func TestMoveDeploymentNetwork(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	ncPool := mocks.NewMockNodeClientGetter(ctrl)
+	deployer := mocks.NewMockDeployer(ctrl)
+
+	tfPluginClient := TFPluginClient{
+		TwinID:        1,
+		SubstrateConn: sub,
+		NcPool:        ncPool,
+		State:         state.NewState(ncPool, sub),
+	}
+	tfPluginClient.NetworkDeployer = NetworkDeployer{tfPluginClient: &tfPluginClient, deployer: deployer}
+	d := DeploymentDeployer{tfPluginClient: &tfPluginClient}
+
+	sub.EXPECT().GetBalance(gomock.Any()).Return(substrate.Balance{
+		Free: types.U128{Int: big.NewInt(100000000)},
+	}, nil).AnyTimes()
+
+	sub.EXPECT().GetContract(gomock.Any()).Return(subi.Contract{Contract: &substrate.Contract{
+		State: substrate.ContractState{IsCreated: true},
+	}}, nil).AnyTimes()
+
+	network := &workloads.ZNetLight{
+		Name:             "network",
+		Nodes:            []uint32{1},
+		IPRange:          workloads.IPNet(10, 20, 0, 0, 16),
+		NodesIPRange:     map[uint32]zos.IPNet{1: workloads.IPNet(10, 20, 2, 0, 24)},
+		MyceliumKeys:     map[uint32][]byte{1: make([]byte, 32)},
+		NodeDeploymentID: map[uint32]uint64{1: 10},
+	}
+
+	var deployed map[uint32]zos.Deployment
+	deployer.EXPECT().
+		Deploy(gomock.Any(), map[uint32]uint64{1: 10}, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ map[uint32]uint64, dls map[uint32]zos.Deployment, _ map[uint32]*uint64) (map[uint32]uint64, error) {
+			// the network deployment on the failed node is canceled
+			assert.NotContains(t, dls, uint32(1))
+			assert.Contains(t, dls, uint32(2))
+			deployed = dls
+			return map[uint32]uint64{2: 20}, nil
+		})
+	deployer.EXPECT().
+		GetDeployments(gomock.Any(), map[uint32]uint64{2: 20}).
+		DoAndReturn(func(_ context.Context, _ map[uint32]uint64) (map[uint32]zos.Deployment, error) {
+			return deployed, nil
+		})
+
+	dl := workloads.NewDeployment("dl", 1, "", nil, "network", nil, nil, nil, []workloads.VMLight{{Name: "vm", NodeID: 1, NetworkName: "network"}}, nil, nil)
+	require.NoError(t, d.moveDeployment(context.Background(), &dl, 2, network))
+
+	assert.Equal(t, []uint32{2}, network.Nodes)
+	assert.NotContains(t, network.NodesIPRange, uint32(1))
+	assert.NotContains(t, network.MyceliumKeys, uint32(1))
+	assert.Len(t, network.MyceliumKeys[2], 32)
+	assert.Equal(t, map[uint32]uint64{2: 20}, network.NodeDeploymentID)
+}
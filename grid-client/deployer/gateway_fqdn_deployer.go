@@ -7,6 +7,7 @@ import (
 	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
 	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	"golang.org/x/exp/maps"
 )
 
 // GatewayFQDNDeployer for deploying a GatewayFqdn
@@ -92,14 +93,37 @@ func (d *GatewayFQDNDeployer) Deploy(ctx context.Context, gw *workloads.GatewayF
 	// error is not returned immediately before updating state because of untracked failed deployments
 	if contractID, ok := gw.NodeDeploymentID[gw.NodeID]; ok && contractID != 0 {
 		gw.ContractID = contractID
-		if !workloads.Contains(d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID], gw.ContractID) {
-			d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID] = append(d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID], gw.ContractID)
-		}
+		d.tfPluginClient.State.StoreContractIDs(gw.NodeID, gw.ContractID)
 	}
 
 	return err
 }
 
+// Reconcile reports the drift between the gateway and the deployed one,
+// if reapply is set the gateway is deployed again to fix the drift
+func (d *GatewayFQDNDeployer) Reconcile(ctx context.Context, gw *workloads.GatewayFQDNProxy, reapply bool) (DriftReport, error) {
+	if err := d.Validate(ctx, gw); err != nil {
+		return DriftReport{}, err
+	}
+
+	newDeployments, err := d.GenerateVersionlessDeployments(ctx, gw)
+	if err != nil {
+		return DriftReport{}, errors.Wrap(err, "could not generate deployments data")
+	}
+
+	oldDeploymentIDs := maps.Clone(gw.NodeDeploymentID)
+	deployer := NewDeployer(*d.tfPluginClient, true)
+	currentDeploymentIDs, report, err := deployer.Reconcile(ctx, gw.NodeDeploymentID, newDeployments, nil, reapply)
+
+	if reapply {
+		gw.NodeDeploymentID = currentDeploymentIDs
+		gw.ContractID = currentDeploymentIDs[gw.NodeID]
+		updateStateContracts(d.tfPluginClient.State, oldDeploymentIDs, currentDeploymentIDs)
+	}
+
+	return report, err
+}
+
 // BatchDeploy deploys multiple deployments using the deployer
 func (d *GatewayFQDNDeployer) BatchDeploy(ctx context.Context, gws []*workloads.GatewayFQDNProxy) error {
 	newDeployments := make(map[uint32][]zosTypes.Deployment)
@@ -155,7 +179,7 @@ func (d *GatewayFQDNDeployer) Cancel(ctx context.Context, gw *workloads.GatewayF
 	// update state
 	gw.ContractID = 0
 	delete(gw.NodeDeploymentID, gw.NodeID)
-	d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID] = workloads.Delete(d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID], contractID)
+	d.tfPluginClient.State.RemoveContractIDs(gw.NodeID, contractID)
 
 	return nil
 }
@@ -176,9 +200,7 @@ func (d *GatewayFQDNDeployer) updateStateFromDeployments(gw *workloads.GatewayFQ
 
 	if contractID, ok := gw.NodeDeploymentID[gw.NodeID]; ok && contractID != 0 {
 		gw.ContractID = contractID
-		if !workloads.Contains(d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID], gw.ContractID) {
-			d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID] = append(d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID], gw.ContractID)
-		}
+		d.tfPluginClient.State.StoreContractIDs(gw.NodeID, gw.ContractID)
 	}
 
 	return nil
@@ -8,6 +8,7 @@ import (
 	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
 	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	"golang.org/x/exp/maps"
 )
 
 // GatewayNameDeployer for deploying a GatewayName
@@ -94,14 +95,51 @@ func (d *GatewayNameDeployer) Deploy(ctx context.Context, gw *workloads.GatewayN
 	// error is not returned immediately before updating state because of untracked failed deployments
 	if contractID, ok := gw.NodeDeploymentID[gw.NodeID]; ok && contractID != 0 {
 		gw.ContractID = contractID
-		if !workloads.Contains(d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID], gw.ContractID) {
-			d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID] = append(d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID], gw.ContractID)
-		}
+		d.tfPluginClient.State.StoreContractIDs(gw.NodeID, gw.ContractID)
 	}
 
 	return err
 }
 
+// Reconcile reports the drift between the gateway and the deployed one,
+// if reapply is set the gateway is deployed again to fix the drift
+func (d *GatewayNameDeployer) Reconcile(ctx context.Context, gw *workloads.GatewayNameProxy, reapply bool) (DriftReport, error) {
+	if err := d.Validate(ctx, gw); err != nil {
+		return DriftReport{}, err
+	}
+
+	newDeployments, err := d.GenerateVersionlessDeployments(ctx, gw)
+	if err != nil {
+		return DriftReport{}, errors.Wrap(err, "could not generate deployments data")
+	}
+
+	// the name contract is needed before deploying the gateway again
+	if reapply {
+		if err := d.InvalidateNameContract(ctx, gw); err != nil {
+			return DriftReport{}, err
+		}
+
+		if gw.NameContractID == 0 {
+			gw.NameContractID, err = d.tfPluginClient.SubstrateConn.CreateNameContract(d.tfPluginClient.Identity, gw.Name)
+			if err != nil {
+				return DriftReport{}, err
+			}
+		}
+	}
+
+	oldDeploymentIDs := maps.Clone(gw.NodeDeploymentID)
+	deployer := NewDeployer(*d.tfPluginClient, true)
+	currentDeploymentIDs, report, err := deployer.Reconcile(ctx, gw.NodeDeploymentID, newDeployments, nil, reapply)
+
+	if reapply {
+		gw.NodeDeploymentID = currentDeploymentIDs
+		gw.ContractID = currentDeploymentIDs[gw.NodeID]
+		updateStateContracts(d.tfPluginClient.State, oldDeploymentIDs, currentDeploymentIDs)
+	}
+
+	return report, err
+}
+
 // BatchDeploy deploys multiple deployments using the deployer
 func (d *GatewayNameDeployer) BatchDeploy(ctx context.Context, gws []*workloads.GatewayNameProxy) error {
 	newDeployments := make(map[uint32][]zosTypes.Deployment)
@@ -162,7 +200,7 @@ func (d *GatewayNameDeployer) Cancel(ctx context.Context, gw *workloads.GatewayN
 
 	gw.ContractID = 0
 	delete(gw.NodeDeploymentID, gw.NodeID)
-	d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID] = workloads.Delete(d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID], contractID)
+	d.tfPluginClient.State.RemoveContractIDs(gw.NodeID, contractID)
 
 	if gw.NameContractID != 0 {
 		if err := d.tfPluginClient.SubstrateConn.EnsureContractCanceled(d.tfPluginClient.Identity, gw.NameContractID); err != nil {
@@ -190,9 +228,7 @@ func (d *GatewayNameDeployer) updateStateFromDeployments(gw *workloads.GatewayNa
 
 	if contractID, ok := gw.NodeDeploymentID[gw.NodeID]; ok && contractID != 0 {
 		gw.ContractID = contractID
-		if !workloads.Contains(d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID], gw.ContractID) {
-			d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID] = append(d.tfPluginClient.State.CurrentNodeDeployments[gw.NodeID], gw.ContractID)
-		}
+		d.tfPluginClient.State.StoreContractIDs(gw.NodeID, gw.ContractID)
 	}
 
 	return nil
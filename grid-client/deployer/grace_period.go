@@ -0,0 +1,337 @@
+package deployer
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/graphql"
+	proxyTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// DefaultGracePeriod is the tfchain grace period after which contracts in grace period are deleted
+	DefaultGracePeriod = 14 * 24 * time.Hour
+	// DefaultRecoveryBuffer is the billing duration covered by a recovery on top of the overdue amounts
+	DefaultRecoveryBuffer = 24 * time.Hour
+
+	gracePeriodState = "GracePeriod"
+	blockTime        = 6 * time.Second
+	// recentBills is the number of recent bills used to get the hourly cost of a contract
+	recentBills = 24
+)
+
+// GracePeriodOptions configures checking and recovering contracts in grace period
+type GracePeriodOptions struct {
+	// GracePeriod defaults to DefaultGracePeriod
+	GracePeriod time.Duration
+	// Buffer defaults to DefaultRecoveryBuffer
+	Buffer time.Duration
+
+	// FundingIdentity tops up the twin account with the needed TFT if set
+	FundingIdentity substrate.Identity
+	// MaxTopUp limits the top up in TFT, zero is unlimited
+	MaxTopUp float64
+	// LowPriorityProjects are the projects, in order, which could be canceled to recover the remaining
+	// contracts as contracts can not be paused by their owner
+	LowPriorityProjects []string
+	// CancelProjects cancels the low priority projects needed to cover the missing TFT,
+	// they are only reported otherwise
+	CancelProjects bool
+}
+
+// AtRiskContract is a contract in grace period which gets deleted at its deadline if not recovered
+type AtRiskContract struct {
+	ContractID       uint64    `json:"contract_id"`
+	NodeID           uint32    `json:"node_id,omitempty"`
+	Project          string    `json:"project"`
+	GracePeriodStart time.Time `json:"grace_period_start"`
+	Deadline         time.Time `json:"deadline"`
+	// HourlyCost is the TFT billed per hour based on the contract recent bills
+	HourlyCost float64 `json:"hourly_cost"`
+	// Overdue is the TFT billed since the contract entered grace period
+	Overdue float64 `json:"overdue"`
+	// Canceled is set if the contract project was canceled during the recovery
+	Canceled bool `json:"canceled,omitempty"`
+}
+
+// GracePeriodReport is the state of the twin contracts in grace period
+type GracePeriodReport struct {
+	// Contracts are sorted by their deadlines
+	Contracts []AtRiskContract `json:"contracts"`
+	// Balance is the twin free balance in TFT
+	Balance float64 `json:"balance"`
+	// HourlyCost is the TFT billed per hour by all the twin contracts, including the ones not in grace period,
+	// it is only computed if some contracts are in grace period
+	HourlyCost float64 `json:"hourly_cost"`
+	// Needed is the TFT missing to recover the contracts and cover all the twin contracts for the recovery buffer
+	Needed float64 `json:"needed"`
+	// ToppedUp is the TFT transferred from the funding identity
+	ToppedUp float64 `json:"topped_up,omitempty"`
+	// ProjectsToCancel are the low priority projects which cover the missing TFT if canceled,
+	// it is empty if all the low priority projects can not cover it
+	ProjectsToCancel []string `json:"projects_to_cancel,omitempty"`
+	CanceledProjects []string `json:"canceled_projects,omitempty"`
+}
+
+// recoveryContract is a twin contract with the TFT it needs during the recovery,
+// which is not needed anymore if the contract is canceled
+type recoveryContract struct {
+	reportedContract
+	required float64
+}
+
+// CheckGracePeriod reports the twin contracts in grace period with their deadlines and the TFT needed to recover them
+func (t *TFPluginClient) CheckGracePeriod(ctx context.Context, opts GracePeriodOptions) (GracePeriodReport, error) {
+	report, _, err := t.checkGracePeriod(ctx, opts.withDefaults())
+	return report, err
+}
+
+// checkGracePeriod returns the grace period report with all the twin contracts and the TFT they need during the recovery
+func (t *TFPluginClient) checkGracePeriod(ctx context.Context, opts GracePeriodOptions) (GracePeriodReport, []recoveryContract, error) {
+	contracts, err := t.ContractsGetter.ListContractsByTwinID([]string{"Created", gracePeriodState})
+	if err != nil {
+		return GracePeriodReport{}, nil, errors.Wrap(err, "failed to list contracts")
+	}
+	reported := groupContractsByProject(contracts)
+
+	inGracePeriod := map[string]bool{}
+	for _, list := range [][]graphql.Contract{contracts.NodeContracts, contracts.NameContracts, contracts.RentContracts} {
+		for _, contract := range list {
+			if contract.State == gracePeriodState {
+				inGracePeriod[contract.ContractID] = true
+			}
+		}
+	}
+
+	balance, err := t.SubstrateConn.GetBalance(t.Identity)
+	if err != nil {
+		return GracePeriodReport{}, nil, errors.Wrap(err, "failed to get balance")
+	}
+
+	report := GracePeriodReport{Contracts: []AtRiskContract{}}
+	if balance.Free.Int != nil {
+		report.Balance = tftFromUnits(balance.Free.Uint64())
+	}
+
+	if len(inGracePeriod) == 0 {
+		return report, nil, nil
+	}
+
+	height, err := t.SubstrateConn.GetCurrentHeight()
+	if err != nil {
+		return GracePeriodReport{}, nil, errors.Wrap(err, "failed to get current block")
+	}
+	now := time.Now()
+
+	recovery := make([]recoveryContract, len(reported))
+	hourlyCosts := make([]float64, len(reported))
+	atRisk := make([]*AtRiskContract, len(reported))
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(billsWorkers)
+	for i, contract := range reported {
+		i, contract := i, contract
+		group.Go(func() error {
+			bills, err := t.contractBills(ctx, contract.id)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get contract %d bills", contract.id)
+			}
+
+			hourlyCost := hourlyCost(bills)
+			hourlyCosts[i] = hourlyCost
+
+			// contracts not in grace period keep being billed during the recovery
+			if !inGracePeriod[strconv.FormatUint(contract.id, 10)] {
+				recovery[i] = recoveryContract{reportedContract: contract, required: hourlyCost * opts.Buffer.Hours()}
+				return nil
+			}
+
+			chainContract, err := t.SubstrateConn.GetContract(contract.id)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get contract %d", contract.id)
+			}
+
+			// the contract state changes on the chain before graphql processes it
+			start := now
+			if chainContract.State.IsGracePeriod {
+				start = now.Add(-time.Duration(uint64(height)-uint64(chainContract.State.AsGracePeriodBlockNumber)) * blockTime)
+			}
+
+			atRisk[i] = &AtRiskContract{
+				ContractID:       contract.id,
+				NodeID:           contract.nodeID,
+				Project:          contract.project,
+				GracePeriodStart: start,
+				Deadline:         start.Add(opts.GracePeriod),
+				HourlyCost:       hourlyCost,
+				Overdue:          hourlyCost * now.Sub(start).Hours(),
+			}
+			recovery[i] = recoveryContract{reportedContract: contract, required: atRisk[i].required(opts.Buffer)}
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return GracePeriodReport{}, nil, err
+	}
+
+	var required float64
+	for i := range reported {
+		if atRisk[i] != nil {
+			report.Contracts = append(report.Contracts, *atRisk[i])
+		}
+
+		report.HourlyCost += hourlyCosts[i]
+		required += recovery[i].required
+	}
+	report.Needed = math.Max(0, required-report.Balance)
+
+	sort.SliceStable(report.Contracts, func(i, j int) bool {
+		return report.Contracts[i].Deadline.Before(report.Contracts[j].Deadline)
+	})
+
+	return report, recovery, nil
+}
+
+// RecoverGracePeriod checks the twin contracts in grace period then tops up the needed TFT from the
+// funding identity. If the top up does not cover the needed TFT, the low priority projects which cover
+// the rest are reported and only canceled if CancelProjects is set
+func (t *TFPluginClient) RecoverGracePeriod(ctx context.Context, opts GracePeriodOptions) (GracePeriodReport, error) {
+	opts = opts.withDefaults()
+	report, contracts, err := t.checkGracePeriod(ctx, opts)
+	if err != nil {
+		return GracePeriodReport{}, err
+	}
+
+	if report.Needed > 0 && opts.FundingIdentity != nil {
+		topUp := report.Needed
+		if opts.MaxTopUp > 0 {
+			topUp = math.Min(topUp, opts.MaxTopUp)
+		}
+
+		account, err := substrate.FromAddress(t.Identity.Address())
+		if err != nil {
+			return report, errors.Wrap(err, "failed to get twin account")
+		}
+
+		amount := uint64(math.Ceil(topUp * tftUnitsPerToken))
+		if err := t.SubstrateConn.Transfer(opts.FundingIdentity, amount, account); err != nil {
+			return report, errors.Wrapf(err, "failed to top up %f TFT", topUp)
+		}
+
+		log.Info().Float64("tft", topUp).Msg("topped up twin account to recover contracts in grace period")
+		report.ToppedUp = topUp
+		report.Needed = math.Max(0, report.Needed-topUp)
+	}
+
+	if report.Needed <= 0 || len(opts.LowPriorityProjects) == 0 {
+		return report, nil
+	}
+
+	projectContracts := map[string][]uint64{}
+	projectRequired := map[string]float64{}
+	for _, contract := range contracts {
+		projectContracts[contract.project] = append(projectContracts[contract.project], contract.id)
+		projectRequired[contract.project] += contract.required
+	}
+
+	// projects are only selected if canceling them frees enough TFT to recover the remaining contracts
+	var projects []string
+	var freed float64
+	for _, project := range opts.LowPriorityProjects {
+		if freed >= report.Needed {
+			break
+		}
+
+		if len(projectContracts[project]) == 0 || slices.Contains(projects, project) {
+			continue
+		}
+
+		projects = append(projects, project)
+		freed += projectRequired[project]
+	}
+
+	if freed < report.Needed {
+		log.Warn().Float64("needed", report.Needed).Float64("freed", freed).Msg("canceling the low priority projects does not cover the needed TFT")
+		return report, nil
+	}
+
+	report.ProjectsToCancel = projects
+	if !opts.CancelProjects {
+		return report, nil
+	}
+
+	for _, project := range projects {
+		if err := t.BatchCancelContract(projectContracts[project]); err != nil {
+			return report, errors.Wrapf(err, "failed to cancel project %s", project)
+		}
+
+		log.Info().Str("project", project).Msg("canceled low priority project to recover contracts in grace period")
+		report.CanceledProjects = append(report.CanceledProjects, project)
+		report.Needed = math.Max(0, report.Needed-projectRequired[project])
+		for i := range report.Contracts {
+			if report.Contracts[i].Project == project {
+				report.Contracts[i].Canceled = true
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func (opts GracePeriodOptions) withDefaults() GracePeriodOptions {
+	if opts.GracePeriod == 0 {
+		opts.GracePeriod = DefaultGracePeriod
+	}
+
+	if opts.Buffer == 0 {
+		opts.Buffer = DefaultRecoveryBuffer
+	}
+
+	return opts
+}
+
+// required is the TFT needed to bring the contract back and cover it for the buffer duration
+func (c AtRiskContract) required(buffer time.Duration) float64 {
+	return c.Overdue + c.HourlyCost*buffer.Hours()
+}
+
+// hourlyCost returns the TFT billed per hour based on the most recent bills
+func hourlyCost(bills []proxyTypes.ContractBilling) float64 {
+	if len(bills) == 0 {
+		return 0
+	}
+
+	bills = slices.Clone(bills)
+	sort.Slice(bills, func(i, j int) bool { return bills[i].Timestamp < bills[j].Timestamp })
+	if len(bills) > recentBills {
+		bills = bills[len(bills)-recentBills:]
+	}
+
+	// contracts are billed every hour
+	last := bills[len(bills)-1]
+	if len(bills) == 1 || last.Timestamp == bills[0].Timestamp {
+		return tftFromUnits(last.AmountBilled)
+	}
+
+	// the first bill covers the time before the period of the recent bills
+	var amount uint64
+	for _, bill := range bills[1:] {
+		amount += bill.AmountBilled
+	}
+
+	hours := float64(last.Timestamp-bills[0].Timestamp) / time.Hour.Seconds()
+	return tftFromUnits(amount) / hours
+}
+
+func tftFromUnits(amount uint64) float64 {
+	return float64(amount) / tftUnitsPerToken
+}
@@ -0,0 +1,176 @@
+package deployer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/graphql"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
+	proxyTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+)
+
+// gracePeriodGraphql serves node contracts 1 and 3 in grace period and contract 2 created
+func gracePeriodGraphql(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query string `json:"query"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		var edges []string
+		if strings.Contains(request.Query, "nodeContractsConnection") {
+			for id, contract := range []struct{ state, project string }{
+				{state: "GracePeriod", project: "prod"},
+				{state: "Created", project: "low"},
+				{state: "GracePeriod", project: "low"},
+			} {
+				edges = append(edges, fmt.Sprintf(
+					`{"node": {"contractID": "%d", "state": "%s", "nodeID": 1, "deploymentData": "{\"type\":\"vm\",\"name\":\"vm\",\"projectName\":\"%s\"}"}}`,
+					id+1, contract.state, contract.project,
+				))
+			}
+		}
+
+		fmt.Fprintf(w, `{"data": {"items": {"edges": [%s], "pageInfo": {"hasNextPage": false}}}}`, strings.Join(edges, ","))
+	}))
+}
+
+func TestGracePeriod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := gracePeriodGraphql(t)
+	defer server.Close()
+
+	g, err := graphql.NewGraphQl(server.URL)
+	require.NoError(t, err)
+
+	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
+	require.NoError(t, err)
+
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	gridProxy := mocks.NewMockClient(ctrl)
+
+	tfPluginClient := TFPluginClient{
+		Identity:        identity,
+		SubstrateConn:   sub,
+		GridProxyClient: gridProxy,
+		ContractsGetter: graphql.NewContractsGetter(1, g, sub, nil),
+	}
+
+	sub.EXPECT().GetBalance(identity).Return(substrate.Balance{Free: types.NewU128(*big.NewInt(1e7))}, nil).AnyTimes()
+	sub.EXPECT().GetCurrentHeight().Return(uint32(1000), nil).AnyTimes()
+
+	gracePeriodContract := func(block uint64) subi.Contract {
+		return subi.Contract{Contract: &substrate.Contract{State: substrate.ContractState{
+			IsGracePeriod:            true,
+			AsGracePeriodBlockNumber: types.U64(block),
+		}}}
+	}
+	// contract 1 entered grace period an hour ago, contract 3 just entered it
+	sub.EXPECT().GetContract(uint64(1)).Return(gracePeriodContract(400), nil).AnyTimes()
+	sub.EXPECT().GetContract(uint64(3)).Return(gracePeriodContract(1000), nil).AnyTimes()
+
+	bills := map[uint32][]proxyTypes.ContractBilling{
+		// 1 TFT per hour
+		1: {{AmountBilled: 1e7, Timestamp: 7200}, {AmountBilled: 1e7, Timestamp: 0}, {AmountBilled: 1e7, Timestamp: 3600}},
+		// 0.5 TFT per hour
+		2: {{AmountBilled: 5e6, Timestamp: 3600}},
+		// 2 TFT per hour
+		3: {{AmountBilled: 2e7, Timestamp: 3600}},
+	}
+	gridProxy.EXPECT().
+		ContractBills(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, contractID uint32, _ proxyTypes.Limit) ([]proxyTypes.ContractBilling, uint, error) {
+			return bills[contractID], uint(len(bills[contractID])), nil
+		}).AnyTimes()
+
+	t.Run("check", func(t *testing.T) {
+		report, err := tfPluginClient.CheckGracePeriod(context.Background(), GracePeriodOptions{})
+		require.NoError(t, err)
+
+		require.Len(t, report.Contracts, 2)
+		assert.Equal(t, uint64(1), report.Contracts[0].ContractID)
+		assert.Equal(t, "prod", report.Contracts[0].Project)
+		assert.InDelta(t, 1, report.Contracts[0].HourlyCost, 1e-9)
+		assert.InDelta(t, 1, report.Contracts[0].Overdue, 1e-3)
+		assert.WithinDuration(t, time.Now().Add(DefaultGracePeriod-time.Hour), report.Contracts[0].Deadline, time.Minute)
+
+		assert.Equal(t, uint64(3), report.Contracts[1].ContractID)
+		assert.InDelta(t, 2, report.Contracts[1].HourlyCost, 1e-9)
+
+		// (1 + 24) + (0 + 48) + 12 - 1, the created contract 2 is billed during the recovery buffer
+		assert.Equal(t, float64(1), report.Balance)
+		assert.InDelta(t, 3.5, report.HourlyCost, 1e-9)
+		assert.InDelta(t, 84, report.Needed, 1e-3)
+	})
+
+	funding, err := substrate.NewIdentityFromSr25519Phrase("//Bob")
+	require.NoError(t, err)
+
+	t.Run("report projects to cancel", func(t *testing.T) {
+		sub.EXPECT().Transfer(funding, uint64(50e7), gomock.Any()).Return(nil)
+
+		report, err := tfPluginClient.RecoverGracePeriod(context.Background(), GracePeriodOptions{
+			FundingIdentity:     funding,
+			MaxTopUp:            50,
+			LowPriorityProjects: []string{"low", "prod"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, float64(50), report.ToppedUp)
+		assert.Equal(t, []string{"low"}, report.ProjectsToCancel)
+		assert.Empty(t, report.CanceledProjects)
+		assert.False(t, report.Contracts[1].Canceled)
+		assert.InDelta(t, 34, report.Needed, 1e-3)
+	})
+
+	t.Run("projects do not cover the needed tft", func(t *testing.T) {
+		sub.EXPECT().Transfer(funding, uint64(10e7), gomock.Any()).Return(nil)
+
+		// canceling low frees 12 + 48 out of the 74 needed
+		report, err := tfPluginClient.RecoverGracePeriod(context.Background(), GracePeriodOptions{
+			FundingIdentity:     funding,
+			MaxTopUp:            10,
+			LowPriorityProjects: []string{"low"},
+			CancelProjects:      true,
+		})
+		require.NoError(t, err)
+
+		assert.Empty(t, report.ProjectsToCancel)
+		assert.Empty(t, report.CanceledProjects)
+		assert.InDelta(t, 74, report.Needed, 1e-3)
+	})
+
+	t.Run("recover", func(t *testing.T) {
+		sub.EXPECT().Transfer(funding, uint64(50e7), gomock.Any()).Return(nil)
+		sub.EXPECT().BatchCancelContract(identity, []uint64{2, 3}).Return(nil)
+
+		report, err := tfPluginClient.RecoverGracePeriod(context.Background(), GracePeriodOptions{
+			FundingIdentity:     funding,
+			MaxTopUp:            50,
+			LowPriorityProjects: []string{"low", "prod"},
+			CancelProjects:      true,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, float64(50), report.ToppedUp)
+		assert.Equal(t, []string{"low"}, report.CanceledProjects)
+		assert.False(t, report.Contracts[0].Canceled)
+		assert.True(t, report.Contracts[1].Canceled)
+		assert.Zero(t, report.Needed)
+	})
+}
@@ -6,14 +6,17 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"slices"
 
 	"github.com/pkg/errors"
 	zerolog "github.com/rs/zerolog/log"
 	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
 	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
 	"github.com/threefoldtech/zos/pkg/gridtypes"
 	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"golang.org/x/exp/maps"
 )
 
 // K8sDeployer for deploying k8s
@@ -52,6 +55,9 @@ func (d *K8sDeployer) Validate(ctx context.Context, k8sCluster *workloads.K8sClu
 	// validate cluster nodes
 	var nodes []uint32
 	nodes = append(nodes, k8sCluster.Master.NodeID)
+	for _, master := range k8sCluster.Masters {
+		nodes = append(nodes, master.NodeID)
+	}
 	for _, worker := range k8sCluster.Workers {
 		if !workloads.Contains(nodes, worker.NodeID) {
 			nodes = append(nodes, worker.NodeID)
@@ -73,6 +79,11 @@ func (d *K8sDeployer) GenerateVersionlessDeployments(ctx context.Context, k8sClu
 	for _, m := range masterWorkloads {
 		nodeWorkloads[k8sCluster.Master.NodeID] = append(nodeWorkloads[k8sCluster.Master.NodeID], zosTypes.NewWorkloadFromZosWorkload(m))
 	}
+	for _, m := range k8sCluster.Masters {
+		for _, wl := range m.JoiningMasterZosWorkload(k8sCluster) {
+			nodeWorkloads[m.NodeID] = append(nodeWorkloads[m.NodeID], zosTypes.NewWorkloadFromZosWorkload(wl))
+		}
+	}
 	for _, w := range k8sCluster.Workers {
 		workerWorkloads := w.WorkerZosWorkload(k8sCluster)
 		for _, wr := range workerWorkloads {
@@ -117,12 +128,22 @@ func (d *K8sDeployer) Deploy(ctx context.Context, k8sCluster *workloads.K8sClust
 	newDeploymentsSolutionProvider := make(map[uint32]*uint64)
 	newDeploymentsSolutionProvider[k8sCluster.Master.NodeID] = nil
 
+	oldDeploymentIDs := maps.Clone(k8sCluster.NodeDeploymentID)
 	k8sCluster.NodeDeploymentID, err = d.deployer.Deploy(ctx, k8sCluster.NodeDeploymentID, newDeployments, newDeploymentsSolutionProvider)
 
 	// update deployments state
 	// error is not returned immediately before updating state because of untracked failed deployments
+	for nodeID, oldContractID := range oldDeploymentIDs {
+		// nodes with changed public ips are moved to new contracts
+		if contractID, ok := k8sCluster.NodeDeploymentID[nodeID]; ok && contractID != oldContractID {
+			d.tfPluginClient.State.RemoveContractIDs(nodeID, oldContractID)
+		}
+	}
 	if contractID, ok := k8sCluster.NodeDeploymentID[k8sCluster.Master.NodeID]; ok && contractID != 0 {
 		d.tfPluginClient.State.StoreContractIDs(k8sCluster.Master.NodeID, contractID)
+		for _, m := range k8sCluster.Masters {
+			d.tfPluginClient.State.StoreContractIDs(m.NodeID, k8sCluster.NodeDeploymentID[m.NodeID])
+		}
 		for _, w := range k8sCluster.Workers {
 			d.tfPluginClient.State.StoreContractIDs(w.NodeID, k8sCluster.NodeDeploymentID[w.NodeID])
 		}
@@ -131,6 +152,67 @@ func (d *K8sDeployer) Deploy(ctx context.Context, k8sCluster *workloads.K8sClust
 	return err
 }
 
+// Plan returns the changes deploying the k8s cluster would apply without deploying it
+func (d *K8sDeployer) Plan(ctx context.Context, k8sCluster *workloads.K8sCluster) (Plan, error) {
+	if err := d.tfPluginClient.State.AssignNodesIPRange(k8sCluster); err != nil {
+		return Plan{}, err
+	}
+
+	err := k8sCluster.InvalidateBrokenAttributes(d.tfPluginClient.SubstrateConn)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	assignNodesFlistsAndEntryPoints(k8sCluster)
+
+	if err := d.Validate(ctx, k8sCluster); err != nil {
+		return Plan{}, err
+	}
+
+	newDeployments, err := d.GenerateVersionlessDeployments(ctx, k8sCluster)
+	if err != nil {
+		return Plan{}, errors.Wrap(err, "could not generate k8s grid deployments")
+	}
+
+	deployer := NewDeployer(*d.tfPluginClient, true)
+	return deployer.Plan(ctx, k8sCluster.NodeDeploymentID, newDeployments)
+}
+
+// Reconcile reports the drift between the k8s cluster and the deployed one,
+// if reapply is set the cluster is deployed again to fix the drift
+func (d *K8sDeployer) Reconcile(ctx context.Context, k8sCluster *workloads.K8sCluster, reapply bool) (DriftReport, error) {
+	if err := d.tfPluginClient.State.AssignNodesIPRange(k8sCluster); err != nil {
+		return DriftReport{}, err
+	}
+
+	err := k8sCluster.InvalidateBrokenAttributes(d.tfPluginClient.SubstrateConn)
+	if err != nil {
+		return DriftReport{}, err
+	}
+
+	assignNodesFlistsAndEntryPoints(k8sCluster)
+
+	if err := d.Validate(ctx, k8sCluster); err != nil {
+		return DriftReport{}, err
+	}
+
+	newDeployments, err := d.GenerateVersionlessDeployments(ctx, k8sCluster)
+	if err != nil {
+		return DriftReport{}, errors.Wrap(err, "could not generate k8s grid deployments")
+	}
+
+	oldDeploymentIDs := maps.Clone(k8sCluster.NodeDeploymentID)
+	deployer := NewDeployer(*d.tfPluginClient, true)
+	currentDeploymentIDs, report, err := deployer.Reconcile(ctx, k8sCluster.NodeDeploymentID, newDeployments, nil, reapply)
+
+	if reapply {
+		k8sCluster.NodeDeploymentID = currentDeploymentIDs
+		updateStateContracts(d.tfPluginClient.State, oldDeploymentIDs, currentDeploymentIDs)
+	}
+
+	return report, err
+}
+
 // BatchDeploy deploys multiple clusters using the deployer
 func (d *K8sDeployer) BatchDeploy(ctx context.Context, k8sClusters []*workloads.K8sCluster) error {
 	newDeployments := make(map[uint32][]zosTypes.Deployment)
@@ -182,25 +264,124 @@ func (d *K8sDeployer) BatchDeploy(ctx context.Context, k8sClusters []*workloads.
 	return err
 }
 
+// Scale sets the number of workers of a node pool of the cluster. new workers are deployed on nodes
+// picked with the pool node filter after extending the cluster network to them, and the workers with
+// the highest indices are removed first. the network keeps its nodes as other workloads could use them
+func (d *K8sDeployer) Scale(ctx context.Context, k8sCluster *workloads.K8sCluster, poolName string, size int) error {
+	pool, err := k8sCluster.NodePool(poolName)
+	if err != nil {
+		return err
+	}
+
+	if err := pool.Validate(); err != nil {
+		return errors.Wrapf(err, "node pool %s is invalid", poolName)
+	}
+
+	if err := pool.ValidateSize(size); err != nil {
+		return err
+	}
+
+	poolWorkers := k8sCluster.PoolWorkers(poolName)
+	if size == len(poolWorkers) {
+		return nil
+	}
+
+	if size < len(poolWorkers) {
+		removed := make(map[string]bool)
+		for _, w := range poolWorkers[size:] {
+			removed[w.Name] = true
+		}
+
+		k8sCluster.Workers = slices.DeleteFunc(k8sCluster.Workers, func(w workloads.K8sNode) bool {
+			return removed[w.Name]
+		})
+
+		return d.Deploy(ctx, k8sCluster)
+	}
+
+	filter, disks, rootfss := k8sPoolFilter(*pool)
+	nodes, err := FilterNodes(ctx, *d.tfPluginClient, filter, disks, nil, rootfss, uint64(size-len(poolWorkers)))
+	if err != nil {
+		return errors.Wrapf(err, "failed to find nodes for node pool %s", poolName)
+	}
+
+	if len(nodes) < size-len(poolWorkers) {
+		return errors.Errorf("found %d nodes for node pool %s, %d nodes are needed", len(nodes), poolName, size-len(poolWorkers))
+	}
+
+	var nodeIDs []uint32
+	for _, node := range nodes {
+		nodeIDs = append(nodeIDs, uint32(node.NodeID))
+	}
+
+	newWorkers, err := newPoolWorkers(*pool, poolWorkers, nodeIDs, k8sCluster.NetworkName)
+	if err != nil {
+		return err
+	}
+
+	network, err := d.tfPluginClient.State.LoadNetworkFromGrid(ctx, k8sCluster.NetworkName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load network %s", k8sCluster.NetworkName)
+	}
+
+	extended := false
+	for _, w := range newWorkers {
+		if slices.Contains(network.Nodes, w.NodeID) {
+			continue
+		}
+
+		network.Nodes = append(network.Nodes, w.NodeID)
+		extended = true
+		if len(w.MyceliumIPSeed) != 0 && len(network.MyceliumKeys[w.NodeID]) == 0 {
+			key, err := workloads.RandomMyceliumKey()
+			if err != nil {
+				return errors.Wrap(err, "failed to generate mycelium key")
+			}
+			network.MyceliumKeys[w.NodeID] = key
+		}
+	}
+
+	if extended {
+		if err := d.tfPluginClient.NetworkDeployer.Deploy(ctx, &network); err != nil {
+			return errors.Wrapf(err, "failed to extend network %s to nodes %v", network.Name, network.Nodes)
+		}
+	}
+
+	k8sCluster.Workers = append(k8sCluster.Workers, newWorkers...)
+	return d.Deploy(ctx, k8sCluster)
+}
+
 // Cancel cancels a k8s cluster deployment
 func (d *K8sDeployer) Cancel(ctx context.Context, k8sCluster *workloads.K8sCluster) (err error) {
+nodes:
 	for nodeID, contractID := range k8sCluster.NodeDeploymentID {
 		if k8sCluster.Master.NodeID == nodeID {
 			err = d.deployer.Cancel(ctx, contractID)
 			if err != nil {
 				return errors.Wrapf(err, "could not cancel master %s, contract %d", k8sCluster.Master.Name, contractID)
 			}
-			d.tfPluginClient.State.CurrentNodeDeployments[nodeID] = workloads.Delete(d.tfPluginClient.State.CurrentNodeDeployments[nodeID], contractID)
+			d.tfPluginClient.State.RemoveContractIDs(nodeID, contractID)
 			delete(k8sCluster.NodeDeploymentID, nodeID)
 			continue
 		}
+		for _, master := range k8sCluster.Masters {
+			if master.NodeID == nodeID {
+				err = d.deployer.Cancel(ctx, contractID)
+				if err != nil {
+					return errors.Wrapf(err, "could not cancel master %s, contract %d", master.Name, contractID)
+				}
+				d.tfPluginClient.State.RemoveContractIDs(nodeID, contractID)
+				delete(k8sCluster.NodeDeploymentID, nodeID)
+				continue nodes
+			}
+		}
 		for _, worker := range k8sCluster.Workers {
 			if worker.NodeID == nodeID {
 				err = d.deployer.Cancel(ctx, contractID)
 				if err != nil {
 					return errors.Wrapf(err, "could not cancel worker %s, contract %d", worker.Name, contractID)
 				}
-				d.tfPluginClient.State.CurrentNodeDeployments[nodeID] = workloads.Delete(d.tfPluginClient.State.CurrentNodeDeployments[nodeID], contractID)
+				d.tfPluginClient.State.RemoveContractIDs(nodeID, contractID)
 				delete(k8sCluster.NodeDeploymentID, nodeID)
 				break
 			}
@@ -212,6 +393,9 @@ func (d *K8sDeployer) Cancel(ctx context.Context, k8sCluster *workloads.K8sClust
 
 func (d *K8sDeployer) updateStateFromDeployments(k8sCluster *workloads.K8sCluster, newDl map[uint32][]zosTypes.Deployment) error {
 	k8sNodes := []uint32{k8sCluster.Master.NodeID}
+	for _, m := range k8sCluster.Masters {
+		k8sNodes = append(k8sNodes, m.NodeID)
+	}
 	for _, w := range k8sCluster.Workers {
 		k8sNodes = append(k8sNodes, w.NodeID)
 	}
@@ -226,19 +410,18 @@ func (d *K8sDeployer) updateStateFromDeployments(k8sCluster *workloads.K8sCluste
 			}
 
 			if dlData.Name == k8sCluster.Master.Name {
-				k8sCluster.NodeDeploymentID[k8sCluster.Master.NodeID] = newDl.ContractID
+				k8sCluster.NodeDeploymentID[k8sNode] = newDl.ContractID
 			}
 		}
 	}
 
 	if contractID, ok := k8sCluster.NodeDeploymentID[k8sCluster.Master.NodeID]; ok && contractID != 0 {
-		if !workloads.Contains(d.tfPluginClient.State.CurrentNodeDeployments[k8sCluster.Master.NodeID], contractID) {
-			d.tfPluginClient.State.CurrentNodeDeployments[k8sCluster.Master.NodeID] = append(d.tfPluginClient.State.CurrentNodeDeployments[k8sCluster.Master.NodeID], contractID)
+		d.tfPluginClient.State.StoreContractIDs(k8sCluster.Master.NodeID, contractID)
+		for _, m := range k8sCluster.Masters {
+			d.tfPluginClient.State.StoreContractIDs(m.NodeID, k8sCluster.NodeDeploymentID[m.NodeID])
 		}
 		for _, w := range k8sCluster.Workers {
-			if !workloads.Contains(d.tfPluginClient.State.CurrentNodeDeployments[w.NodeID], k8sCluster.NodeDeploymentID[w.NodeID]) {
-				d.tfPluginClient.State.CurrentNodeDeployments[w.NodeID] = append(d.tfPluginClient.State.CurrentNodeDeployments[w.NodeID], k8sCluster.NodeDeploymentID[w.NodeID])
-			}
+			d.tfPluginClient.State.StoreContractIDs(w.NodeID, k8sCluster.NodeDeploymentID[w.NodeID])
 		}
 	}
 
@@ -266,7 +449,7 @@ func (d *K8sDeployer) UpdateFromRemote(ctx context.Context, k8sCluster *workload
 					zerolog.Error().Err(err).Msg("failed to get workload data")
 				}
 				SSHKey := d.(*zos.ZMachine).Env["SSH_KEY"]
-				token := d.(*zos.ZMachine).Env["K3S_TOKEN"]
+				token := workloads.K8sClusterToken(d.(*zos.ZMachine).Env)
 				networkName := string(d.(*zos.ZMachine).Network.Interfaces[0].Network)
 				if !keyUpdated && SSHKey != k8sCluster.SSHKey {
 					k8sCluster.SSHKey = SSHKey
@@ -348,6 +531,20 @@ func (d *K8sDeployer) UpdateFromRemote(ctx context.Context, k8sCluster *workload
 		}
 		k8sCluster.Master = &m
 	}
+	// update joining masters
+	masters := make([]workloads.K8sNode, 0)
+	for _, m := range k8sCluster.Masters {
+		masterNodeID, ok := workloadNodeID[m.Name]
+		if !ok {
+			continue
+		}
+		delete(workloadNodeID, m.Name)
+		m, err := workloads.NewK8sNodeFromWorkload(workloadObj[m.Name], masterNodeID, workloadDiskSize[m.Name], workloadComputedIP[m.Name], workloadComputedIP6[m.Name])
+		if err != nil {
+			return errors.Wrap(err, "failed to get master data from workload")
+		}
+		masters = append(masters, m)
+	}
 	// update workers
 	workers := make([]workloads.K8sNode, 0)
 	for _, w := range k8sCluster.Workers {
@@ -368,7 +565,7 @@ func (d *K8sDeployer) UpdateFromRemote(ctx context.Context, k8sCluster *workload
 		}
 		workers = append(workers, w)
 	}
-	// add missing workers (in case of failed deletions)
+	// add missing masters and workers (in case of failed deletions)
 	for name, workerNodeID := range workloadNodeID {
 		if name == k8sCluster.Master.Name {
 			continue
@@ -381,8 +578,13 @@ func (d *K8sDeployer) UpdateFromRemote(ctx context.Context, k8sCluster *workload
 		if err != nil {
 			return errors.Wrap(err, "failed to get worker data from workload")
 		}
+		if workloads.IsK8sJoiningMaster(w.EnvVars) {
+			masters = append(masters, w)
+			continue
+		}
 		workers = append(workers, w)
 	}
+	k8sCluster.Masters = masters
 	k8sCluster.Workers = workers
 	zerolog.Debug().Msg("after updateFromRemote\n")
 	enc := json.NewEncoder(log.Writer())
@@ -422,7 +624,7 @@ func (d *K8sDeployer) getK8sUsedIPs(k8s *workloads.K8sCluster) map[uint32][]byte
 		}
 	}
 
-	for _, w := range k8s.Workers {
+	for _, w := range append(slices.Clone(k8s.Masters), k8s.Workers...) {
 		if w.IP != "" {
 			ip := net.ParseIP(w.IP).To4()
 			if ip != nil {
@@ -462,6 +664,17 @@ func (d *K8sDeployer) assignNodesIPs(k8sCluster *workloads.K8sCluster) error {
 		}
 		k8sCluster.Master.IP = ip
 	}
+	for idx, m := range k8sCluster.Masters {
+		masterNodeRange := k8sCluster.NodesIPRange[m.NodeID]
+		if m.IP != "" && masterNodeRange.Contains(net.ParseIP(m.IP)) {
+			continue
+		}
+		ip, err := d.getK8sFreeIP(masterNodeRange, m.NodeID, k8sCluster)
+		if err != nil {
+			return errors.Wrap(err, "failed to find free ip for master")
+		}
+		k8sCluster.Masters[idx].IP = ip
+	}
 	for idx, w := range k8sCluster.Workers {
 		workerNodeRange := k8sCluster.NodesIPRange[w.NodeID]
 		if w.IP != "" && workerNodeRange.Contains(net.ParseIP(w.IP)) {
@@ -476,6 +689,67 @@ func (d *K8sDeployer) assignNodesIPs(k8sCluster *workloads.K8sCluster) error {
 	return nil
 }
 
+// k8sPoolFilter returns the node filter of new workers of the pool with the resources of its worker spec
+func k8sPoolFilter(pool workloads.K8sNodePool) (types.NodeFilter, []uint64, []uint64) {
+	filter := pool.NodeFilter
+	if filter.Status == nil {
+		filter.Status = []string{"up"}
+	}
+
+	if filter.FreeMRU == nil {
+		freeMRU := pool.Worker.MemoryMB * zosTypes.Megabyte
+		filter.FreeMRU = &freeMRU
+	}
+
+	if filter.FreeSRU == nil {
+		freeSRU := pool.Worker.DiskSizeGB * zosTypes.Gigabyte
+		filter.FreeSRU = &freeSRU
+	}
+
+	if filter.FreeIPs == nil && pool.Worker.PublicIP {
+		freeIPs := uint64(1)
+		filter.FreeIPs = &freeIPs
+	}
+
+	// k8s rootfs is 2 GB
+	return filter, []uint64{pool.Worker.DiskSizeGB * zosTypes.Gigabyte}, []uint64{2 * zosTypes.Gigabyte}
+}
+
+// newPoolWorkers returns workers of the pool on the given nodes indexed after its current workers
+func newPoolWorkers(pool workloads.K8sNodePool, poolWorkers []workloads.K8sNode, nodeIDs []uint32, networkName string) ([]workloads.K8sNode, error) {
+	next := 0
+	if len(poolWorkers) != 0 {
+		idx, _ := pool.WorkerIndex(poolWorkers[len(poolWorkers)-1].Name)
+		next = idx + 1
+	}
+
+	var workers []workloads.K8sNode
+	for i, nodeID := range nodeIDs {
+		vm := *pool.Worker.VM
+		vm.Name = pool.WorkerName(next + i)
+		vm.NodeID = nodeID
+		vm.NetworkName = networkName
+		vm.IP = ""
+		vm.ComputedIP = ""
+		vm.ComputedIP6 = ""
+		vm.PlanetaryIP = ""
+		vm.MyceliumIP = ""
+
+		// each worker needs its own mycelium ip
+		if len(pool.Worker.MyceliumIPSeed) != 0 {
+			seed, err := workloads.RandomMyceliumIPSeed()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to generate mycelium ip seed")
+			}
+			vm.MyceliumIPSeed = seed
+		}
+
+		workers = append(workers, workloads.K8sNode{VM: &vm, DiskSizeGB: pool.Worker.DiskSizeGB})
+	}
+
+	return workers, nil
+}
+
 func assignNodesFlistsAndEntryPoints(k *workloads.K8sCluster) {
 	if k.Flist == "" {
 		k.Flist = k.Master.Flist
@@ -490,6 +764,10 @@ func assignNodesFlistsAndEntryPoints(k *workloads.K8sCluster) {
 
 	k.Master.Flist = k.Flist
 	k.Master.Entrypoint = k.Entrypoint
+	for i := range k.Masters {
+		k.Masters[i].Flist = k.Flist
+		k.Masters[i].Entrypoint = k.Entrypoint
+	}
 	for i := range k.Workers {
 		k.Workers[i].Flist = k.Flist
 		k.Workers[i].Entrypoint = k.Entrypoint
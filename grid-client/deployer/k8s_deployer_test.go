@@ -503,3 +503,80 @@ func ExampleK8sDeployer_Cancel() {
 	}
 	fmt.Println("deployment is canceled successfully")
 }
+
+func TestK8sCancelJoiningMasters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	ncPool := mocks.NewMockNodeClientGetter(ctrl)
+	deployer := mocks.NewMockDeployer(ctrl)
+
+	d := K8sDeployer{
+		tfPluginClient: &TFPluginClient{State: state.NewState(ncPool, sub)},
+		deployer:       deployer,
+	}
+
+	// the joining master and the worker on node 2 share the same deployment
+	cluster := workloads.K8sCluster{
+		Master:           &workloads.K8sNode{VM: &workloads.VM{Name: "master", NodeID: 1}},
+		Masters:          []workloads.K8sNode{{VM: &workloads.VM{Name: "master1", NodeID: 2}}},
+		Workers:          []workloads.K8sNode{{VM: &workloads.VM{Name: "worker", NodeID: 2}}},
+		NodeDeploymentID: map[uint32]uint64{1: 10, 2: 20},
+	}
+
+	deployer.EXPECT().Cancel(gomock.Any(), uint64(10)).Return(nil)
+	deployer.EXPECT().Cancel(gomock.Any(), uint64(20)).Return(nil)
+
+	err := d.Cancel(context.Background(), &cluster)
+	assert.NoError(t, err)
+	assert.Empty(t, cluster.NodeDeploymentID)
+}
+
+func TestK8sNodePools(t *testing.T) {
+	pool := workloads.K8sNodePool{
+		Name: "pool",
+		Worker: workloads.K8sNode{
+			VM: &workloads.VM{
+				Name:           "spec",
+				CPU:            2,
+				MemoryMB:       2048,
+				PublicIP:       true,
+				IP:             "10.1.2.2",
+				MyceliumIPSeed: []byte{1, 2, 3, 4, 5, 6},
+			},
+			DiskSizeGB: 10,
+		},
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		filter, disks, rootfss := k8sPoolFilter(pool)
+		assert.Equal(t, []string{"up"}, filter.Status)
+		assert.Equal(t, uint64(2048*zosTypes.Megabyte), *filter.FreeMRU)
+		assert.Equal(t, uint64(10*zosTypes.Gigabyte), *filter.FreeSRU)
+		assert.Equal(t, uint64(1), *filter.FreeIPs)
+		assert.Equal(t, []uint64{10 * zosTypes.Gigabyte}, disks)
+		assert.Equal(t, []uint64{2 * zosTypes.Gigabyte}, rootfss)
+		assert.Nil(t, pool.NodeFilter.FreeMRU)
+	})
+
+	t.Run("new workers", func(t *testing.T) {
+		current := []workloads.K8sNode{{VM: &workloads.VM{Name: "pool0"}}, {VM: &workloads.VM{Name: "pool3"}}}
+
+		workers, err := newPoolWorkers(pool, current, []uint32{11, 12}, "network")
+		assert.NoError(t, err)
+		assert.Len(t, workers, 2)
+
+		assert.Equal(t, "pool4", workers[0].Name)
+		assert.Equal(t, uint32(11), workers[0].NodeID)
+		assert.Equal(t, "pool5", workers[1].Name)
+		assert.Equal(t, "network", workers[1].NetworkName)
+		assert.Empty(t, workers[1].IP)
+		assert.Equal(t, uint64(10), workers[1].DiskSizeGB)
+		assert.Len(t, workers[0].MyceliumIPSeed, 6)
+		assert.NotEqual(t, workers[0].MyceliumIPSeed, workers[1].MyceliumIPSeed)
+
+		// the spec is not changed
+		assert.Equal(t, "spec", pool.Worker.Name)
+	})
+}
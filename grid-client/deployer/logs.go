@@ -0,0 +1,216 @@
+package deployer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+)
+
+// logLinesBuffer is the number of collected log lines buffered before the collector drops lines
+const logLinesBuffer = 1024
+
+// AttachZlog streams the output of a vm of the deployment to the output url by adding a zlog to
+// the vm and redeploying the deployment. zos supports redis, ws and wss outputs
+func (t *TFPluginClient) AttachZlog(ctx context.Context, dl *workloads.Deployment, vmName, output string) error {
+	zlogs, err := vmZlogs(dl, vmName)
+	if err != nil {
+		return err
+	}
+
+	zlog := workloads.Zlog{Zmachine: vmName, Output: output}
+	if err := zlog.Validate(); err != nil {
+		return errors.Wrap(err, "invalid zlog")
+	}
+
+	if slices.Contains(*zlogs, zlog) {
+		return nil
+	}
+
+	*zlogs = append(*zlogs, zlog)
+	if err := t.DeploymentDeployer.Deploy(ctx, dl); err != nil {
+		return errors.Wrapf(err, "failed to attach zlog to vm %s", vmName)
+	}
+
+	return nil
+}
+
+// DetachZlog stops streaming the output of a vm of the deployment to the output url
+func (t *TFPluginClient) DetachZlog(ctx context.Context, dl *workloads.Deployment, vmName, output string) error {
+	zlogs, err := vmZlogs(dl, vmName)
+	if err != nil {
+		return err
+	}
+
+	idx := slices.Index(*zlogs, workloads.Zlog{Zmachine: vmName, Output: output})
+	if idx == -1 {
+		return nil
+	}
+
+	*zlogs = slices.Delete(*zlogs, idx, idx+1)
+	if err := t.DeploymentDeployer.Deploy(ctx, dl); err != nil {
+		return errors.Wrapf(err, "failed to detach zlog from vm %s", vmName)
+	}
+
+	return nil
+}
+
+// vmZlogs returns the zlogs of a vm or a light vm of the deployment
+func vmZlogs(dl *workloads.Deployment, vmName string) (*[]workloads.Zlog, error) {
+	for i := range dl.Vms {
+		if dl.Vms[i].Name == vmName {
+			return &dl.Vms[i].Zlogs, nil
+		}
+	}
+
+	for i := range dl.VmsLight {
+		if dl.VmsLight[i].Name == vmName {
+			return &dl.VmsLight[i].Zlogs, nil
+		}
+	}
+
+	return nil, errors.Errorf("could not find vm %s in deployment %s", vmName, dl.Name)
+}
+
+// LogCollector is a websocket server receiving the vm logs streamed by zlogs,
+// the nodes of the vms must be able to reach its advertised address
+type LogCollector struct {
+	listener net.Listener
+	server   *http.Server
+	output   string
+	lines    chan string
+
+	m      sync.Mutex
+	closed bool
+	conns  map[*websocket.Conn]struct{}
+	wg     sync.WaitGroup
+}
+
+// NewLogCollector starts a log collector listening on listenAddr, the zlogs output is a ws url of advertiseAddr
+// which is usually the collector address on the mycelium or yggdrasil networks
+func NewLogCollector(listenAddr, advertiseAddr string) (*LogCollector, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, errors.Wrap(err, "failed to generate collector token")
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on %s", listenAddr)
+	}
+
+	path := "/" + hex.EncodeToString(token)
+	c := &LogCollector{
+		listener: listener,
+		output:   fmt.Sprintf("ws://%s%s", advertiseAddr, path),
+		lines:    make(chan string, logLinesBuffer),
+		conns:    make(map[*websocket.Conn]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, c.collect)
+	c.server = &http.Server{Handler: mux}
+
+	go func() {
+		if err := c.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("log collector stopped")
+		}
+	}()
+
+	return c, nil
+}
+
+// Output is the zlog output url of the collector
+func (c *LogCollector) Output() string {
+	return c.output
+}
+
+// Addr is the address the collector listens on
+func (c *LogCollector) Addr() net.Addr {
+	return c.listener.Addr()
+}
+
+// Lines returns the collected log lines, the channel is closed when the collector is closed
+func (c *LogCollector) Lines() <-chan string {
+	return c.lines
+}
+
+// Close stops the collector and closes its nodes connections
+func (c *LogCollector) Close() error {
+	c.m.Lock()
+	if c.closed {
+		c.m.Unlock()
+		return nil
+	}
+	c.closed = true
+
+	err := c.server.Close()
+	// websocket connections are hijacked and not closed by the server
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.m.Unlock()
+
+	c.wg.Wait()
+	close(c.lines)
+
+	return err
+}
+
+func (c *LogCollector) collect(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		// nodes are not browsers and send no origin
+		CheckOrigin: func(*http.Request) bool { return true },
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to upgrade log collector connection")
+		return
+	}
+
+	c.m.Lock()
+	if c.closed {
+		c.m.Unlock()
+		conn.Close()
+		return
+	}
+	c.conns[conn] = struct{}{}
+	c.wg.Add(1)
+	c.m.Unlock()
+
+	defer func() {
+		c.m.Lock()
+		delete(c.conns, conn)
+		c.m.Unlock()
+
+		conn.Close()
+		c.wg.Done()
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		for _, line := range strings.Split(strings.TrimRight(string(message), "\n"), "\n") {
+			// a slow reader must not block the node stream
+			select {
+			case c.lines <- line:
+			default:
+				log.Warn().Msg("log collector buffer is full, dropping log line")
+			}
+		}
+	}
+}
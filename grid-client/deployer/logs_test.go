@@ -0,0 +1,69 @@
+package deployer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+)
+
+func TestLogCollector(t *testing.T) {
+	collector, err := NewLogCollector("127.0.0.1:0", "[300:1::1]:8090")
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(collector.Output(), "ws://[300:1::1]:8090/"))
+	require.NoError(t, (&workloads.Zlog{Zmachine: "vm", Output: collector.Output()}).Validate())
+
+	path := strings.TrimPrefix(collector.Output(), "ws://[300:1::1]:8090")
+	url := "ws://" + collector.Addr().String()
+
+	_, _, err = websocket.DefaultDialer.Dial(url+"/logs", nil)
+	assert.Error(t, err, "only the collector output path should be accepted")
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+path, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("booting\nmounting disks\n")))
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte("ready")))
+
+	var lines []string
+	for len(lines) < 3 {
+		select {
+		case line := <-collector.Lines():
+			lines = append(lines, line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timeout waiting for log lines", "got %v", lines)
+		}
+	}
+	assert.Equal(t, []string{"booting", "mounting disks", "ready"}, lines)
+
+	require.NoError(t, collector.Close())
+	_, ok := <-collector.Lines()
+	assert.False(t, ok)
+
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err)
+}
+
+func TestVMZlogs(t *testing.T) {
+	dl := workloads.Deployment{
+		Name:     "dl",
+		Vms:      []workloads.VM{{Name: "vm"}},
+		VmsLight: []workloads.VMLight{{Name: "light"}},
+	}
+
+	zlogs, err := vmZlogs(&dl, "light")
+	require.NoError(t, err)
+	*zlogs = append(*zlogs, workloads.Zlog{Zmachine: "light", Output: "redis://codescalers1.com"})
+	assert.Len(t, dl.VmsLight[0].Zlogs, 1)
+
+	_, err = vmZlogs(&dl, "vm")
+	require.NoError(t, err)
+
+	_, err = vmZlogs(&dl, "missing")
+	assert.Error(t, err)
+}
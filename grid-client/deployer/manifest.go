@@ -0,0 +1,838 @@
+package deployer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"gopkg.in/yaml.v3"
+)
+
+// manifestRef matches references to other workloads outputs, e.g. ${web.mycelium_ip}
+var manifestRef = regexp.MustCompile(`\$\{([a-zA-Z0-9_]+)\.([a-z0-9_]+)\}`)
+
+const (
+	manifestNetwork     = "network"
+	manifestDeployment  = "deployment"
+	manifestKubernetes  = "kubernetes"
+	manifestNameGateway = "name_gateway"
+	manifestFQDNGateway = "fqdn_gateway"
+)
+
+// Manifest describes grid resources that are applied together.
+// VMs env vars and gateways backends can reference the outputs of other workloads using
+// ${<workload name>.<field>}, the fields are:
+//   - VMs and k8s nodes: ip, computed_ip, computed_ip6, planetary_ip, mycelium_ip
+//   - ZDBs: ip, port, namespace
+//   - gateways: fqdn
+type Manifest struct {
+	// Project is the solution type of all the manifest resources
+	Project      string                `yaml:"project" json:"project"`
+	Networks     []ManifestNetwork     `yaml:"networks" json:"networks"`
+	Deployments  []ManifestDeployment  `yaml:"deployments" json:"deployments"`
+	Kubernetes   []ManifestK8sCluster  `yaml:"kubernetes" json:"kubernetes"`
+	NameGateways []ManifestNameGateway `yaml:"name_gateways" json:"name_gateways"`
+	FQDNGateways []ManifestFQDNGateway `yaml:"fqdn_gateways" json:"fqdn_gateways"`
+}
+
+// ManifestNetwork is a network of a manifest, it is deployed on its nodes and the nodes of the resources using it
+type ManifestNetwork struct {
+	Name        string   `yaml:"name" json:"name"`
+	Description string   `yaml:"description" json:"description"`
+	Nodes       []uint32 `yaml:"nodes" json:"nodes"`
+	IPRange     string   `yaml:"ip_range" json:"ip_range"`
+	AddWGAccess bool     `yaml:"add_wg_access" json:"add_wg_access"`
+	Mycelium    bool     `yaml:"mycelium" json:"mycelium"`
+	Light       bool     `yaml:"light" json:"light"`
+}
+
+// ManifestDeployment is a node deployment of a manifest, its workloads use their json field names
+type ManifestDeployment struct {
+	Name        string              `yaml:"name" json:"name"`
+	NodeID      uint32              `yaml:"node_id" json:"node_id"`
+	NetworkName string              `yaml:"network_name" json:"network_name"`
+	VMs         []workloads.VM      `yaml:"vms" json:"vms"`
+	VMsLight    []workloads.VMLight `yaml:"vms_light" json:"vms_light"`
+	Disks       []workloads.Disk    `yaml:"disks" json:"disks"`
+	Volumes     []workloads.Volume  `yaml:"volumes" json:"volumes"`
+	ZDBs        []workloads.ZDB     `yaml:"zdbs" json:"zdbs"`
+	QSFS        []workloads.QSFS    `yaml:"qsfs" json:"qsfs"`
+}
+
+// ManifestK8sCluster is a k8s cluster of a manifest, it is named after its master
+type ManifestK8sCluster struct {
+	NetworkName string              `yaml:"network_name" json:"network_name"`
+	Token       string              `yaml:"token" json:"token"`
+	SSHKey      string              `yaml:"ssh_key" json:"ssh_key"`
+	Flist       string              `yaml:"flist" json:"flist"`
+	APIServer   string              `yaml:"api_server" json:"api_server"`
+	Master      workloads.K8sNode   `yaml:"master" json:"master"`
+	Masters     []workloads.K8sNode `yaml:"masters" json:"masters"`
+	Workers     []workloads.K8sNode `yaml:"workers" json:"workers"`
+}
+
+// ManifestNameGateway is a name gateway of a manifest
+type ManifestNameGateway struct {
+	Name           string   `yaml:"name" json:"name"`
+	NodeID         uint32   `yaml:"node_id" json:"node_id"`
+	Backends       []string `yaml:"backends" json:"backends"`
+	TLSPassthrough bool     `yaml:"tls_passthrough" json:"tls_passthrough"`
+	Network        string   `yaml:"network" json:"network"`
+	Description    string   `yaml:"description" json:"description"`
+}
+
+// ManifestFQDNGateway is a fqdn gateway of a manifest
+type ManifestFQDNGateway struct {
+	Name           string   `yaml:"name" json:"name"`
+	NodeID         uint32   `yaml:"node_id" json:"node_id"`
+	FQDN           string   `yaml:"fqdn" json:"fqdn"`
+	Backends       []string `yaml:"backends" json:"backends"`
+	TLSPassthrough bool     `yaml:"tls_passthrough" json:"tls_passthrough"`
+	Network        string   `yaml:"network" json:"network"`
+	Description    string   `yaml:"description" json:"description"`
+}
+
+// AppliedManifest holds the deployed resources of a manifest by name
+type AppliedManifest struct {
+	Networks     map[string]workloads.Network
+	Deployments  map[string]*workloads.Deployment
+	K8sClusters  map[string]*workloads.K8sCluster
+	NameGateways map[string]*workloads.GatewayNameProxy
+	FQDNGateways map[string]*workloads.GatewayFQDNProxy
+}
+
+// manifestResource is a resource of a manifest with the resources it depends on
+type manifestResource struct {
+	kind string
+	name string
+	deps map[string]struct{}
+}
+
+func (r manifestResource) key() string {
+	return r.kind + "/" + r.name
+}
+
+// ParseManifest parses a yaml or json manifest
+func ParseManifest(data []byte) (Manifest, error) {
+	// yaml is decoded then encoded as json so that workloads are read with their json field names
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return Manifest{}, errors.Wrap(err, "could not parse manifest")
+	}
+
+	jsonData, err := json.Marshal(raw)
+	if err != nil {
+		return Manifest{}, errors.Wrap(err, "could not parse manifest")
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(jsonData, &manifest); err != nil {
+		return Manifest{}, errors.Wrap(err, "could not parse manifest")
+	}
+
+	return manifest, nil
+}
+
+// LoadManifest reads and parses a yaml or json manifest file
+func LoadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, errors.Wrapf(err, "could not read manifest %s", path)
+	}
+
+	return ParseManifest(data)
+}
+
+// Apply deploys the manifest resources after their dependencies: networks first, then every
+// resource after the resources its references point to
+func (t *TFPluginClient) Apply(ctx context.Context, manifest Manifest) (AppliedManifest, error) {
+	applied := AppliedManifest{
+		Networks:     make(map[string]workloads.Network),
+		Deployments:  make(map[string]*workloads.Deployment),
+		K8sClusters:  make(map[string]*workloads.K8sCluster),
+		NameGateways: make(map[string]*workloads.GatewayNameProxy),
+		FQDNGateways: make(map[string]*workloads.GatewayFQDNProxy),
+	}
+
+	order, err := manifest.order()
+	if err != nil {
+		return applied, err
+	}
+
+	outputs := make(map[string]map[string]string)
+	for _, resource := range order {
+		log.Info().Str("kind", resource.kind).Str("name", resource.name).Msg("applying")
+
+		if err := t.applyResource(ctx, &manifest, resource, outputs, &applied); err != nil {
+			return applied, errors.Wrapf(err, "failed to apply %s %s", resource.kind, resource.name)
+		}
+	}
+
+	return applied, nil
+}
+
+func (t *TFPluginClient) applyResource(
+	ctx context.Context,
+	manifest *Manifest,
+	resource manifestResource,
+	outputs map[string]map[string]string,
+	applied *AppliedManifest,
+) error {
+	switch resource.kind {
+	case manifestNetwork:
+		for _, n := range manifest.Networks {
+			if n.Name == resource.name {
+				return t.applyNetwork(ctx, manifest, n, applied)
+			}
+		}
+
+	case manifestDeployment:
+		for _, d := range manifest.Deployments {
+			if d.Name == resource.name {
+				return t.applyDeployment(ctx, manifest, d, outputs, applied)
+			}
+		}
+
+	case manifestKubernetes:
+		for _, k := range manifest.Kubernetes {
+			if k.Master.Name == resource.name {
+				return t.applyK8sCluster(ctx, manifest, k, outputs, applied)
+			}
+		}
+
+	case manifestNameGateway:
+		for _, gw := range manifest.NameGateways {
+			if gw.Name == resource.name {
+				return t.applyNameGateway(ctx, manifest.Project, gw, outputs, applied)
+			}
+		}
+
+	case manifestFQDNGateway:
+		for _, gw := range manifest.FQDNGateways {
+			if gw.Name == resource.name {
+				return t.applyFQDNGateway(ctx, manifest.Project, gw, outputs, applied)
+			}
+		}
+	}
+
+	return errors.Errorf("unknown resource %s", resource.key())
+}
+
+func (t *TFPluginClient) applyNetwork(ctx context.Context, manifest *Manifest, n ManifestNetwork, applied *AppliedManifest) error {
+	ipRange, err := zosTypes.ParseIPNet(n.IPRange)
+	if err != nil {
+		return errors.Wrapf(err, "invalid ip range %s", n.IPRange)
+	}
+
+	nodes := manifest.networkNodes(n)
+
+	var myceliumKeys map[uint32][]byte
+	if n.Mycelium {
+		myceliumKeys = make(map[uint32][]byte)
+		for _, node := range nodes {
+			if myceliumKeys[node], err = workloads.RandomMyceliumKey(); err != nil {
+				return errors.Wrap(err, "could not generate mycelium key")
+			}
+		}
+	}
+
+	var network workloads.Network
+	if n.Light {
+		network = &workloads.ZNetLight{
+			Name:         n.Name,
+			Description:  n.Description,
+			Nodes:        nodes,
+			IPRange:      ipRange,
+			MyceliumKeys: myceliumKeys,
+			SolutionType: manifest.Project,
+		}
+	} else {
+		network = &workloads.ZNet{
+			Name:         n.Name,
+			Description:  n.Description,
+			Nodes:        nodes,
+			IPRange:      ipRange,
+			AddWGAccess:  n.AddWGAccess,
+			MyceliumKeys: myceliumKeys,
+			SolutionType: manifest.Project,
+		}
+	}
+
+	if err := t.NetworkDeployer.Deploy(ctx, network); err != nil {
+		return err
+	}
+
+	applied.Networks[n.Name] = network
+	return nil
+}
+
+func (t *TFPluginClient) applyDeployment(
+	ctx context.Context,
+	manifest *Manifest,
+	d ManifestDeployment,
+	outputs map[string]map[string]string,
+	applied *AppliedManifest,
+) error {
+	dl, err := manifest.deployment(d, outputs)
+	if err != nil {
+		return err
+	}
+
+	if err := t.DeploymentDeployer.Deploy(ctx, &dl); err != nil {
+		return err
+	}
+
+	deployed, err := t.State.LoadDeploymentFromGrid(ctx, d.NodeID, d.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not load deployment %s", d.Name)
+	}
+
+	deploymentOutputs(deployed, outputs)
+	applied.Deployments[d.Name] = &deployed
+	return nil
+}
+
+func (t *TFPluginClient) applyK8sCluster(
+	ctx context.Context,
+	manifest *Manifest,
+	k ManifestK8sCluster,
+	outputs map[string]map[string]string,
+	applied *AppliedManifest,
+) error {
+	cluster, err := manifest.k8sCluster(k, outputs)
+	if err != nil {
+		return err
+	}
+
+	if err := t.K8sDeployer.Deploy(ctx, &cluster); err != nil {
+		return err
+	}
+
+	nodeIDs := []uint32{cluster.Master.NodeID}
+	for _, node := range append(slices.Clone(cluster.Masters), cluster.Workers...) {
+		nodeIDs = append(nodeIDs, node.NodeID)
+	}
+
+	deployed, err := t.State.LoadK8sFromGrid(ctx, nodeIDs, k.Master.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not load k8s cluster %s", k.Master.Name)
+	}
+
+	k8sOutputs(deployed, outputs)
+	applied.K8sClusters[k.Master.Name] = &deployed
+	return nil
+}
+
+// deployment returns the workloads deployment of a manifest deployment with its references resolved,
+// vms on mycelium networks get a mycelium ip seed if they have none
+func (m *Manifest) deployment(d ManifestDeployment, outputs map[string]map[string]string) (workloads.Deployment, error) {
+	// the manifest workloads are copied so that resolved references don't change it
+	d.VMs = slices.Clone(d.VMs)
+	d.VMsLight = slices.Clone(d.VMsLight)
+	mycelium := m.myceliumNetwork(d.NetworkName)
+
+	var err error
+	for i := range d.VMs {
+		d.VMs[i].NodeID = d.NodeID
+		d.VMs[i].NetworkName = d.NetworkName
+		if d.VMs[i].EnvVars, err = resolveEnvVars(d.VMs[i].EnvVars, outputs); err != nil {
+			return workloads.Deployment{}, err
+		}
+		if d.VMs[i].MyceliumIPSeed, err = myceliumIPSeed(d.VMs[i].MyceliumIPSeed, mycelium); err != nil {
+			return workloads.Deployment{}, err
+		}
+	}
+
+	for i := range d.VMsLight {
+		d.VMsLight[i].NodeID = d.NodeID
+		d.VMsLight[i].NetworkName = d.NetworkName
+		if d.VMsLight[i].EnvVars, err = resolveEnvVars(d.VMsLight[i].EnvVars, outputs); err != nil {
+			return workloads.Deployment{}, err
+		}
+		if d.VMsLight[i].MyceliumIPSeed, err = myceliumIPSeed(d.VMsLight[i].MyceliumIPSeed, mycelium); err != nil {
+			return workloads.Deployment{}, err
+		}
+	}
+
+	return workloads.NewDeployment(d.Name, d.NodeID, m.Project, nil, d.NetworkName, d.Disks, d.ZDBs, d.VMs, d.VMsLight, d.QSFS, d.Volumes), nil
+}
+
+// k8sCluster returns the k8s cluster of a manifest cluster with its references resolved,
+// nodes on mycelium networks get a mycelium ip seed if they have none
+func (m *Manifest) k8sCluster(k ManifestK8sCluster, outputs map[string]map[string]string) (workloads.K8sCluster, error) {
+	nodes := append([]workloads.K8sNode{k.Master}, k.Masters...)
+	nodes = append(nodes, k.Workers...)
+	mycelium := m.myceliumNetwork(k.NetworkName)
+
+	var err error
+	for i := range nodes {
+		vm := *nodes[i].VM
+		nodes[i].VM = &vm
+		nodes[i].NetworkName = k.NetworkName
+		if nodes[i].EnvVars, err = resolveEnvVars(nodes[i].EnvVars, outputs); err != nil {
+			return workloads.K8sCluster{}, err
+		}
+		if nodes[i].MyceliumIPSeed, err = myceliumIPSeed(nodes[i].MyceliumIPSeed, mycelium); err != nil {
+			return workloads.K8sCluster{}, err
+		}
+	}
+
+	return workloads.K8sCluster{
+		Master:       &nodes[0],
+		Masters:      nodes[1 : len(k.Masters)+1],
+		Workers:      nodes[len(k.Masters)+1:],
+		Token:        k.Token,
+		NetworkName:  k.NetworkName,
+		Flist:        k.Flist,
+		SSHKey:       k.SSHKey,
+		APIServer:    k.APIServer,
+		SolutionType: m.Project,
+	}, nil
+}
+
+func (t *TFPluginClient) applyNameGateway(
+	ctx context.Context,
+	project string,
+	gw ManifestNameGateway,
+	outputs map[string]map[string]string,
+	applied *AppliedManifest,
+) error {
+	backends, err := resolveBackends(gw.Backends, outputs)
+	if err != nil {
+		return err
+	}
+
+	gateway := workloads.GatewayNameProxy{
+		NodeID:         gw.NodeID,
+		Name:           gw.Name,
+		Backends:       backends,
+		TLSPassthrough: gw.TLSPassthrough,
+		Network:        gw.Network,
+		Description:    gw.Description,
+		SolutionType:   project,
+	}
+
+	if err := t.GatewayNameDeployer.Deploy(ctx, &gateway); err != nil {
+		return err
+	}
+
+	deployed, err := t.State.LoadGatewayNameFromGrid(ctx, gw.NodeID, gw.Name, gw.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not load gateway %s", gw.Name)
+	}
+
+	outputs[gw.Name] = map[string]string{"fqdn": deployed.FQDN}
+	applied.NameGateways[gw.Name] = &deployed
+	return nil
+}
+
+func (t *TFPluginClient) applyFQDNGateway(
+	ctx context.Context,
+	project string,
+	gw ManifestFQDNGateway,
+	outputs map[string]map[string]string,
+	applied *AppliedManifest,
+) error {
+	backends, err := resolveBackends(gw.Backends, outputs)
+	if err != nil {
+		return err
+	}
+
+	gateway := workloads.GatewayFQDNProxy{
+		NodeID:         gw.NodeID,
+		Name:           gw.Name,
+		FQDN:           gw.FQDN,
+		Backends:       backends,
+		TLSPassthrough: gw.TLSPassthrough,
+		Network:        gw.Network,
+		Description:    gw.Description,
+		SolutionType:   project,
+	}
+
+	if err := t.GatewayFQDNDeployer.Deploy(ctx, &gateway); err != nil {
+		return err
+	}
+
+	outputs[gw.Name] = map[string]string{"fqdn": gw.FQDN}
+	applied.FQDNGateways[gw.Name] = &gateway
+	return nil
+}
+
+// order sorts the manifest resources so every resource comes after its dependencies
+func (m *Manifest) order() ([]manifestResource, error) {
+	resources, err := m.resources()
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]manifestResource)
+	for _, resource := range resources {
+		byKey[resource.key()] = resource
+	}
+
+	var order []manifestResource
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		if done[key] {
+			return nil
+		}
+		if visiting[key] {
+			return errors.Errorf("dependency cycle: %s", strings.Join(append(path, key), " -> "))
+		}
+		visiting[key] = true
+
+		resource := byKey[key]
+		deps := make([]string, 0, len(resource.deps))
+		for dep := range resource.deps {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+
+		for _, dep := range deps {
+			if _, ok := byKey[dep]; !ok {
+				return errors.Errorf("%s depends on unknown %s", key, dep)
+			}
+			if err := visit(dep, append(path, key)); err != nil {
+				return err
+			}
+		}
+
+		visiting[key] = false
+		done[key] = true
+		order = append(order, resource)
+		return nil
+	}
+
+	for _, resource := range resources {
+		if err := visit(resource.key(), nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// resources lists the manifest resources with their dependencies on networks and referenced workloads
+func (m *Manifest) resources() ([]manifestResource, error) {
+	var resources []manifestResource
+	keys := make(map[string]bool)
+	// owners maps workload names to the resources holding them
+	owners := make(map[string]string)
+
+	add := func(kind, name string, workloadNames ...string) error {
+		resource := manifestResource{kind: kind, name: name, deps: make(map[string]struct{})}
+		if name == "" {
+			return errors.Errorf("%s name cannot be empty", kind)
+		}
+		if keys[resource.key()] {
+			return errors.Errorf("%s %s is defined more than once", kind, name)
+		}
+		keys[resource.key()] = true
+
+		for _, workloadName := range workloadNames {
+			if owner, ok := owners[workloadName]; ok {
+				return errors.Errorf("workload %s is defined in both %s and %s", workloadName, owner, resource.key())
+			}
+			owners[workloadName] = resource.key()
+		}
+
+		resources = append(resources, resource)
+		return nil
+	}
+
+	for _, n := range m.Networks {
+		if err := add(manifestNetwork, n.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, d := range m.Deployments {
+		var names []string
+		for _, vm := range d.VMs {
+			names = append(names, vm.Name)
+		}
+		for _, vm := range d.VMsLight {
+			names = append(names, vm.Name)
+		}
+		for _, zdb := range d.ZDBs {
+			names = append(names, zdb.Name)
+		}
+		if err := add(manifestDeployment, d.Name, names...); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range m.Kubernetes {
+		if k.Master.VM == nil {
+			return nil, errors.New("kubernetes cluster master cannot be empty")
+		}
+		names := []string{k.Master.Name}
+		for _, master := range k.Masters {
+			if master.VM == nil {
+				return nil, errors.Errorf("kubernetes cluster %s has an empty master", k.Master.Name)
+			}
+			names = append(names, master.Name)
+		}
+		for _, worker := range k.Workers {
+			if worker.VM == nil {
+				return nil, errors.Errorf("kubernetes cluster %s has an empty worker", k.Master.Name)
+			}
+			names = append(names, worker.Name)
+		}
+		if err := add(manifestKubernetes, k.Master.Name, names...); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, gw := range m.NameGateways {
+		if err := add(manifestNameGateway, gw.Name, gw.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, gw := range m.FQDNGateways {
+		if err := add(manifestFQDNGateway, gw.Name, gw.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	// dependencies
+	idx := len(m.Networks)
+	dependOn := func(resource *manifestResource, network string, values ...string) error {
+		if network != "" {
+			resource.deps[manifestNetwork+"/"+network] = struct{}{}
+		}
+		for _, value := range values {
+			for _, ref := range manifestRef.FindAllStringSubmatch(value, -1) {
+				owner, ok := owners[ref[1]]
+				if !ok {
+					return errors.Errorf("%s references unknown workload %s", resource.key(), ref[1])
+				}
+				if owner != resource.key() {
+					resource.deps[owner] = struct{}{}
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, d := range m.Deployments {
+		var values []string
+		for _, vm := range d.VMs {
+			values = append(values, envValues(vm.EnvVars)...)
+		}
+		for _, vm := range d.VMsLight {
+			values = append(values, envValues(vm.EnvVars)...)
+		}
+		if err := dependOn(&resources[idx], d.NetworkName, values...); err != nil {
+			return nil, err
+		}
+		idx++
+	}
+
+	for _, k := range m.Kubernetes {
+		values := envValues(k.Master.EnvVars)
+		for _, master := range k.Masters {
+			values = append(values, envValues(master.EnvVars)...)
+		}
+		for _, worker := range k.Workers {
+			values = append(values, envValues(worker.EnvVars)...)
+		}
+		if err := dependOn(&resources[idx], k.NetworkName, values...); err != nil {
+			return nil, err
+		}
+		idx++
+	}
+
+	for _, gw := range m.NameGateways {
+		if err := dependOn(&resources[idx], gw.Network, gw.Backends...); err != nil {
+			return nil, err
+		}
+		idx++
+	}
+
+	for _, gw := range m.FQDNGateways {
+		if err := dependOn(&resources[idx], gw.Network, gw.Backends...); err != nil {
+			return nil, err
+		}
+		idx++
+	}
+
+	return resources, nil
+}
+
+// networkNodes returns the nodes of a network along with the nodes of the resources using it
+func (m *Manifest) networkNodes(n ManifestNetwork) []uint32 {
+	nodes := make(map[uint32]struct{})
+	for _, node := range n.Nodes {
+		nodes[node] = struct{}{}
+	}
+
+	for _, d := range m.Deployments {
+		if d.NetworkName == n.Name {
+			nodes[d.NodeID] = struct{}{}
+		}
+	}
+
+	for _, k := range m.Kubernetes {
+		if k.NetworkName != n.Name {
+			continue
+		}
+		nodes[k.Master.NodeID] = struct{}{}
+		for _, master := range k.Masters {
+			nodes[master.NodeID] = struct{}{}
+		}
+		for _, worker := range k.Workers {
+			nodes[worker.NodeID] = struct{}{}
+		}
+	}
+
+	for _, gw := range m.NameGateways {
+		if gw.Network == n.Name {
+			nodes[gw.NodeID] = struct{}{}
+		}
+	}
+
+	for _, gw := range m.FQDNGateways {
+		if gw.Network == n.Name {
+			nodes[gw.NodeID] = struct{}{}
+		}
+	}
+
+	res := make([]uint32, 0, len(nodes))
+	for node := range nodes {
+		res = append(res, node)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+
+	return res
+}
+
+// myceliumNetwork returns true if the manifest network with the given name has mycelium enabled
+func (m *Manifest) myceliumNetwork(name string) bool {
+	for _, n := range m.Networks {
+		if n.Name == name {
+			return n.Mycelium
+		}
+	}
+	return false
+}
+
+// myceliumIPSeed returns the given seed or a new one if it is empty and the workload is on a mycelium network
+func myceliumIPSeed(seed []byte, mycelium bool) ([]byte, error) {
+	if len(seed) != 0 || !mycelium {
+		return seed, nil
+	}
+
+	seed, err := workloads.RandomMyceliumIPSeed()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate mycelium ip seed")
+	}
+	return seed, nil
+}
+
+// deploymentOutputs adds the outputs of the deployed deployment workloads
+func deploymentOutputs(deployed workloads.Deployment, outputs map[string]map[string]string) {
+	for _, vm := range deployed.Vms {
+		outputs[vm.Name] = vmOutputs(vm)
+	}
+
+	for _, vm := range deployed.VmsLight {
+		outputs[vm.Name] = map[string]string{"ip": vm.IP, "mycelium_ip": vm.MyceliumIP}
+	}
+
+	for _, zdb := range deployed.Zdbs {
+		outputs[zdb.Name] = map[string]string{"port": fmt.Sprint(zdb.Port), "namespace": zdb.Namespace}
+		if len(zdb.IPs) != 0 {
+			outputs[zdb.Name]["ip"] = zdb.IPs[0]
+		}
+	}
+}
+
+// k8sOutputs adds the outputs of the deployed cluster nodes
+func k8sOutputs(deployed workloads.K8sCluster, outputs map[string]map[string]string) {
+	outputs[deployed.Master.Name] = vmOutputs(*deployed.Master.VM)
+	for _, master := range deployed.Masters {
+		outputs[master.Name] = vmOutputs(*master.VM)
+	}
+	for _, worker := range deployed.Workers {
+		outputs[worker.Name] = vmOutputs(*worker.VM)
+	}
+}
+
+// resolveReferences replaces the references in a value with the outputs of the referenced workloads,
+// references to empty outputs, e.g. the mycelium ip of a vm without mycelium, are invalid
+func resolveReferences(value string, outputs map[string]map[string]string) (string, error) {
+	var err error
+	resolved := manifestRef.ReplaceAllStringFunc(value, func(ref string) string {
+		match := manifestRef.FindStringSubmatch(ref)
+		output, ok := outputs[match[1]][match[2]]
+		if !ok {
+			err = errors.Errorf("could not resolve %s, workload %s has no output %s", ref, match[1], match[2])
+		} else if output == "" {
+			err = errors.Errorf("could not resolve %s, output %s of workload %s is empty", ref, match[2], match[1])
+		}
+		return output
+	})
+
+	return resolved, err
+}
+
+// resolveEnvVars returns a copy of the env vars with their references resolved
+func resolveEnvVars(envVars map[string]string, outputs map[string]map[string]string) (map[string]string, error) {
+	if envVars == nil {
+		return nil, nil
+	}
+
+	resolved := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		var err error
+		if resolved[key], err = resolveReferences(value, outputs); err != nil {
+			return nil, errors.Wrapf(err, "invalid env var %s", key)
+		}
+	}
+	return resolved, nil
+}
+
+func resolveBackends(backends []string, outputs map[string]map[string]string) ([]zos.Backend, error) {
+	resolved := make([]zos.Backend, 0, len(backends))
+	for _, backend := range backends {
+		value, err := resolveReferences(backend, outputs)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid backend")
+		}
+		resolved = append(resolved, zos.Backend(value))
+	}
+	return resolved, nil
+}
+
+func envValues(envVars map[string]string) []string {
+	values := make([]string, 0, len(envVars))
+	for _, value := range envVars {
+		values = append(values, value)
+	}
+	return values
+}
+
+func vmOutputs(vm workloads.VM) map[string]string {
+	return map[string]string{
+		"ip":           vm.IP,
+		"computed_ip":  vm.ComputedIP,
+		"computed_ip6": vm.ComputedIP6,
+		"planetary_ip": vm.PlanetaryIP,
+		"mycelium_ip":  vm.MyceliumIP,
+	}
+}
@@ -0,0 +1,209 @@
+package deployer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+)
+
+const testManifestYAML = `
+project: demo
+networks:
+  - name: net
+    ip_range: 10.20.0.0/16
+    nodes: [11]
+    mycelium: true
+deployments:
+  - name: db
+    node_id: 12
+    network_name: net
+    vms:
+      - name: postgres
+        flist: https://hub.grid.tf/tf-official-apps/postgres.flist
+        cpu: 1
+        memory: 1024
+  - name: app
+    node_id: 13
+    network_name: net
+    vms:
+      - name: web
+        flist: https://hub.grid.tf/tf-official-apps/base:latest.flist
+        cpu: 1
+        memory: 1024
+        env_vars:
+          DB_HOST: ${postgres.ip}
+name_gateways:
+  - name: site
+    node_id: 14
+    backends:
+      - http://[${web.mycelium_ip}]:8080
+`
+
+func TestParseManifest(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		manifest, err := ParseManifest([]byte(testManifestYAML))
+		require.NoError(t, err)
+
+		assert.Equal(t, "demo", manifest.Project)
+		require.Len(t, manifest.Networks, 1)
+		assert.Equal(t, "10.20.0.0/16", manifest.Networks[0].IPRange)
+		require.Len(t, manifest.Deployments, 2)
+		assert.Equal(t, uint32(13), manifest.Deployments[1].NodeID)
+		require.Len(t, manifest.Deployments[1].VMs, 1)
+		assert.Equal(t, uint64(1024), manifest.Deployments[1].VMs[0].MemoryMB)
+		assert.Equal(t, "${postgres.ip}", manifest.Deployments[1].VMs[0].EnvVars["DB_HOST"])
+		require.Len(t, manifest.NameGateways, 1)
+		assert.Equal(t, []string{"http://[${web.mycelium_ip}]:8080"}, manifest.NameGateways[0].Backends)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		manifest, err := ParseManifest([]byte(`{
+			"kubernetes": [{
+				"network_name": "net",
+				"master": {"name": "master", "node": 11, "cpu": 2, "disk_size": 10},
+				"workers": [{"name": "worker", "node": 12, "cpu": 1}]
+			}]
+		}`))
+		require.NoError(t, err)
+
+		require.Len(t, manifest.Kubernetes, 1)
+		require.NotNil(t, manifest.Kubernetes[0].Master.VM)
+		assert.Equal(t, "master", manifest.Kubernetes[0].Master.Name)
+		assert.Equal(t, uint32(11), manifest.Kubernetes[0].Master.NodeID)
+		assert.Equal(t, uint64(10), manifest.Kubernetes[0].Master.DiskSizeGB)
+		assert.Equal(t, "worker", manifest.Kubernetes[0].Workers[0].Name)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseManifest([]byte("networks: {"))
+		assert.Error(t, err)
+	})
+}
+
+func TestManifestOrder(t *testing.T) {
+	t.Run("dependencies first", func(t *testing.T) {
+		manifest, err := ParseManifest([]byte(testManifestYAML))
+		require.NoError(t, err)
+
+		order, err := manifest.order()
+		require.NoError(t, err)
+
+		var keys []string
+		for _, resource := range order {
+			keys = append(keys, resource.key())
+		}
+		assert.Equal(t, []string{"network/net", "deployment/db", "deployment/app", "name_gateway/site"}, keys)
+
+		assert.Equal(t, []uint32{11, 12, 13}, manifest.networkNodes(manifest.Networks[0]))
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		manifest, err := ParseManifest([]byte(`
+deployments:
+  - name: a
+    node_id: 11
+    vms:
+      - name: vm_a
+        env_vars:
+          PEER: ${vm_b.ip}
+  - name: b
+    node_id: 12
+    vms:
+      - name: vm_b
+        env_vars:
+          PEER: ${vm_a.ip}
+`))
+		require.NoError(t, err)
+
+		_, err = manifest.order()
+		assert.ErrorContains(t, err, "dependency cycle")
+	})
+
+	t.Run("unknown reference", func(t *testing.T) {
+		manifest := Manifest{NameGateways: []ManifestNameGateway{{Name: "site", Backends: []string{"http://${web.ip}"}}}}
+
+		_, err := manifest.order()
+		assert.ErrorContains(t, err, "unknown workload web")
+	})
+
+	t.Run("unknown network", func(t *testing.T) {
+		manifest := Manifest{Deployments: []ManifestDeployment{{Name: "app", NetworkName: "net"}}}
+
+		_, err := manifest.order()
+		assert.ErrorContains(t, err, "depends on unknown network/net")
+	})
+
+	t.Run("duplicate workload", func(t *testing.T) {
+		manifest := Manifest{
+			Deployments:  []ManifestDeployment{{Name: "app"}},
+			NameGateways: []ManifestNameGateway{{Name: "app"}, {Name: "app"}},
+		}
+
+		_, err := manifest.order()
+		assert.Error(t, err)
+	})
+}
+
+func TestResolveReferences(t *testing.T) {
+	outputs := map[string]map[string]string{
+		"web": {"ip": "10.20.2.2", "mycelium_ip": "5ff:1::2"},
+	}
+
+	resolved, err := resolveReferences("http://[${web.mycelium_ip}]:8080", outputs)
+	require.NoError(t, err)
+	assert.Equal(t, "http://[5ff:1::2]:8080", resolved)
+
+	envVars := map[string]string{"HOST": "${web.ip}"}
+	resolvedEnv, err := resolveEnvVars(envVars, outputs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"HOST": "10.20.2.2"}, resolvedEnv)
+	assert.Equal(t, "${web.ip}", envVars["HOST"])
+
+	_, err = resolveReferences("${web.fqdn}", outputs)
+	assert.Error(t, err)
+
+	outputs["web"]["planetary_ip"] = ""
+	_, err = resolveReferences("${web.planetary_ip}", outputs)
+	assert.Error(t, err)
+}
+
+func TestManifestOutputs(t *testing.T) {
+	manifest, err := ParseManifest([]byte(testManifestYAML))
+	require.NoError(t, err)
+
+	outputs := map[string]map[string]string{}
+
+	_, err = manifest.deployment(manifest.Deployments[1], outputs)
+	assert.Error(t, err, "postgres is not deployed yet")
+
+	db, err := manifest.deployment(manifest.Deployments[0], outputs)
+	require.NoError(t, err)
+	require.Len(t, db.Vms, 1)
+	assert.Len(t, db.Vms[0].MyceliumIPSeed, zosTypes.MyceliumIPSeedLen)
+	assert.Empty(t, manifest.Deployments[0].VMs[0].MyceliumIPSeed)
+
+	db.Vms[0].IP = "10.20.2.2"
+	deploymentOutputs(db, outputs)
+
+	app, err := manifest.deployment(manifest.Deployments[1], outputs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"DB_HOST": "10.20.2.2"}, app.Vms[0].EnvVars)
+
+	deploymentOutputs(app, outputs)
+	_, err = resolveBackends(manifest.NameGateways[0].Backends, outputs)
+	assert.Error(t, err, "web mycelium ip is empty")
+
+	app.Vms[0].MyceliumIP = "5ff:1::2"
+	deploymentOutputs(app, outputs)
+	backends, err := resolveBackends(manifest.NameGateways[0].Backends, outputs)
+	require.NoError(t, err)
+	assert.Equal(t, []zos.Backend{"http://[5ff:1::2]:8080"}, backends)
+
+	manifest.Networks[0].Mycelium = false
+	db, err = manifest.deployment(manifest.Deployments[0], outputs)
+	require.NoError(t, err)
+	assert.Empty(t, db.Vms[0].MyceliumIPSeed)
+}
@@ -210,11 +210,11 @@ func (d *NetworkDeployer) Cancel(ctx context.Context, znet workloads.Network) er
 		znetDeploymentsIDs := znet.GetNodeDeploymentID()
 		delete(znetDeploymentsIDs, nodeID)
 		znet.SetNodeDeploymentID(znetDeploymentsIDs)
-		d.tfPluginClient.State.CurrentNodeDeployments[nodeID] = workloads.Delete(d.tfPluginClient.State.CurrentNodeDeployments[nodeID], contractID)
+		d.tfPluginClient.State.RemoveContractIDs(nodeID, contractID)
 	}
 
 	// delete network from state if all contracts was deleted
-	d.tfPluginClient.State.Networks.DeleteNetwork(znet.GetName())
+	d.tfPluginClient.State.DeleteNetwork(znet.GetName())
 
 	dls, err := d.deployer.GetDeployments(ctx, znet.GetNodeDeploymentID())
 	if err != nil {
@@ -245,10 +245,10 @@ func (d *NetworkDeployer) BatchCancel(ctx context.Context, znets []workloads.Net
 	}
 	for _, znet := range znets {
 		for nodeID, contractID := range znet.GetNodeDeploymentID() {
-			d.tfPluginClient.State.CurrentNodeDeployments[nodeID] = workloads.Delete(d.tfPluginClient.State.CurrentNodeDeployments[nodeID], contractID)
+			d.tfPluginClient.State.RemoveContractIDs(nodeID, contractID)
 		}
 
-		d.tfPluginClient.State.Networks.DeleteNetwork(znet.GetName())
+		d.tfPluginClient.State.DeleteNetwork(znet.GetName())
 		znet.SetNodeDeploymentID(make(map[uint32]uint64))
 		znet.SetKeys(make(map[uint32]wgtypes.Key))
 		znet.SetWGPort(make(map[uint32]int))
@@ -0,0 +1,306 @@
+package deployer
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/calculator"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+// ContractAction is the action the deployer takes on a node contract
+type ContractAction string
+
+const (
+	// ContractCreate a new contract is created on the node
+	ContractCreate ContractAction = "create"
+	// ContractUpdate the node contract is updated
+	ContractUpdate ContractAction = "update"
+	// ContractReplace a new contract replaces the node contract as its public ips count changed
+	ContractReplace ContractAction = "replace"
+	// ContractCancel the node contract is canceled
+	ContractCancel ContractAction = "cancel"
+	// ContractNoChange the node deployment is already up to date
+	ContractNoChange ContractAction = "none"
+)
+
+// CapacityDelta is the capacity difference between the old and the new deployment of a node
+type CapacityDelta struct {
+	CRU int64 `json:"cru"`
+	MRU int64 `json:"mru"`
+	SRU int64 `json:"sru"`
+	HRU int64 `json:"hru"`
+}
+
+// NodePlan describes what the deployer would do on a node
+type NodePlan struct {
+	NodeID uint32         `json:"node_id"`
+	Action ContractAction `json:"action"`
+	// ContractID is the current contract of the node deployment, zero for creations
+	ContractID uint64 `json:"contract_id"`
+
+	AddedWorkloads   []string `json:"added_workloads"`
+	RemovedWorkloads []string `json:"removed_workloads"`
+	// UpdatedWorkloads maps the changed workloads to their new versions
+	UpdatedWorkloads map[string]uint32 `json:"updated_workloads"`
+
+	// LostDataWorkloads are the disks, volumes and zdbs whose data is lost if the contract is replaced,
+	// deploying refuses such replacements unless the client allows the data loss
+	LostDataWorkloads []string `json:"lost_data_workloads,omitempty"`
+
+	CapacityDelta CapacityDelta `json:"capacity_delta"`
+	OldPublicIPs  uint32        `json:"old_public_ips"`
+	NewPublicIPs  uint32        `json:"new_public_ips"`
+
+	// EstimatedMonthlyCost is the monthly cost of the new node deployment
+	EstimatedMonthlyCost float64 `json:"estimated_monthly_cost"`
+}
+
+// ErrMaxMonthlyCostExceeded is returned if deploying would cost more than the client max monthly cost
+var ErrMaxMonthlyCostExceeded = errors.New("estimated monthly cost exceeds the max monthly cost")
+
+// Plan is the set of changes a deployment would apply without touching the chain
+type Plan struct {
+	Nodes                []NodePlan `json:"nodes"`
+	EstimatedMonthlyCost float64    `json:"estimated_monthly_cost"`
+}
+
+// HasChanges returns true if the plan changes any node deployment
+func (p *Plan) HasChanges() bool {
+	for _, node := range p.Nodes {
+		if node.Action != ContractNoChange {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan returns the changes Deploy would apply given the old deployments' IDs, nothing is signed or sent
+func (d *Deployer) Plan(ctx context.Context,
+	oldDeploymentIDs map[uint32]uint64,
+	newDeployments map[uint32]zos.Deployment,
+) (Plan, error) {
+	oldDeployments, err := d.GetDeployments(ctx, oldDeploymentIDs)
+	if err != nil {
+		return Plan{}, errors.Wrap(err, "failed to get old deployments")
+	}
+
+	var plan Plan
+	for node, oldDl := range oldDeployments {
+		if _, ok := newDeployments[node]; ok {
+			continue
+		}
+
+		nodePlan, err := d.planNode(node, &oldDl, nil)
+		if err != nil {
+			return Plan{}, err
+		}
+		plan.Nodes = append(plan.Nodes, nodePlan)
+	}
+
+	calc := calculator.NewCalculator(d.substrateConn, d.identity, d.gridProxyClient)
+	var nodes []calculator.NodeWorkloads
+	for node, dl := range newDeployments {
+		var oldDl *zos.Deployment
+		if old, ok := oldDeployments[node]; ok {
+			oldDl = &old
+		}
+
+		nodePlan, err := d.planNode(node, oldDl, &dl)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		workloads := calculator.NodeWorkloads{NodeID: node, Workloads: dl.Workloads}
+		if nodePlan.EstimatedMonthlyCost, err = monthlyCost(ctx, &calc, workloads); err != nil {
+			return Plan{}, err
+		}
+		plan.Nodes = append(plan.Nodes, nodePlan)
+		nodes = append(nodes, workloads)
+	}
+
+	// the plan cost is estimated the same way the max monthly cost is checked when deploying
+	if plan.EstimatedMonthlyCost, err = monthlyCost(ctx, &calc, nodes...); err != nil {
+		return Plan{}, err
+	}
+
+	plan.sort()
+	return plan, nil
+}
+
+// BatchPlan returns the changes BatchDeploy would apply, nothing is signed or sent
+func (d *Deployer) BatchPlan(ctx context.Context, deployments map[uint32][]zos.Deployment) (Plan, error) {
+	var plan Plan
+	var err error
+	calc := calculator.NewCalculator(d.substrateConn, d.identity, d.gridProxyClient)
+	var nodes []calculator.NodeWorkloads
+	for node, dls := range deployments {
+		for _, dl := range dls {
+			nodePlan, err := d.planNode(node, nil, &dl)
+			if err != nil {
+				return Plan{}, err
+			}
+
+			workloads := calculator.NodeWorkloads{NodeID: node, Workloads: dl.Workloads}
+			if nodePlan.EstimatedMonthlyCost, err = monthlyCost(ctx, &calc, workloads); err != nil {
+				return Plan{}, err
+			}
+			plan.Nodes = append(plan.Nodes, nodePlan)
+			nodes = append(nodes, workloads)
+		}
+	}
+
+	if plan.EstimatedMonthlyCost, err = monthlyCost(ctx, &calc, nodes...); err != nil {
+		return Plan{}, err
+	}
+
+	plan.sort()
+	return plan, nil
+}
+
+// planNode compares the old and the new deployment of a node, a nil old deployment means a creation
+// and a nil new deployment means a cancellation
+func (d *Deployer) planNode(node uint32, oldDl, newDl *zos.Deployment) (NodePlan, error) {
+	nodePlan := NodePlan{
+		NodeID:           node,
+		UpdatedWorkloads: map[string]uint32{},
+	}
+
+	var oldCap, newCap zos.Capacity
+	var err error
+
+	if oldDl != nil {
+		nodePlan.ContractID = oldDl.ContractID
+		if oldCap, err = Capacity(*oldDl); err != nil {
+			return NodePlan{}, errors.Wrapf(err, "could not read old deployment %d capacity", oldDl.ContractID)
+		}
+		if nodePlan.OldPublicIPs, err = CountDeploymentPublicIPs(*oldDl); err != nil {
+			return NodePlan{}, errors.Wrap(err, "failed to count old deployment public IPs")
+		}
+	}
+
+	if newDl != nil {
+		if newCap, err = Capacity(*newDl); err != nil {
+			return NodePlan{}, errors.Wrapf(err, "could not read node %d deployment capacity", node)
+		}
+		if nodePlan.NewPublicIPs, err = CountDeploymentPublicIPs(*newDl); err != nil {
+			return NodePlan{}, errors.Wrap(err, "failed to count deployment public IPs")
+		}
+	}
+
+	nodePlan.CapacityDelta = CapacityDelta{
+		CRU: int64(newCap.CRU) - int64(oldCap.CRU),
+		MRU: int64(newCap.MRU) - int64(oldCap.MRU),
+		SRU: int64(newCap.SRU) - int64(oldCap.SRU),
+		HRU: int64(newCap.HRU) - int64(oldCap.HRU),
+	}
+
+	switch {
+	case oldDl == nil:
+		nodePlan.Action = ContractCreate
+		for _, wl := range newDl.Workloads {
+			nodePlan.AddedWorkloads = append(nodePlan.AddedWorkloads, wl.Name)
+		}
+
+	case newDl == nil:
+		nodePlan.Action = ContractCancel
+		for _, wl := range oldDl.Workloads {
+			nodePlan.RemovedWorkloads = append(nodePlan.RemovedWorkloads, wl.Name)
+		}
+
+	default:
+		// work on a copy of the workloads so the caller's deployment versions stay untouched
+		dl := *newDl
+		dl.Workloads = slices.Clone(newDl.Workloads)
+
+		matchOldVersions(oldDl, &dl)
+
+		oldHash, err := HashDeployment(*oldDl)
+		if err != nil {
+			return NodePlan{}, errors.Wrap(err, "could not get deployment hash")
+		}
+
+		newHash, err := HashDeployment(dl)
+		if err != nil {
+			return NodePlan{}, errors.Wrap(err, "could not get deployment hash")
+		}
+
+		if oldHash == newHash && SameWorkloadsNames(dl, *oldDl) {
+			nodePlan.Action = ContractNoChange
+			return nodePlan, nil
+		}
+
+		nodePlan.Action = ContractUpdate
+		if nodePlan.OldPublicIPs != nodePlan.NewPublicIPs {
+			nodePlan.Action = ContractReplace
+			nodePlan.LostDataWorkloads = lostDataWorkloads(*oldDl, dl)
+		}
+
+		versions, err := assignVersions(oldDl, &dl)
+		if err != nil {
+			return NodePlan{}, errors.Wrapf(err, "failed to assign new versions to deployment with contract %d", oldDl.ContractID)
+		}
+
+		oldVersions := ConstructWorkloadVersions(*oldDl)
+		for name, version := range versions {
+			if _, ok := oldVersions[name]; !ok {
+				nodePlan.AddedWorkloads = append(nodePlan.AddedWorkloads, name)
+				continue
+			}
+			if version == dl.Version {
+				nodePlan.UpdatedWorkloads[name] = version
+			}
+		}
+
+		for name := range oldVersions {
+			if _, ok := versions[name]; !ok {
+				nodePlan.RemovedWorkloads = append(nodePlan.RemovedWorkloads, name)
+			}
+		}
+	}
+
+	sort.Strings(nodePlan.AddedWorkloads)
+	sort.Strings(nodePlan.RemovedWorkloads)
+	return nodePlan, nil
+}
+
+// monthlyCost estimates the monthly cost of the given workloads with the farms pricing policies
+func monthlyCost(ctx context.Context, calc *calculator.Calculator, nodes ...calculator.NodeWorkloads) (float64, error) {
+	if len(nodes) == 0 {
+		return 0, nil
+	}
+
+	estimate, err := calc.EstimateNodeWorkloads(ctx, nodes...)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to estimate deployments monthly cost")
+	}
+
+	return estimate.MonthlyCost, nil
+}
+
+// checkMaxMonthlyCost refuses deploying the given workloads if their estimated monthly cost exceeds the max monthly cost
+func (d *Deployer) checkMaxMonthlyCost(ctx context.Context, nodes ...calculator.NodeWorkloads) error {
+	if d.maxMonthlyCost == 0 || len(nodes) == 0 {
+		return nil
+	}
+
+	calc := calculator.NewCalculator(d.substrateConn, d.identity, d.gridProxyClient)
+	cost, err := monthlyCost(ctx, &calc, nodes...)
+	if err != nil {
+		return err
+	}
+
+	if cost > d.maxMonthlyCost {
+		return errors.Wrapf(ErrMaxMonthlyCostExceeded, "%.2f USD > %.2f USD", cost, d.maxMonthlyCost)
+	}
+
+	return nil
+}
+
+func (p *Plan) sort() {
+	sort.SliceStable(p.Nodes, func(i, j int) bool {
+		return p.Nodes[i].NodeID < p.Nodes[j].NodeID
+	})
+}
@@ -0,0 +1,199 @@
+package deployer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/calculator"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	proxyTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+)
+
+func TestDeployerPlan(t *testing.T) {
+	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
+	require.NoError(t, err)
+	twinID := uint32(1)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cl := mocks.NewRMBMockClient(ctrl)
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	ncPool := mocks.NewMockNodeClientGetter(ctrl)
+	gridProxy := mocks.NewMockClient(ctrl)
+
+	d := Deployer{
+		identity:        identity,
+		twinID:          twinID,
+		ncPool:          ncPool,
+		substrateConn:   sub,
+		gridProxyClient: gridProxy,
+	}
+
+	sub.EXPECT().GetTFTPrice().Return(types.U32(1), nil).AnyTimes()
+	sub.EXPECT().GetPricingPolicy(uint32(1)).Return(substrate.PricingPolicy{}, nil).AnyTimes()
+	gridProxy.EXPECT().Node(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, nodeID uint32) (proxyTypes.NodeWithNestedCapacity, error) {
+		return proxyTypes.NodeWithNestedCapacity{NodeID: int(nodeID), FarmID: 1}, nil
+	}).AnyTimes()
+	gridProxy.EXPECT().Farms(gomock.Any(), gomock.Any(), gomock.Any()).Return([]proxyTypes.Farm{{FarmID: 1}}, 1, nil).AnyTimes()
+
+	oldGateway, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+	require.NoError(t, err)
+	oldGateway.ContractID = 100
+
+	oldFQDN, err := deploymentWithFQDN(identity, twinID, 0)
+	require.NoError(t, err)
+	oldFQDN.ContractID = 200
+
+	disk := workloads.Disk{Name: "disk", SizeGB: 1}
+	oldDisk := workloads.NewGridDeployment(twinID, 0, []zosTypes.Workload{disk.ZosWorkload()})
+	oldDisk.ContractID = 500
+
+	oldDls := map[uint32]zosTypes.Deployment{10: oldGateway, 20: oldFQDN, 50: oldDisk}
+	for node, twin := range map[uint32]uint32{10: 13, 20: 23, 50: 53} {
+		dl := oldDls[node]
+		ncPool.EXPECT().
+			GetNodeClient(sub, node).
+			Return(client.NewNodeClient(twin, cl, 10), nil).AnyTimes()
+
+		cl.EXPECT().
+			Call(gomock.Any(), twin, "zos.deployment.get", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+				*result.(*zosTypes.Deployment) = dl
+				return nil
+			}).AnyTimes()
+	}
+
+	t.Run("update, create and cancel", func(t *testing.T) {
+		updatedGateway, err := deploymentWithNameGateway(identity, twinID, true, 0, "2.2.2.2:10")
+		require.NoError(t, err)
+		newFQDN, err := deploymentWithFQDN(identity, twinID, 0)
+		require.NoError(t, err)
+
+		plan, err := d.Plan(
+			context.Background(),
+			map[uint32]uint64{10: 100, 20: 200},
+			map[uint32]zosTypes.Deployment{10: updatedGateway, 30: newFQDN},
+		)
+		require.NoError(t, err)
+		require.Len(t, plan.Nodes, 3)
+		assert.True(t, plan.HasChanges())
+
+		assert.Equal(t, uint32(10), plan.Nodes[0].NodeID)
+		assert.Equal(t, ContractUpdate, plan.Nodes[0].Action)
+		assert.Equal(t, uint64(100), plan.Nodes[0].ContractID)
+		assert.Equal(t, map[string]uint32{"name": 1}, plan.Nodes[0].UpdatedWorkloads)
+		assert.Empty(t, plan.Nodes[0].AddedWorkloads)
+		assert.Empty(t, plan.Nodes[0].RemovedWorkloads)
+
+		assert.Equal(t, uint32(20), plan.Nodes[1].NodeID)
+		assert.Equal(t, ContractCancel, plan.Nodes[1].Action)
+		assert.Equal(t, []string{"fqdn"}, plan.Nodes[1].RemovedWorkloads)
+
+		assert.Equal(t, uint32(30), plan.Nodes[2].NodeID)
+		assert.Equal(t, ContractCreate, plan.Nodes[2].Action)
+		assert.Equal(t, []string{"fqdn"}, plan.Nodes[2].AddedWorkloads)
+
+		// the caller's deployment is not versioned by planning
+		assert.Equal(t, uint32(0), updatedGateway.Workloads[0].Version)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		sameGateway, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+		require.NoError(t, err)
+
+		plan, err := d.Plan(
+			context.Background(),
+			map[uint32]uint64{10: 100},
+			map[uint32]zosTypes.Deployment{10: sameGateway},
+		)
+		require.NoError(t, err)
+		require.Len(t, plan.Nodes, 1)
+		assert.Equal(t, ContractNoChange, plan.Nodes[0].Action)
+		assert.False(t, plan.HasChanges())
+	})
+
+	t.Run("replace loses data", func(t *testing.T) {
+		dl := workloads.NewGridDeployment(twinID, 0, []zosTypes.Workload{
+			disk.ZosWorkload(),
+			workloads.ConstructPublicIPWorkload("ip", true, false),
+		})
+
+		plan, err := d.Plan(context.Background(), map[uint32]uint64{50: 500}, map[uint32]zosTypes.Deployment{50: dl})
+		require.NoError(t, err)
+		require.Len(t, plan.Nodes, 1)
+		assert.Equal(t, ContractReplace, plan.Nodes[0].Action)
+		assert.Equal(t, []string{"disk"}, plan.Nodes[0].LostDataWorkloads)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		gateway, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+		require.NoError(t, err)
+		fqdn, err := deploymentWithFQDN(identity, twinID, 0)
+		require.NoError(t, err)
+
+		plan, err := d.BatchPlan(context.Background(), map[uint32][]zosTypes.Deployment{40: {gateway, fqdn}})
+		require.NoError(t, err)
+		require.Len(t, plan.Nodes, 2)
+		for _, node := range plan.Nodes {
+			assert.Equal(t, ContractCreate, node.Action)
+			assert.Equal(t, uint32(40), node.NodeID)
+		}
+	})
+}
+
+func TestDeployerMaxMonthlyCost(t *testing.T) {
+	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	gridProxy := mocks.NewMockClient(ctrl)
+
+	d := Deployer{
+		identity:        identity,
+		twinID:          1,
+		gridProxyClient: gridProxy,
+		substrateConn:   sub,
+		maxMonthlyCost:  1,
+	}
+
+	sub.EXPECT().GetTFTPrice().Return(types.U32(20), nil).AnyTimes()
+	sub.EXPECT().GetPricingPolicy(uint32(1)).Return(substrate.PricingPolicy{
+		IPU: substrate.Policy{Value: 10000},
+	}, nil).AnyTimes()
+	gridProxy.EXPECT().Node(gomock.Any(), uint32(10)).Return(proxyTypes.NodeWithNestedCapacity{NodeID: 10, FarmID: 1}, nil).AnyTimes()
+	gridProxy.EXPECT().Farms(gomock.Any(), gomock.Any(), gomock.Any()).Return([]proxyTypes.Farm{{FarmID: 1, PublicIps: []proxyTypes.PublicIP{{}, {}}}}, 1, nil).AnyTimes()
+
+	ip := func(name string) zosTypes.Workload {
+		return zosTypes.Workload{Name: name, Type: zosTypes.PublicIPType, Data: zosTypes.MustMarshal(zosTypes.PublicIP{V4: true})}
+	}
+
+	// a public ip costs 0.72 USD per month
+	err = d.checkMaxMonthlyCost(context.Background(), calculator.NodeWorkloads{NodeID: 10, Workloads: []zosTypes.Workload{ip("ip1")}})
+	assert.NoError(t, err)
+
+	dl := workloads.NewGridDeployment(1, 0, []zosTypes.Workload{ip("ip1"), ip("ip2")})
+
+	// the plan shows the cost the deployment is refused for
+	plan, err := d.Plan(context.Background(), nil, map[uint32]zosTypes.Deployment{10: dl})
+	require.NoError(t, err)
+	assert.InDelta(t, 1.44, plan.EstimatedMonthlyCost, 1e-9)
+	assert.InDelta(t, 1.44, plan.Nodes[0].EstimatedMonthlyCost, 1e-9)
+
+	_, err = d.Deploy(context.Background(), nil, map[uint32]zosTypes.Deployment{10: dl}, nil)
+	assert.ErrorIs(t, err, ErrMaxMonthlyCostExceeded)
+
+	_, err = d.BatchDeploy(context.Background(), map[uint32][]zosTypes.Deployment{10: {dl}}, nil)
+	assert.ErrorIs(t, err, ErrMaxMonthlyCostExceeded)
+}
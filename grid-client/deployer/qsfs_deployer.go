@@ -0,0 +1,361 @@
+package deployer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+)
+
+const (
+	// DefaultQSFSCache is the default qsfs cache size in MB
+	DefaultQSFSCache = 1024
+	// DefaultQSFSMetadataSizeGB is the default size of each qsfs metadata zdb
+	DefaultQSFSMetadataSizeGB = 1
+
+	// qsfsMetadataBackends is the number of metadata backends zstor requires
+	qsfsMetadataBackends = 4
+	// qsfsMaxZDBDataDirSize is the size in MB of the zdbs data on the qsfs local disk
+	qsfsMaxZDBDataDirSize    = 512
+	qsfsEncryptionAlgorithm  = "AES"
+	qsfsCompressionAlgorithm = "snappy"
+	qsfsMetadataZDB          = "meta"
+)
+
+// QSFSOptions configures a qsfs and its zdb backends
+type QSFSOptions struct {
+	// Name of the qsfs, its zdbs are deployed in deployments with the same name followed by "zdbs"
+	Name string
+	// CapacityGB is the data capacity of the qsfs
+	CapacityGB uint64
+	// MinimalShards is the number of shards needed to recover the data
+	MinimalShards uint32
+	// ExpectedShards is the number of shards the data is split into, each on a different node
+	ExpectedShards uint32
+	// RedundantGroups is the number of backend groups that can be lost without losing the data
+	RedundantGroups uint32
+	// RedundantNodes is the number of nodes of each group that can be lost without losing the data
+	RedundantNodes uint32
+	// Cache is the qsfs cache in MB, defaults to DefaultQSFSCache
+	Cache int
+	// MetadataSizeGB is the size of each metadata zdb, defaults to DefaultQSFSMetadataSizeGB
+	MetadataSizeGB uint64
+	// NodeFilter filters the nodes of the zdbs, only nodes with enough free hdd are used
+	NodeFilter types.NodeFilter
+	// SolutionType defaults to qsfs/<name>
+	SolutionType string
+}
+
+// QSFSCluster is a qsfs with the deployments of its zdb backends
+type QSFSCluster struct {
+	// QSFS is ready to be deployed on the node of the vms mounting it
+	QSFS workloads.QSFS
+	// Deployments are the zdbs deployments of the qsfs backends, one on each node
+	Deployments []*workloads.Deployment
+}
+
+// QSFSDeployer deploys the zdb backends of qsfs
+type QSFSDeployer struct {
+	tfPluginClient *TFPluginClient
+}
+
+// NewQSFSDeployer generates a new qsfs deployer
+func NewQSFSDeployer(tfPluginClient *TFPluginClient) QSFSDeployer {
+	return QSFSDeployer{tfPluginClient: tfPluginClient}
+}
+
+// Deploy picks the nodes of the qsfs backends, deploys its metadata and data zdbs and returns the qsfs
+// configured with the backends and a newly generated password and encryption key
+func (d *QSFSDeployer) Deploy(ctx context.Context, opts QSFSOptions) (QSFSCluster, error) {
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		return QSFSCluster{}, errors.Wrap(err, "invalid qsfs options")
+	}
+
+	nodesCount := opts.nodesCount()
+	filter := opts.NodeFilter
+	if filter.Status == nil {
+		filter.Status = []string{"up"}
+	}
+
+	// the first node has the most zdbs
+	zdbsSizes := opts.nodeZDBsSizes(0)
+	if filter.FreeHRU == nil {
+		var freeHRU uint64
+		for _, size := range zdbsSizes {
+			freeHRU += size
+		}
+		filter.FreeHRU = &freeHRU
+	}
+
+	nodes, err := FilterNodes(ctx, *d.tfPluginClient, filter, nil, zdbsSizes, nil, uint64(nodesCount))
+	if err != nil {
+		return QSFSCluster{}, errors.Wrap(err, "failed to find nodes for the qsfs zdbs")
+	}
+
+	if len(nodes) < nodesCount {
+		return QSFSCluster{}, errors.Errorf("found %d nodes for the qsfs zdbs, %d nodes are needed", len(nodes), nodesCount)
+	}
+
+	var nodeIDs []uint32
+	for _, node := range nodes[:nodesCount] {
+		nodeIDs = append(nodeIDs, uint32(node.NodeID))
+	}
+
+	password, err := randomHex(16)
+	if err != nil {
+		return QSFSCluster{}, errors.Wrap(err, "failed to generate zdbs password")
+	}
+
+	cluster := QSFSCluster{Deployments: qsfsDeployments(opts, nodeIDs, password)}
+	if err := d.tfPluginClient.DeploymentDeployer.BatchDeploy(ctx, cluster.Deployments); err != nil {
+		return QSFSCluster{}, multierror.Append(errors.Wrap(err, "failed to deploy qsfs zdbs"), d.Cancel(ctx, &cluster))
+	}
+
+	// zdbs outputs are only known after they are deployed
+	for _, dl := range cluster.Deployments {
+		for i, zdb := range dl.Zdbs {
+			loaded, err := d.tfPluginClient.State.LoadZdbFromGrid(ctx, dl.NodeID, zdb.Name, dl.Name)
+			if err != nil {
+				return QSFSCluster{}, multierror.Append(errors.Wrapf(err, "failed to load zdb %s on node %d", zdb.Name, dl.NodeID), d.Cancel(ctx, &cluster))
+			}
+			dl.Zdbs[i] = loaded
+		}
+	}
+
+	cluster.QSFS, err = buildQSFS(opts, cluster.Deployments)
+	if err != nil {
+		return QSFSCluster{}, multierror.Append(err, d.Cancel(ctx, &cluster))
+	}
+
+	return cluster, nil
+}
+
+// Cancel cancels the zdbs deployments of the qsfs
+func (d *QSFSDeployer) Cancel(ctx context.Context, cluster *QSFSCluster) error {
+	var errs error
+	for _, dl := range cluster.Deployments {
+		if dl.ContractID == 0 {
+			continue
+		}
+
+		if err := d.tfPluginClient.DeploymentDeployer.Cancel(ctx, dl); err != nil {
+			errs = multierror.Append(errs, errors.Wrapf(err, "failed to cancel qsfs zdbs on node %d", dl.NodeID))
+		}
+	}
+
+	return errs
+}
+
+// Validate validates the qsfs options
+func (opts QSFSOptions) Validate() error {
+	if opts.CapacityGB == 0 {
+		return errors.New("capacity should be a positive integer not zero")
+	}
+
+	if opts.MinimalShards == 0 {
+		return errors.New("minimal shards should be a positive integer not zero")
+	}
+
+	if opts.MinimalShards > opts.ExpectedShards {
+		return errors.New("minimal shards can't be greater than expected shards")
+	}
+
+	// each node has one shard of each group
+	if opts.RedundantNodes > opts.ExpectedShards-opts.MinimalShards {
+		return errors.Errorf("redundant nodes can't be greater than %d, the expected shards that are not needed to recover the data", opts.ExpectedShards-opts.MinimalShards)
+	}
+
+	qsfs := workloads.QSFS{Name: opts.Name, MinimalShards: opts.MinimalShards, ExpectedShards: opts.ExpectedShards}
+	return qsfs.Validate()
+}
+
+func (opts QSFSOptions) withDefaults() QSFSOptions {
+	if opts.Cache == 0 {
+		opts.Cache = DefaultQSFSCache
+	}
+
+	if opts.MetadataSizeGB == 0 {
+		opts.MetadataSizeGB = DefaultQSFSMetadataSizeGB
+	}
+
+	if opts.SolutionType == "" {
+		opts.SolutionType = fmt.Sprintf("qsfs/%s", opts.Name)
+	}
+
+	return opts
+}
+
+// nodesCount is the number of nodes of the zdbs, each group has a data backend on each of the
+// first expected shards nodes and the metadata backends are on the first nodes
+func (opts QSFSOptions) nodesCount() int {
+	return int(max(opts.ExpectedShards, qsfsMetadataBackends))
+}
+
+// dataZDBSizeGB is the size of each data zdb, each shard is a part of the data needed to recover it
+func (opts QSFSOptions) dataZDBSizeGB() uint64 {
+	shards := uint64(opts.MinimalShards)
+	return (opts.CapacityGB + shards - 1) / shards
+}
+
+// nodeZDBsSizes returns the sizes in bytes of the zdbs on the node with the given index
+func (opts QSFSOptions) nodeZDBsSizes(idx int) []uint64 {
+	var sizes []uint64
+	if idx < int(opts.ExpectedShards) {
+		for group := uint32(0); group <= opts.RedundantGroups; group++ {
+			sizes = append(sizes, gbToBytes(opts.dataZDBSizeGB()))
+		}
+	}
+
+	if idx < qsfsMetadataBackends {
+		sizes = append(sizes, gbToBytes(opts.MetadataSizeGB))
+	}
+
+	return sizes
+}
+
+// qsfsDeployments returns the zdbs deployments of the qsfs on the given nodes
+func qsfsDeployments(opts QSFSOptions, nodes []uint32, password string) []*workloads.Deployment {
+	var dls []*workloads.Deployment
+	for idx, nodeID := range nodes {
+		var zdbs []workloads.ZDB
+		if idx < int(opts.ExpectedShards) {
+			for group := uint32(0); group <= opts.RedundantGroups; group++ {
+				zdbs = append(zdbs, workloads.ZDB{
+					Name:        qsfsDataZDB(group),
+					Password:    password,
+					Public:      true,
+					SizeGB:      opts.dataZDBSizeGB(),
+					Description: fmt.Sprintf("qsfs %s data backend", opts.Name),
+					Mode:        workloads.ZDBModeSeq,
+				})
+			}
+		}
+
+		if idx < qsfsMetadataBackends {
+			zdbs = append(zdbs, workloads.ZDB{
+				Name:        qsfsMetadataZDB,
+				Password:    password,
+				Public:      true,
+				SizeGB:      opts.MetadataSizeGB,
+				Description: fmt.Sprintf("qsfs %s metadata backend", opts.Name),
+				Mode:        workloads.ZDBModeUser,
+			})
+		}
+
+		dl := workloads.NewDeployment(fmt.Sprintf("%szdbs", opts.Name), nodeID, opts.SolutionType, nil, "", nil, zdbs, nil, nil, nil, nil)
+		dls = append(dls, &dl)
+	}
+
+	return dls
+}
+
+// buildQSFS returns the qsfs using the deployed zdbs as backends with a new encryption key
+func buildQSFS(opts QSFSOptions, dls []*workloads.Deployment) (workloads.QSFS, error) {
+	groups := make(workloads.Groups, opts.RedundantGroups+1)
+	var metadata workloads.Backends
+
+	for _, dl := range dls {
+		for _, zdb := range dl.Zdbs {
+			address, err := zdbBackendAddress(zdb)
+			if err != nil {
+				return workloads.QSFS{}, errors.Wrapf(err, "invalid zdb %s on node %d", zdb.Name, dl.NodeID)
+			}
+
+			backend := workloads.Backend{Address: address, Namespace: zdb.Namespace, Password: zdb.Password}
+			if zdb.Name == qsfsMetadataZDB {
+				metadata = append(metadata, backend)
+				continue
+			}
+
+			var group uint32
+			if _, err := fmt.Sscanf(zdb.Name, "data%d", &group); err != nil || group > opts.RedundantGroups {
+				return workloads.QSFS{}, errors.Errorf("invalid data zdb name %s on node %d", zdb.Name, dl.NodeID)
+			}
+			groups[group].Backends = append(groups[group].Backends, backend)
+		}
+	}
+
+	encryptionKey, err := randomHex(32)
+	if err != nil {
+		return workloads.QSFS{}, errors.Wrap(err, "failed to generate encryption key")
+	}
+
+	return workloads.QSFS{
+		Name:                 opts.Name,
+		Description:          fmt.Sprintf("qsfs with %d GB capacity", opts.CapacityGB),
+		Cache:                opts.Cache,
+		MinimalShards:        opts.MinimalShards,
+		ExpectedShards:       opts.ExpectedShards,
+		RedundantGroups:      opts.RedundantGroups,
+		RedundantNodes:       opts.RedundantNodes,
+		MaxZDBDataDirSize:    qsfsMaxZDBDataDirSize,
+		EncryptionAlgorithm:  qsfsEncryptionAlgorithm,
+		EncryptionKey:        encryptionKey,
+		CompressionAlgorithm: qsfsCompressionAlgorithm,
+		Metadata: workloads.Metadata{
+			Type:                "zdb",
+			Prefix:              opts.Name,
+			EncryptionAlgorithm: qsfsEncryptionAlgorithm,
+			// zos encrypts the metadata with the data encryption key
+			EncryptionKey: encryptionKey,
+			Backends:      metadata,
+		},
+		Groups: groups,
+	}, nil
+}
+
+func qsfsDataZDB(group uint32) string {
+	return fmt.Sprintf("data%d", group)
+}
+
+// zdbBackendAddress returns the address of a zdb preferring its yggdrasil ip, then its public ipv6 and then its mycelium ip
+func zdbBackendAddress(zdb workloads.ZDB) (string, error) {
+	yggdrasil := net.IPNet{IP: net.ParseIP("200::"), Mask: net.CIDRMask(7, 128)}
+	mycelium := net.IPNet{IP: net.ParseIP("400::"), Mask: net.CIDRMask(7, 128)}
+
+	var public, overlay net.IP
+	for _, ip := range zdb.IPs {
+		parsed := net.ParseIP(ip)
+		switch {
+		case parsed == nil || parsed.To4() != nil:
+			continue
+		case yggdrasil.Contains(parsed):
+			return fmt.Sprintf("[%s]:%d", parsed, zdb.Port), nil
+		case mycelium.Contains(parsed):
+			overlay = parsed
+		case parsed.IsGlobalUnicast() && !parsed.IsPrivate() && public == nil:
+			public = parsed
+		}
+	}
+
+	if public != nil {
+		return fmt.Sprintf("[%s]:%d", public, zdb.Port), nil
+	}
+
+	if overlay != nil {
+		return fmt.Sprintf("[%s]:%d", overlay, zdb.Port), nil
+	}
+
+	return "", errors.Errorf("zdb has no reachable ipv6 in %v", zdb.IPs)
+}
+
+func randomHex(size int) (string, error) {
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(key), nil
+}
+
+func gbToBytes(gb uint64) uint64 {
+	return uint64(gridtypes.Unit(gb) * gridtypes.Gigabyte)
+}
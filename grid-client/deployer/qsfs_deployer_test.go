@@ -0,0 +1,124 @@
+package deployer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+)
+
+func TestQSFSDeployer(t *testing.T) {
+	opts := QSFSOptions{
+		Name:            "qsfs",
+		CapacityGB:      10,
+		MinimalShards:   3,
+		ExpectedShards:  5,
+		RedundantGroups: 1,
+		RedundantNodes:  2,
+	}.withDefaults()
+
+	t.Run("validate", func(t *testing.T) {
+		require.NoError(t, opts.Validate())
+
+		invalid := opts
+		invalid.RedundantNodes = 3
+		assert.Error(t, invalid.Validate())
+
+		invalid = opts
+		invalid.MinimalShards = 6
+		assert.Error(t, invalid.Validate())
+
+		invalid = opts
+		invalid.CapacityGB = 0
+		assert.Error(t, invalid.Validate())
+
+		invalid = opts
+		invalid.Name = "qsfs-1"
+		assert.Error(t, invalid.Validate())
+	})
+
+	t.Run("deployments", func(t *testing.T) {
+		require.Equal(t, 5, opts.nodesCount())
+		assert.Equal(t, []uint64{gbToBytes(4), gbToBytes(4), gbToBytes(1)}, opts.nodeZDBsSizes(0))
+
+		dls := qsfsDeployments(opts, []uint32{1, 2, 3, 4, 5}, "password")
+		require.Len(t, dls, 5)
+
+		for i, dl := range dls {
+			assert.Equal(t, "qsfszdbs", dl.Name)
+			assert.Equal(t, "qsfs/qsfs", dl.SolutionType)
+			assert.Equal(t, uint32(i+1), dl.NodeID)
+
+			var names []string
+			for _, zdb := range dl.Zdbs {
+				require.NoError(t, zdb.Validate())
+				assert.Equal(t, "password", zdb.Password)
+				names = append(names, zdb.Name)
+			}
+
+			// only the first 4 nodes have metadata zdbs
+			if i < qsfsMetadataBackends {
+				assert.Equal(t, []string{"data0", "data1", "meta"}, names)
+			} else {
+				assert.Equal(t, []string{"data0", "data1"}, names)
+			}
+		}
+
+		// metadata zdbs are on the first nodes even with less expected shards
+		small := opts
+		small.ExpectedShards = 3
+		small.RedundantNodes = 0
+		dls = qsfsDeployments(small, []uint32{1, 2, 3, 4}, "password")
+		require.Len(t, dls, 4)
+		assert.Len(t, dls[3].Zdbs, 1)
+		assert.Equal(t, workloads.ZDBModeUser, dls[3].Zdbs[0].Mode)
+	})
+
+	t.Run("qsfs", func(t *testing.T) {
+		dls := qsfsDeployments(opts, []uint32{1, 2, 3, 4, 5}, "password")
+		for _, dl := range dls {
+			for i := range dl.Zdbs {
+				dl.Zdbs[i].IPs = []string{"10.20.1.2", fmt.Sprintf("2a02:1802:5e::%d", dl.NodeID), fmt.Sprintf("302:9e63:7d43:b742::%d", dl.NodeID)}
+				dl.Zdbs[i].Port = 9900
+				dl.Zdbs[i].Namespace = fmt.Sprintf("%d-%s", dl.NodeID, dl.Zdbs[i].Name)
+			}
+		}
+
+		qsfs, err := buildQSFS(opts, dls)
+		require.NoError(t, err)
+		require.NoError(t, qsfs.Validate())
+
+		require.Len(t, qsfs.Groups, 2)
+		for group, zdbGroup := range qsfs.Groups {
+			require.Len(t, zdbGroup.Backends, 5)
+			assert.Equal(t, workloads.Backend{
+				Address:   "[302:9e63:7d43:b742::1]:9900",
+				Namespace: fmt.Sprintf("1-data%d", group),
+				Password:  "password",
+			}, zdbGroup.Backends[0])
+		}
+
+		require.Len(t, qsfs.Metadata.Backends, qsfsMetadataBackends)
+		assert.Equal(t, "4-meta", qsfs.Metadata.Backends[3].Namespace)
+
+		assert.Len(t, qsfs.EncryptionKey, 64)
+		assert.Equal(t, qsfs.EncryptionKey, qsfs.Metadata.EncryptionKey)
+		assert.Equal(t, uint32(3), qsfs.MinimalShards)
+		assert.Equal(t, DefaultQSFSCache, qsfs.Cache)
+	})
+
+	t.Run("backend address", func(t *testing.T) {
+		address, err := zdbBackendAddress(workloads.ZDB{IPs: []string{"10.20.1.2", "2a02:1802:5e::1", "4a1:d2f4:e5d6::1"}, Port: 9900})
+		require.NoError(t, err)
+		assert.Equal(t, "[2a02:1802:5e::1]:9900", address)
+
+		address, err = zdbBackendAddress(workloads.ZDB{IPs: []string{"fd00::1", "4a1:d2f4:e5d6::1"}, Port: 9900})
+		require.NoError(t, err)
+		assert.Equal(t, "[4a1:d2f4:e5d6::1]:9900", address)
+
+		_, err = zdbBackendAddress(workloads.ZDB{IPs: []string{"10.20.1.2"}, Port: 9900})
+		assert.Error(t, err)
+	})
+}
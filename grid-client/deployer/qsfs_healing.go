@@ -0,0 +1,446 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+)
+
+// qsfsMetadataGroup is the group of the qsfs metadata backends in the backends statuses
+const qsfsMetadataGroup = -1
+
+// QSFSHealOptions configures the replacement zdbs of a qsfs
+type QSFSHealOptions struct {
+	// NodeFilter filters the nodes of the replacement zdbs, the nodes of the qsfs backends are never used
+	NodeFilter types.NodeFilter
+	// SolutionType of the replacement zdbs deployments, defaults to qsfs/<name>
+	SolutionType string
+}
+
+// QSFSBackendStatus is the health of a qsfs backend
+type QSFSBackendStatus struct {
+	Backend workloads.Backend `json:"backend"`
+	// Group is the index of the data group of the backend, or -1 for metadata backends
+	Group      int    `json:"group"`
+	NodeID     uint32 `json:"node_id"`
+	ContractID uint64 `json:"contract_id"`
+	Healthy    bool   `json:"healthy"`
+	// Reason is why the backend is unhealthy
+	Reason string `json:"reason,omitempty"`
+	// Replacement is the backend replacing the unhealthy backend in the qsfs
+	Replacement *workloads.Backend `json:"replacement,omitempty"`
+}
+
+// QSFSHealReport reports the degraded backends of a qsfs and the backends replacing them
+type QSFSHealReport struct {
+	// DegradedShards is the number of unhealthy data backends
+	DegradedShards int `json:"degraded_shards"`
+	// RestoredShards is the number of unhealthy data backends replaced with new zdbs
+	RestoredShards int `json:"restored_shards"`
+	// DegradedMetadata is the number of unhealthy metadata backends
+	DegradedMetadata int `json:"degraded_metadata"`
+	// RestoredMetadata is the number of unhealthy metadata backends replaced with new zdbs
+	RestoredMetadata int `json:"restored_metadata"`
+	// Backends are the statuses of all the qsfs backends
+	Backends []QSFSBackendStatus `json:"backends"`
+	// Deployments are the deployments of the replacement zdbs
+	Deployments []*workloads.Deployment `json:"-"`
+}
+
+// CheckBackends checks the contracts, nodes and zdbs of each metadata and data backend of the qsfs
+func (d *QSFSDeployer) CheckBackends(ctx context.Context, qsfs workloads.QSFS) ([]QSFSBackendStatus, error) {
+	statuses, _, err := d.checkBackends(ctx, qsfs)
+	return statuses, err
+}
+
+// Heal replaces the unhealthy backends of the qsfs in the deployment with zdbs deployed on healthy nodes
+// and updates the qsfs workload so zstor rebalances its data on them. backends are replaced as long as
+// nodes are found for them, the report has the degraded and restored shards of the qsfs
+func (d *QSFSDeployer) Heal(ctx context.Context, dl *workloads.Deployment, qsfsName string, opts QSFSHealOptions) (QSFSHealReport, error) {
+	idx := -1
+	for i := range dl.QSFS {
+		if dl.QSFS[i].Name == qsfsName {
+			idx = i
+			break
+		}
+	}
+
+	if idx == -1 {
+		return QSFSHealReport{}, errors.Errorf("could not find qsfs %s in deployment %s", qsfsName, dl.Name)
+	}
+
+	qsfs := dl.QSFS[idx]
+	if opts.SolutionType == "" {
+		opts.SolutionType = fmt.Sprintf("qsfs/%s", qsfsName)
+	}
+
+	statuses, zdbs, err := d.checkBackends(ctx, qsfs)
+	if err != nil {
+		return QSFSHealReport{}, err
+	}
+
+	report := QSFSHealReport{Backends: statuses}
+	for _, status := range statuses {
+		if status.Healthy {
+			continue
+		}
+
+		if status.Group == qsfsMetadataGroup {
+			report.DegradedMetadata++
+		} else {
+			report.DegradedShards++
+		}
+	}
+
+	if report.DegradedShards+report.DegradedMetadata == 0 {
+		return report, nil
+	}
+
+	// replacement zdbs have the same size as the healthy zdbs of the same kind
+	var dataSizeGB, metadataSizeGB uint64
+	for i, zdb := range zdbs {
+		if statuses[i].Group == qsfsMetadataGroup {
+			metadataSizeGB = max(metadataSizeGB, zdb.SizeGB)
+		} else {
+			dataSizeGB = max(dataSizeGB, zdb.SizeGB)
+		}
+	}
+
+	if (report.DegradedShards > 0 && dataSizeGB == 0) || (report.DegradedMetadata > 0 && metadataSizeGB == 0) {
+		return report, errors.New("could not find a healthy backend to get the size of the replacement zdbs from")
+	}
+
+	nodes, err := d.replacementNodes(ctx, qsfsName, statuses, dataSizeGB, metadataSizeGB, opts.NodeFilter)
+	if err != nil {
+		return report, err
+	}
+
+	report.Deployments = replacementDeployments(qsfsName, opts.SolutionType, statuses, nodes, dataSizeGB, metadataSizeGB)
+	cancel := func(err error) error {
+		cluster := QSFSCluster{Deployments: report.Deployments}
+		return multierror.Append(err, d.Cancel(ctx, &cluster))
+	}
+
+	if err := d.tfPluginClient.DeploymentDeployer.BatchDeploy(ctx, report.Deployments); err != nil {
+		return report, cancel(errors.Wrap(err, "failed to deploy replacement zdbs"))
+	}
+
+	replacements := make(map[string]workloads.Backend)
+	for _, replacement := range report.Deployments {
+		for _, zdb := range replacement.Zdbs {
+			loaded, err := d.tfPluginClient.State.LoadZdbFromGrid(ctx, replacement.NodeID, zdb.Name, replacement.Name)
+			if err != nil {
+				return report, cancel(errors.Wrapf(err, "failed to load zdb %s on node %d", zdb.Name, replacement.NodeID))
+			}
+
+			address, err := zdbBackendAddress(loaded)
+			if err != nil {
+				return report, cancel(errors.Wrapf(err, "invalid zdb %s on node %d", zdb.Name, replacement.NodeID))
+			}
+
+			replacements[replacementKey(replacement.NodeID, zdb.Name)] = workloads.Backend{Address: address, Namespace: loaded.Namespace, Password: loaded.Password}
+		}
+	}
+
+	healed := replaceBackends(qsfs, statuses, nodes, replacements)
+	dl.QSFS[idx] = healed
+	if err := d.tfPluginClient.DeploymentDeployer.Deploy(ctx, dl); err != nil {
+		dl.QSFS[idx] = qsfs
+		return report, cancel(errors.Wrapf(err, "failed to update qsfs %s backends", qsfsName))
+	}
+
+	wl, _, err := d.tfPluginClient.State.GetWorkloadInDeployment(ctx, dl.NodeID, qsfsName, dl.Name)
+	if err != nil {
+		return report, errors.Wrapf(err, "failed to load qsfs %s from node %d", qsfsName, dl.NodeID)
+	}
+
+	if err := dl.QSFS[idx].UpdateFromWorkload(&wl); err != nil {
+		return report, errors.Wrapf(err, "failed to update qsfs %s from its workload", qsfsName)
+	}
+
+	for _, status := range statuses {
+		if status.Replacement == nil {
+			continue
+		}
+
+		if status.Group == qsfsMetadataGroup {
+			report.RestoredMetadata++
+		} else {
+			report.RestoredShards++
+		}
+	}
+
+	return report, nil
+}
+
+// checkBackends returns the statuses of the qsfs backends and their zdbs, unhealthy backends have empty zdbs
+func (d *QSFSDeployer) checkBackends(ctx context.Context, qsfs workloads.QSFS) ([]QSFSBackendStatus, []workloads.ZDB, error) {
+	var statuses []QSFSBackendStatus
+	for _, backend := range qsfs.Metadata.Backends {
+		statuses = append(statuses, QSFSBackendStatus{Backend: backend, Group: qsfsMetadataGroup})
+	}
+
+	for group := range qsfs.Groups {
+		for _, backend := range qsfs.Groups[group].Backends {
+			statuses = append(statuses, QSFSBackendStatus{Backend: backend, Group: group})
+		}
+	}
+
+	zdbs := make([]workloads.ZDB, len(statuses))
+	for i := range statuses {
+		zdb, err := d.checkBackend(ctx, &statuses[i])
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to check backend %s", statuses[i].Backend.Address)
+		}
+		zdbs[i] = zdb
+	}
+
+	return statuses, zdbs, nil
+}
+
+// checkBackend sets the backend status from its contract, its node status and its zdb on the node,
+// errors are only returned if the backend health can't be known
+func (d *QSFSDeployer) checkBackend(ctx context.Context, status *QSFSBackendStatus) (workloads.ZDB, error) {
+	contractID, zdbName, err := parseZDBNamespace(status.Backend.Namespace)
+	if err != nil {
+		status.Reason = err.Error()
+		return workloads.ZDB{}, nil
+	}
+	status.ContractID = contractID
+
+	contract, err := d.tfPluginClient.SubstrateConn.GetContract(contractID)
+	if errors.Is(err, substrate.ErrNotFound) {
+		status.Reason = fmt.Sprintf("contract %d is not found", contractID)
+		return workloads.ZDB{}, nil
+	} else if err != nil {
+		return workloads.ZDB{}, errors.Wrapf(err, "failed to get contract %d", contractID)
+	}
+
+	if contract.IsDeleted() || !contract.ContractType.IsNodeContract {
+		status.Reason = fmt.Sprintf("contract %d is not an active node contract", contractID)
+		return workloads.ZDB{}, nil
+	}
+	status.NodeID = uint32(contract.ContractType.NodeContract.Node)
+
+	nodeStatus, err := d.tfPluginClient.GridProxyClient.NodeStatus(ctx, status.NodeID)
+	if err != nil {
+		return workloads.ZDB{}, errors.Wrapf(err, "failed to get node %d status", status.NodeID)
+	}
+
+	if nodeStatus.Status != "up" {
+		status.Reason = fmt.Sprintf("node %d is %s", status.NodeID, nodeStatus.Status)
+		return workloads.ZDB{}, nil
+	}
+
+	// the zdb is loaded from its contract deployment which could be deployed by another client
+	zdb, err := d.loadZDB(ctx, status.NodeID, contractID, zdbName)
+	if err != nil {
+		status.Reason = fmt.Sprintf("zdb %s is not reachable: %s", zdbName, err)
+		return workloads.ZDB{}, nil
+	}
+
+	if zdb.Namespace != status.Backend.Namespace {
+		status.Reason = fmt.Sprintf("zdb %s namespace is %s", zdbName, zdb.Namespace)
+		return workloads.ZDB{}, nil
+	}
+
+	status.Healthy = true
+	return zdb, nil
+}
+
+// loadZDB loads a zdb from the deployment of its contract without adding the contract to the state
+func (d *QSFSDeployer) loadZDB(ctx context.Context, nodeID uint32, contractID uint64, name string) (workloads.ZDB, error) {
+	nodeClient, err := d.tfPluginClient.NcPool.GetNodeClient(d.tfPluginClient.SubstrateConn, nodeID)
+	if err != nil {
+		return workloads.ZDB{}, errors.Wrapf(err, "could not get node client: %d", nodeID)
+	}
+
+	dl, err := nodeClient.DeploymentGet(ctx, contractID)
+	if err != nil {
+		return workloads.ZDB{}, errors.Wrapf(err, "could not get deployment %d from node %d", contractID, nodeID)
+	}
+
+	for _, wl := range dl.Workloads {
+		if wl.Name == name {
+			return workloads.NewZDBFromWorkload(&wl)
+		}
+	}
+
+	return workloads.ZDB{}, errors.Errorf("deployment %d has no zdb %s", contractID, name)
+}
+
+// replacementNodes returns healthy nodes for the replacement zdbs, a node is needed for
+// each unhealthy backend of the group with the most unhealthy backends
+func (d *QSFSDeployer) replacementNodes(ctx context.Context, qsfsName string, statuses []QSFSBackendStatus, dataSizeGB, metadataSizeGB uint64, filter types.NodeFilter) ([]uint32, error) {
+	used := make(map[uint32]bool)
+	degraded := make(map[int]int)
+	var needed int
+	for _, status := range statuses {
+		if status.NodeID != 0 {
+			used[status.NodeID] = true
+		}
+
+		if !status.Healthy {
+			degraded[status.Group]++
+			needed = max(needed, degraded[status.Group])
+		}
+	}
+
+	// the first node has a replacement of each group with unhealthy backends
+	var sizes []uint64
+	for group := range degraded {
+		if group == qsfsMetadataGroup {
+			sizes = append(sizes, gbToBytes(metadataSizeGB))
+		} else {
+			sizes = append(sizes, gbToBytes(dataSizeGB))
+		}
+	}
+
+	if filter.Status == nil {
+		filter.Status = []string{"up"}
+	}
+
+	if filter.FreeHRU == nil {
+		var freeHRU uint64
+		for _, size := range sizes {
+			freeHRU += size
+		}
+		filter.FreeHRU = &freeHRU
+	}
+
+	found, err := FilterNodes(ctx, *d.tfPluginClient, filter, nil, sizes, nil, uint64(needed+len(used)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find nodes for qsfs %s replacement zdbs", qsfsName)
+	}
+
+	var nodes []uint32
+	for _, node := range found {
+		if len(nodes) < needed && !used[uint32(node.NodeID)] {
+			nodes = append(nodes, uint32(node.NodeID))
+		}
+	}
+
+	if len(nodes) == 0 {
+		return nil, errors.Errorf("could not find nodes for qsfs %s replacement zdbs", qsfsName)
+	}
+
+	return nodes, nil
+}
+
+// replacementDeployments returns the deployments of the replacement zdbs, the nth unhealthy backend
+// of each group is replaced on the nth node. backends are not replaced if there are not enough nodes
+func replacementDeployments(qsfsName, solutionType string, statuses []QSFSBackendStatus, nodes []uint32, dataSizeGB, metadataSizeGB uint64) []*workloads.Deployment {
+	nodesZDBs := make([][]workloads.ZDB, len(nodes))
+	degraded := make(map[int]int)
+	for _, status := range statuses {
+		if status.Healthy {
+			continue
+		}
+
+		idx := degraded[status.Group]
+		degraded[status.Group]++
+		if idx >= len(nodes) {
+			continue
+		}
+
+		zdb := workloads.ZDB{
+			Name:        qsfsMetadataZDB,
+			Password:    status.Backend.Password,
+			Public:      true,
+			SizeGB:      metadataSizeGB,
+			Description: fmt.Sprintf("qsfs %s metadata backend", qsfsName),
+			Mode:        workloads.ZDBModeUser,
+		}
+		if status.Group != qsfsMetadataGroup {
+			zdb.Name = qsfsDataZDB(uint32(status.Group))
+			zdb.SizeGB = dataSizeGB
+			zdb.Description = fmt.Sprintf("qsfs %s data backend", qsfsName)
+			zdb.Mode = workloads.ZDBModeSeq
+		}
+		nodesZDBs[idx] = append(nodesZDBs[idx], zdb)
+	}
+
+	var dls []*workloads.Deployment
+	for idx, nodeID := range nodes {
+		dl := workloads.NewDeployment(fmt.Sprintf("%szdbs", qsfsName), nodeID, solutionType, nil, "", nil, nodesZDBs[idx], nil, nil, nil, nil)
+		dls = append(dls, &dl)
+	}
+
+	return dls
+}
+
+// replaceBackends returns a copy of the qsfs with its unhealthy backends replaced, the replacements
+// are set on the statuses of the backends they replace
+func replaceBackends(qsfs workloads.QSFS, statuses []QSFSBackendStatus, nodes []uint32, replacements map[string]workloads.Backend) workloads.QSFS {
+	qsfs.Metadata.Backends = append(workloads.Backends{}, qsfs.Metadata.Backends...)
+	qsfs.Groups = append(workloads.Groups{}, qsfs.Groups...)
+	for i := range qsfs.Groups {
+		qsfs.Groups[i].Backends = append(workloads.Backends{}, qsfs.Groups[i].Backends...)
+	}
+
+	degraded := make(map[int]int)
+	for i, status := range statuses {
+		if status.Healthy {
+			continue
+		}
+
+		idx := degraded[status.Group]
+		degraded[status.Group]++
+		if idx >= len(nodes) {
+			continue
+		}
+
+		name := qsfsMetadataZDB
+		backends := qsfs.Metadata.Backends
+		if status.Group != qsfsMetadataGroup {
+			name = qsfsDataZDB(uint32(status.Group))
+			backends = qsfs.Groups[status.Group].Backends
+		}
+
+		replacement, ok := replacements[replacementKey(nodes[idx], name)]
+		if !ok {
+			continue
+		}
+
+		for j := range backends {
+			if backends[j] == status.Backend {
+				backends[j] = replacement
+				statuses[i].Replacement = &replacement
+				break
+			}
+		}
+	}
+
+	return qsfs
+}
+
+func replacementKey(nodeID uint32, zdbName string) string {
+	return fmt.Sprintf("%d/%s", nodeID, zdbName)
+}
+
+// parseZDBNamespace returns the contract id and the zdb name of a zdb namespace, zos names
+// namespaces as <twin id>-<contract id>-<zdb name>
+func parseZDBNamespace(namespace string) (uint64, string, error) {
+	parts := strings.SplitN(namespace, "-", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return 0, "", errors.Errorf("invalid zdb namespace %s", namespace)
+	}
+
+	if _, err := strconv.ParseUint(parts[0], 10, 32); err != nil {
+		return 0, "", errors.Errorf("invalid zdb namespace %s twin id", namespace)
+	}
+
+	contractID, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, "", errors.Errorf("invalid zdb namespace %s contract id", namespace)
+	}
+
+	return contractID, parts[2], nil
+}
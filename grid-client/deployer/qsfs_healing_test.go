@@ -0,0 +1,154 @@
+package deployer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/state"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	proxyTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+)
+
+func TestQSFSHealing(t *testing.T) {
+	t.Run("zdb namespace", func(t *testing.T) {
+		contractID, name, err := parseZDBNamespace("12-345-data0")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(345), contractID)
+		assert.Equal(t, "data0", name)
+
+		for _, namespace := range []string{"", "12-345", "12-345-", "twin-345-data0", "12-contract-data0"} {
+			_, _, err := parseZDBNamespace(namespace)
+			assert.Error(t, err, namespace)
+		}
+	})
+
+	backend := func(namespace string) workloads.Backend {
+		return workloads.Backend{Address: "[302:9e63:7d43:b742::1]:9900", Namespace: namespace, Password: "password"}
+	}
+
+	qsfs := workloads.QSFS{
+		Name:     "qsfs",
+		Metadata: workloads.Metadata{Backends: workloads.Backends{backend("1-1-meta"), backend("1-2-meta")}},
+		Groups: workloads.Groups{
+			{Backends: workloads.Backends{backend("1-1-data0"), backend("1-2-data0"), backend("1-3-data0")}},
+			{Backends: workloads.Backends{backend("1-1-data1"), backend("1-2-data1"), backend("1-3-data1")}},
+		},
+	}
+
+	// node 2 is down and contract 3 is canceled
+	statuses := []QSFSBackendStatus{
+		{Backend: backend("1-1-meta"), Group: qsfsMetadataGroup, NodeID: 1, Healthy: true},
+		{Backend: backend("1-2-meta"), Group: qsfsMetadataGroup, NodeID: 2},
+		{Backend: backend("1-1-data0"), Group: 0, NodeID: 1, Healthy: true},
+		{Backend: backend("1-2-data0"), Group: 0, NodeID: 2},
+		{Backend: backend("1-3-data0"), Group: 0},
+		{Backend: backend("1-1-data1"), Group: 1, NodeID: 1, Healthy: true},
+		{Backend: backend("1-2-data1"), Group: 1, NodeID: 2},
+		{Backend: backend("1-3-data1"), Group: 1},
+	}
+
+	t.Run("replacement deployments", func(t *testing.T) {
+		dls := replacementDeployments("qsfs", "qsfs/qsfs", statuses, []uint32{4, 5}, 2, 1)
+		require.Len(t, dls, 2)
+
+		assert.Equal(t, uint32(4), dls[0].NodeID)
+		assert.Equal(t, "qsfszdbs", dls[0].Name)
+		require.Len(t, dls[0].Zdbs, 3)
+		assert.Equal(t, "meta", dls[0].Zdbs[0].Name)
+		assert.Equal(t, uint64(1), dls[0].Zdbs[0].SizeGB)
+		assert.Equal(t, workloads.ZDBModeUser, dls[0].Zdbs[0].Mode)
+		assert.Equal(t, "data0", dls[0].Zdbs[1].Name)
+		assert.Equal(t, uint64(2), dls[0].Zdbs[1].SizeGB)
+		assert.Equal(t, workloads.ZDBModeSeq, dls[0].Zdbs[1].Mode)
+		assert.Equal(t, "password", dls[0].Zdbs[1].Password)
+
+		require.Len(t, dls[1].Zdbs, 2)
+		assert.Equal(t, []string{"data0", "data1"}, []string{dls[1].Zdbs[0].Name, dls[1].Zdbs[1].Name})
+	})
+
+	t.Run("replace backends", func(t *testing.T) {
+		statuses := append([]QSFSBackendStatus{}, statuses...)
+		replacements := map[string]workloads.Backend{
+			replacementKey(4, "meta"):  backend("1-4-meta"),
+			replacementKey(4, "data0"): backend("1-4-data0"),
+			replacementKey(4, "data1"): backend("1-4-data1"),
+		}
+
+		// only one node is found for the replacements
+		healed := replaceBackends(qsfs, statuses, []uint32{4}, replacements)
+
+		assert.Equal(t, workloads.Backends{backend("1-1-meta"), backend("1-4-meta")}, healed.Metadata.Backends)
+		assert.Equal(t, workloads.Backends{backend("1-1-data0"), backend("1-4-data0"), backend("1-3-data0")}, healed.Groups[0].Backends)
+		assert.Equal(t, workloads.Backends{backend("1-1-data1"), backend("1-4-data1"), backend("1-3-data1")}, healed.Groups[1].Backends)
+
+		// the qsfs is not changed
+		assert.Equal(t, backend("1-2-meta"), qsfs.Metadata.Backends[1])
+		assert.Equal(t, backend("1-2-data0"), qsfs.Groups[0].Backends[1])
+
+		var replaced []string
+		for _, status := range statuses {
+			if status.Replacement != nil {
+				replaced = append(replaced, status.Backend.Namespace)
+			}
+		}
+		assert.Equal(t, []string{"1-2-meta", "1-2-data0", "1-2-data1"}, replaced)
+	})
+
+	t.Run("check backend", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cl := mocks.NewRMBMockClient(ctrl)
+		sub := mocks.NewMockSubstrateExt(ctrl)
+		ncPool := mocks.NewMockNodeClientGetter(ctrl)
+		gridProxy := mocks.NewMockClient(ctrl)
+
+		tfPluginClient := TFPluginClient{
+			SubstrateConn:   sub,
+			NcPool:          ncPool,
+			GridProxyClient: gridProxy,
+			State:           state.NewState(ncPool, sub),
+		}
+		d := NewQSFSDeployer(&tfPluginClient)
+
+		zdb := workloads.ZDB{Name: "data0", Password: "password", Mode: workloads.ZDBModeSeq, SizeGB: 1}
+		wl := zdb.ZosWorkload()
+		wl.Result.Data = zosTypes.MustMarshal(zosTypes.ZDBResult{Namespace: "1-5-data0"})
+
+		// the zdb is deployed by another client
+		sub.EXPECT().GetContract(uint64(5)).Return(subi.Contract{Contract: &substrate.Contract{
+			State: substrate.ContractState{IsCreated: true},
+			ContractType: substrate.ContractType{
+				IsNodeContract: true,
+				NodeContract:   substrate.NodeContract{Node: 7},
+			},
+		}}, nil)
+		gridProxy.EXPECT().NodeStatus(gomock.Any(), uint32(7)).Return(proxyTypes.NodeStatus{Status: "up"}, nil)
+		ncPool.EXPECT().GetNodeClient(sub, uint32(7)).Return(client.NewNodeClient(17, cl, 10), nil)
+		cl.EXPECT().
+			Call(gomock.Any(), uint32(17), "zos.deployment.get", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ uint32, _ string, _, result interface{}) error {
+				*result.(*zosTypes.Deployment) = zosTypes.Deployment{Workloads: []zosTypes.Workload{wl}}
+				return nil
+			})
+
+		status := QSFSBackendStatus{Backend: backend("1-5-data0")}
+		loaded, err := d.checkBackend(context.Background(), &status)
+		require.NoError(t, err)
+
+		assert.True(t, status.Healthy, status.Reason)
+		assert.Equal(t, uint32(7), status.NodeID)
+		assert.Equal(t, "1-5-data0", loaded.Namespace)
+
+		// the backend contract is not added to the state
+		assert.Empty(t, tfPluginClient.State.CurrentNodeDeployments)
+	})
+}
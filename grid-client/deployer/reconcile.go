@@ -0,0 +1,318 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/pkg/errors"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/state"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+// DriftKind is the kind of difference between a desired and a deployed node deployment
+type DriftKind string
+
+const (
+	// DriftContractDeleted the node contract was canceled or never created
+	DriftContractDeleted DriftKind = "contract_deleted"
+	// DriftContractGracePeriod the node contract is in grace period, it is still alive and is never reapplied
+	DriftContractGracePeriod DriftKind = "contract_grace_period"
+	// DriftWorkloadMissing a desired workload is not deployed
+	DriftWorkloadMissing DriftKind = "workload_missing"
+	// DriftWorkloadUnexpected a deployed workload is not desired
+	DriftWorkloadUnexpected DriftKind = "workload_unexpected"
+	// DriftWorkloadChanged a deployed workload is different from the desired one
+	DriftWorkloadChanged DriftKind = "workload_changed"
+	// DriftWorkloadFailed a deployed workload is in an error or deleted state
+	DriftWorkloadFailed DriftKind = "workload_failed"
+)
+
+// Drift is a difference between a desired and a deployed node deployment
+type Drift struct {
+	Kind       DriftKind `json:"kind"`
+	NodeID     uint32    `json:"node_id"`
+	ContractID uint64    `json:"contract_id"`
+	Workload   string    `json:"workload,omitempty"`
+	// Details describes the difference, e.g. changed env vars or missing mounts
+	Details []string `json:"details,omitempty"`
+}
+
+// DriftReport is the drift found between the desired and the deployed deployments
+type DriftReport struct {
+	Drifts []Drift `json:"drifts"`
+	// Reapplied is true if the desired deployments were applied again to fix the drift
+	Reapplied bool `json:"reapplied"`
+}
+
+// HasDrift returns true if any drift is found
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Drifts) != 0
+}
+
+// Drift compares the desired deployments with the ones deployed on the nodes given their contract IDs
+func (d *Deployer) Drift(ctx context.Context,
+	deploymentIDs map[uint32]uint64,
+	desired map[uint32]zos.Deployment,
+) (DriftReport, error) {
+	var report DriftReport
+
+	for node, dl := range desired {
+		contractID := deploymentIDs[node]
+		if contractID != 0 {
+			contract, err := d.substrateConn.GetContract(contractID)
+			if err != nil && !errors.Is(err, substrate.ErrNotFound) {
+				return DriftReport{}, errors.Wrapf(err, "could not check contract %d", contractID)
+			}
+
+			if err != nil || contract.IsDeleted() {
+				contractID = 0
+			} else if contract.IsGracePeriod() {
+				// the workloads of a contract in grace period are paused until it is funded again
+				report.Drifts = append(report.Drifts, Drift{
+					Kind:       DriftContractGracePeriod,
+					NodeID:     node,
+					ContractID: contractID,
+				})
+				continue
+			}
+		}
+
+		if contractID == 0 {
+			report.Drifts = append(report.Drifts, Drift{
+				Kind:       DriftContractDeleted,
+				NodeID:     node,
+				ContractID: deploymentIDs[node],
+			})
+			continue
+		}
+
+		client, err := d.ncPool.GetNodeClient(d.substrateConn, node)
+		if err != nil {
+			return DriftReport{}, errors.Wrapf(err, "failed to get node %d client", node)
+		}
+
+		live, err := client.DeploymentGet(ctx, contractID)
+		if err != nil {
+			return DriftReport{}, errors.Wrapf(err, "failed to get deployment %d from node %d", contractID, node)
+		}
+
+		drifts, err := deploymentDrift(node, live, dl)
+		if err != nil {
+			return DriftReport{}, err
+		}
+		report.Drifts = append(report.Drifts, drifts...)
+	}
+
+	sort.SliceStable(report.Drifts, func(i, j int) bool {
+		return report.Drifts[i].NodeID < report.Drifts[j].NodeID
+	})
+
+	return report, nil
+}
+
+// Reconcile reports the drift between the desired and the deployed deployments,
+// if reapply is set the desired deployments are deployed again and failed workloads are redeployed.
+// deployments with contracts in grace period are left untouched.
+// it returns the current deployments' IDs
+func (d *Deployer) Reconcile(ctx context.Context,
+	deploymentIDs map[uint32]uint64,
+	desired map[uint32]zos.Deployment,
+	solutionProviders map[uint32]*uint64,
+	reapply bool,
+) (map[uint32]uint64, DriftReport, error) {
+	report, err := d.Drift(ctx, deploymentIDs, desired)
+	if err != nil || !reapply || !report.HasDrift() {
+		return deploymentIDs, report, err
+	}
+
+	currentIDs := make(map[uint32]uint64, len(deploymentIDs))
+	for node, contractID := range deploymentIDs {
+		currentIDs[node] = contractID
+	}
+
+	reapplyDeployments := make(map[uint32]zos.Deployment, len(desired))
+	for node, dl := range desired {
+		reapplyDeployments[node] = dl
+	}
+
+	gracePeriodIDs := make(map[uint32]uint64)
+	failedWorkloads := make(map[uint32]map[string]bool)
+	for _, drift := range report.Drifts {
+		switch drift.Kind {
+		case DriftContractDeleted:
+			// deleted contracts are created again
+			delete(currentIDs, drift.NodeID)
+		case DriftContractGracePeriod:
+			// the contract is still alive, deploying it again would create a second contract on the node
+			gracePeriodIDs[drift.NodeID] = drift.ContractID
+			delete(currentIDs, drift.NodeID)
+			delete(reapplyDeployments, drift.NodeID)
+		case DriftWorkloadFailed:
+			if failedWorkloads[drift.NodeID] == nil {
+				failedWorkloads[drift.NodeID] = make(map[string]bool)
+			}
+			failedWorkloads[drift.NodeID][drift.Workload] = true
+		}
+	}
+
+	if len(gracePeriodIDs) == len(report.Drifts) {
+		return deploymentIDs, report, nil
+	}
+
+	// failed workloads have the same hash as the desired ones, so they are redeployed explicitly
+	for node, workloads := range failedWorkloads {
+		client, err := d.ncPool.GetNodeClient(d.substrateConn, node)
+		if err != nil {
+			return currentIDs, report, errors.Wrapf(err, "failed to get node %d client", node)
+		}
+
+		live, err := client.DeploymentGet(ctx, currentIDs[node])
+		if err != nil {
+			return currentIDs, report, errors.Wrapf(err, "failed to get deployment %d from node %d", currentIDs[node], node)
+		}
+
+		dl := desired[node]
+		dl.Workloads = slices.Clone(dl.Workloads)
+		matchOldVersions(&live, &dl)
+		if err := d.updateDeployment(ctx, client, node, live, dl, workloads); err != nil {
+			return currentIDs, report, errors.Wrapf(err, "failed to redeploy failed workloads on node %d", node)
+		}
+	}
+
+	currentIDs, err = d.Deploy(ctx, currentIDs, reapplyDeployments, solutionProviders)
+	for node, contractID := range gracePeriodIDs {
+		currentIDs[node] = contractID
+	}
+	if err != nil {
+		return currentIDs, report, errors.Wrap(err, "failed to reapply deployments")
+	}
+
+	report.Reapplied = true
+	return currentIDs, report, nil
+}
+
+// deploymentDrift compares a live node deployment with the desired one
+func deploymentDrift(node uint32, live, desired zos.Deployment) ([]Drift, error) {
+	var drifts []Drift
+
+	// work on a copy of the workloads so the desired deployment versions stay untouched
+	dl := desired
+	dl.Workloads = slices.Clone(desired.Workloads)
+	matchOldVersions(&live, &dl)
+
+	liveHashes, err := GetWorkloadHashes(live)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get deployment %d workloads hashes", live.ContractID)
+	}
+
+	desiredHashes, err := GetWorkloadHashes(dl)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get desired workloads hashes")
+	}
+
+	liveWorkloads := make(map[string]zos.Workload)
+	for _, wl := range live.Workloads {
+		liveWorkloads[wl.Name] = wl
+	}
+
+	for _, wl := range dl.Workloads {
+		drift := Drift{NodeID: node, ContractID: live.ContractID, Workload: wl.Name}
+
+		liveWl, ok := liveWorkloads[wl.Name]
+		switch {
+		case !ok:
+			drift.Kind = DriftWorkloadMissing
+		case desiredHashes[wl.Name] != liveHashes[wl.Name]:
+			drift.Kind = DriftWorkloadChanged
+			drift.Details = workloadDriftDetails(liveWl, wl)
+		case liveWl.Result.State == zos.StateError || liveWl.Result.State == zos.StateDeleted:
+			drift.Kind = DriftWorkloadFailed
+			drift.Details = []string{fmt.Sprintf("workload state is %s: %s", liveWl.Result.State, liveWl.Result.Error)}
+		default:
+			continue
+		}
+
+		drifts = append(drifts, drift)
+	}
+
+	for _, wl := range live.Workloads {
+		if _, ok := desiredHashes[wl.Name]; !ok {
+			drifts = append(drifts, Drift{
+				Kind:       DriftWorkloadUnexpected,
+				NodeID:     node,
+				ContractID: live.ContractID,
+				Workload:   wl.Name,
+			})
+		}
+	}
+
+	return drifts, nil
+}
+
+// workloadDriftDetails describes the changes of a zmachine env vars and mounts
+func workloadDriftDetails(live, desired zos.Workload) []string {
+	if live.Type != zos.ZMachineType || desired.Type != zos.ZMachineType {
+		return nil
+	}
+
+	liveVM, err := live.ZMachineWorkload()
+	if err != nil {
+		return nil
+	}
+
+	desiredVM, err := desired.ZMachineWorkload()
+	if err != nil {
+		return nil
+	}
+
+	var details []string
+	for key, value := range desiredVM.Env {
+		liveValue, ok := liveVM.Env[key]
+		if !ok {
+			details = append(details, fmt.Sprintf("env %s is missing", key))
+		} else if liveValue != value {
+			details = append(details, fmt.Sprintf("env %s changed", key))
+		}
+	}
+
+	for key := range liveVM.Env {
+		if _, ok := desiredVM.Env[key]; !ok {
+			details = append(details, fmt.Sprintf("env %s is not desired", key))
+		}
+	}
+
+	liveMounts := make(map[string]string)
+	for _, mount := range liveVM.Mounts {
+		liveMounts[string(mount.Name)] = mount.Mountpoint
+	}
+
+	for _, mount := range desiredVM.Mounts {
+		mountpoint, ok := liveMounts[string(mount.Name)]
+		if !ok {
+			details = append(details, fmt.Sprintf("mount %s is missing", mount.Name))
+		} else if mountpoint != mount.Mountpoint {
+			details = append(details, fmt.Sprintf("mount %s moved from %s to %s", mount.Name, mountpoint, mount.Mountpoint))
+		}
+	}
+
+	sort.Strings(details)
+	return details
+}
+
+// updateStateContracts replaces the old contracts of the nodes in the state with the current ones
+func updateStateContracts(s *state.State, oldIDs, currentIDs map[uint32]uint64) {
+	for node, contractID := range oldIDs {
+		if currentIDs[node] != contractID {
+			s.RemoveContractIDs(node, contractID)
+		}
+	}
+
+	for node, contractID := range currentIDs {
+		if contractID != 0 {
+			s.StoreContractIDs(node, contractID)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package deployer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+func TestDeployerDrift(t *testing.T) {
+	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
+	require.NoError(t, err)
+	twinID := uint32(1)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cl := mocks.NewRMBMockClient(ctrl)
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	ncPool := mocks.NewMockNodeClientGetter(ctrl)
+
+	d := Deployer{
+		identity:      identity,
+		twinID:        twinID,
+		ncPool:        ncPool,
+		substrateConn: sub,
+	}
+
+	liveGateway, err := deploymentWithNameGateway(identity, twinID, true, 1, backendURLWithTLSPassthrough)
+	require.NoError(t, err)
+	liveGateway.ContractID = 100
+
+	ncPool.EXPECT().
+		GetNodeClient(sub, uint32(10)).
+		Return(client.NewNodeClient(13, cl, 10), nil).AnyTimes()
+
+	contract := func(state substrate.ContractState) subi.Contract {
+		return subi.Contract{Contract: &substrate.Contract{State: state}}
+	}
+
+	var live zosTypes.Deployment
+	cl.EXPECT().
+		Call(gomock.Any(), uint32(13), "zos.deployment.get", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+			*result.(*zosTypes.Deployment) = live
+			return nil
+		}).AnyTimes()
+
+	t.Run("no drift", func(t *testing.T) {
+		live = liveGateway
+		desired, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+		require.NoError(t, err)
+
+		sub.EXPECT().GetContract(uint64(100)).Return(contract(substrate.ContractState{IsCreated: true}), nil)
+
+		report, err := d.Drift(context.Background(), map[uint32]uint64{10: 100}, map[uint32]zosTypes.Deployment{10: desired})
+		require.NoError(t, err)
+		assert.False(t, report.HasDrift())
+	})
+
+	t.Run("contract deleted", func(t *testing.T) {
+		desired, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+		require.NoError(t, err)
+
+		sub.EXPECT().GetContract(uint64(100)).Return(contract(substrate.ContractState{IsDeleted: true}), nil)
+		sub.EXPECT().GetContract(uint64(101)).Return(subi.Contract{}, substrate.ErrNotFound)
+
+		report, err := d.Drift(context.Background(), map[uint32]uint64{10: 100, 11: 101}, map[uint32]zosTypes.Deployment{10: desired, 11: desired})
+		require.NoError(t, err)
+		require.Len(t, report.Drifts, 2)
+		assert.Equal(t, DriftContractDeleted, report.Drifts[0].Kind)
+		assert.Equal(t, uint64(100), report.Drifts[0].ContractID)
+		assert.Equal(t, DriftContractDeleted, report.Drifts[1].Kind)
+		assert.Equal(t, uint64(101), report.Drifts[1].ContractID)
+	})
+
+	t.Run("contract in grace period", func(t *testing.T) {
+		desired, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+		require.NoError(t, err)
+
+		sub.EXPECT().GetContract(uint64(100)).Return(contract(substrate.ContractState{IsGracePeriod: true}), nil).Times(2)
+
+		deploymentIDs := map[uint32]uint64{10: 100}
+		report, err := d.Drift(context.Background(), deploymentIDs, map[uint32]zosTypes.Deployment{10: desired})
+		require.NoError(t, err)
+		require.Len(t, report.Drifts, 1)
+		assert.Equal(t, DriftContractGracePeriod, report.Drifts[0].Kind)
+		assert.Equal(t, uint64(100), report.Drifts[0].ContractID)
+
+		// the contract is kept and no new contract is created for it
+		currentIDs, report, err := d.Reconcile(context.Background(), deploymentIDs, map[uint32]zosTypes.Deployment{10: desired}, nil, true)
+		require.NoError(t, err)
+		assert.False(t, report.Reapplied)
+		assert.Equal(t, deploymentIDs, currentIDs)
+	})
+
+	t.Run("workloads drift", func(t *testing.T) {
+		live = liveGateway
+		live.Workloads = append(
+			[]zosTypes.Workload{live.Workloads[0]},
+			workloads.ConstructPublicIPWorkload("ip", true, false),
+		)
+		live.Workloads[1].Result.State = zosTypes.StateError
+
+		desired, err := deploymentWithNameGateway(identity, twinID, true, 0, "2.2.2.2:10")
+		require.NoError(t, err)
+		desired.Workloads = append(desired.Workloads, workloads.ConstructPublicIPWorkload("ip", true, false))
+		desired.Workloads = append(desired.Workloads, workloads.ConstructPublicIPWorkload("ip2", true, false))
+
+		sub.EXPECT().GetContract(uint64(100)).Return(contract(substrate.ContractState{IsCreated: true}), nil)
+
+		report, err := d.Drift(context.Background(), map[uint32]uint64{10: 100}, map[uint32]zosTypes.Deployment{10: desired})
+		require.NoError(t, err)
+		require.Len(t, report.Drifts, 3)
+
+		assert.Equal(t, DriftWorkloadChanged, report.Drifts[0].Kind)
+		assert.Equal(t, "name", report.Drifts[0].Workload)
+		assert.Equal(t, DriftWorkloadFailed, report.Drifts[1].Kind)
+		assert.Equal(t, "ip", report.Drifts[1].Workload)
+		assert.Equal(t, DriftWorkloadMissing, report.Drifts[2].Kind)
+		assert.Equal(t, "ip2", report.Drifts[2].Workload)
+
+		// the desired deployment versions are untouched
+		assert.Equal(t, uint32(0), desired.Workloads[0].Version)
+	})
+
+	t.Run("zmachine details", func(t *testing.T) {
+		vm := func(env map[string]string, mounts []zosTypes.MachineMount) zosTypes.Workload {
+			return zosTypes.Workload{
+				Name: "vm",
+				Type: zosTypes.ZMachineType,
+				Data: zosTypes.MustMarshal(zosTypes.ZMachine{Env: env, Mounts: mounts}),
+			}
+		}
+
+		live := vm(map[string]string{"A": "1", "B": "2"}, []zosTypes.MachineMount{{Name: "disk", Mountpoint: "/data"}})
+		desired := vm(
+			map[string]string{"A": "2", "C": "3"},
+			[]zosTypes.MachineMount{{Name: "disk", Mountpoint: "/mnt"}, {Name: "volume", Mountpoint: "/volume"}},
+		)
+
+		assert.Equal(t, []string{
+			"env A changed",
+			"env B is not desired",
+			"env C is missing",
+			"mount disk moved from /data to /mnt",
+			"mount volume is missing",
+		}, workloadDriftDetails(live, desired))
+	})
+}
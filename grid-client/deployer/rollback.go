@@ -0,0 +1,134 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+// RollbackReport describes what was reverted after a failed deployment
+type RollbackReport struct {
+	// RestoredNodes maps the nodes restored to their old deployments to their contract IDs
+	RestoredNodes map[uint32]uint64 `json:"restored_nodes"`
+	// CanceledContracts are the contracts created during the failed call and canceled on rollback
+	CanceledContracts []uint64 `json:"canceled_contracts"`
+}
+
+// RollbackError is returned when a deployment failed and its changes were rolled back
+type RollbackError struct {
+	Err    error
+	Report RollbackReport
+}
+
+// Error returns the error of the failed deployment with a summary of the rollback
+func (e *RollbackError) Error() string {
+	return fmt.Sprintf(
+		"%s; rolled back: restored %d node deployments and canceled %d contracts",
+		e.Err, len(e.Report.RestoredNodes), len(e.Report.CanceledContracts),
+	)
+}
+
+// Unwrap returns the error of the failed deployment
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// rollback restores the old deployments after a failed deploy and reports what was reverted
+func (d *Deployer) rollback(
+	ctx context.Context,
+	oldDeploymentIDs map[uint32]uint64,
+	currentDeployments map[uint32]uint64,
+	oldDeployments map[uint32]zos.Deployment,
+	newDeploymentSolutionProvider map[uint32]*uint64,
+) (map[uint32]uint64, RollbackReport, error) {
+	report := RollbackReport{RestoredNodes: map[uint32]uint64{}}
+	changedNodes := map[uint32]struct{}{}
+
+	for node, contractID := range currentDeployments {
+		oldContractID, ok := oldDeploymentIDs[node]
+		if !ok || oldContractID != contractID {
+			report.CanceledContracts = append(report.CanceledContracts, contractID)
+			// a replaced deployment is restored on a new contract
+			if ok {
+				changedNodes[node] = struct{}{}
+			}
+			continue
+		}
+
+		// updated nodes have a different version than their old deployments
+		if d.deploymentVersionChanged(ctx, node, contractID, oldDeployments[node].Version) {
+			changedNodes[node] = struct{}{}
+		}
+	}
+
+	// nodes canceled during the failed call are recreated
+	for node := range oldDeploymentIDs {
+		if _, ok := currentDeployments[node]; !ok {
+			changedNodes[node] = struct{}{}
+		}
+	}
+
+	currentDls, err := d.deploy(ctx, currentDeployments, oldDeployments, newDeploymentSolutionProvider, false)
+
+	for node := range changedNodes {
+		if contractID, ok := currentDls[node]; ok {
+			report.RestoredNodes[node] = contractID
+		}
+	}
+	sort.Slice(report.CanceledContracts, func(i, j int) bool {
+		return report.CanceledContracts[i] < report.CanceledContracts[j]
+	})
+
+	return currentDls, report, err
+}
+
+// deploymentVersionChanged checks if a node deployment version is different from the given version,
+// a deployment that cannot be read is considered changed
+func (d *Deployer) deploymentVersionChanged(ctx context.Context, node uint32, contractID uint64, version uint32) bool {
+	client, err := d.ncPool.GetNodeClient(d.substrateConn, node)
+	if err != nil {
+		return true
+	}
+
+	dl, err := client.DeploymentGet(ctx, contractID)
+	if err != nil {
+		return true
+	}
+
+	return dl.Version != version
+}
+
+// rollbackBatch cancels the contracts of the successful deployments of a failed batch along with the failed ones
+func (d *Deployer) rollbackBatch(deployments []zos.Deployment, failedContracts []uint64) (RollbackReport, error) {
+	report := RollbackReport{RestoredNodes: map[uint32]uint64{}}
+	for i := range deployments {
+		if deployments[i].ContractID == 0 {
+			continue
+		}
+		report.CanceledContracts = append(report.CanceledContracts, deployments[i].ContractID)
+		deployments[i].ContractID = 0
+	}
+
+	for _, contractID := range failedContracts {
+		if contractID != 0 {
+			report.CanceledContracts = append(report.CanceledContracts, contractID)
+		}
+	}
+
+	if len(report.CanceledContracts) == 0 {
+		return report, nil
+	}
+
+	sort.Slice(report.CanceledContracts, func(i, j int) bool {
+		return report.CanceledContracts[i] < report.CanceledContracts[j]
+	})
+
+	if err := d.substrateConn.BatchCancelContract(d.identity, report.CanceledContracts); err != nil {
+		return report, errors.Wrapf(err, "failed to cancel contracts %v", report.CanceledContracts)
+	}
+
+	return report, nil
+}
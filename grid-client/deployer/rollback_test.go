@@ -0,0 +1,136 @@
+package deployer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
+	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+func TestDeployerRollback(t *testing.T) {
+	identity, err := substrate.NewIdentityFromSr25519Phrase("//Alice")
+	require.NoError(t, err)
+	twinID := uint32(1)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cl := mocks.NewRMBMockClient(ctrl)
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	ncPool := mocks.NewMockNodeClientGetter(ctrl)
+
+	d := Deployer{
+		identity:        identity,
+		twinID:          twinID,
+		ncPool:          ncPool,
+		substrateConn:   sub,
+		revertOnFailure: true,
+	}
+
+	t.Run("restore updated nodes and cancel created contracts", func(t *testing.T) {
+		oldDl, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+		require.NoError(t, err)
+		oldDl.ContractID = 100
+
+		updatedDl, err := deploymentWithNameGateway(identity, twinID, true, 1, "2.2.2.2:10")
+		require.NoError(t, err)
+		updatedDl.ContractID = 100
+
+		ncPool.EXPECT().
+			GetNodeClient(sub, uint32(10)).
+			Return(client.NewNodeClient(13, cl, 10), nil).AnyTimes()
+
+		cl.EXPECT().
+			Call(gomock.Any(), uint32(13), "zos.deployment.get", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+				*result.(*zosTypes.Deployment) = updatedDl
+				return nil
+			}).AnyTimes()
+
+		sub.EXPECT().EnsureContractCanceled(identity, uint64(200)).Return(nil)
+		sub.EXPECT().UpdateNodeContract(identity, uint64(100), "", gomock.Any()).Return(uint64(100), nil)
+
+		var restoredDl zosTypes.Deployment
+		cl.EXPECT().
+			Call(gomock.Any(), uint32(13), "zos.deployment.update", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+				restoredDl = data.(zosTypes.Deployment)
+				for i := range restoredDl.Workloads {
+					restoredDl.Workloads[i].Result.State = zosTypes.StateOk
+				}
+				return nil
+			})
+
+		cl.EXPECT().
+			Call(gomock.Any(), uint32(13), "zos.deployment.changes", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, twin uint32, fn string, data, result interface{}) error {
+				*result.(*[]zosTypes.Workload) = restoredDl.Workloads
+				return nil
+			})
+
+		contracts, report, err := d.rollback(
+			context.Background(),
+			map[uint32]uint64{10: 100},
+			map[uint32]uint64{10: 100, 20: 200},
+			map[uint32]zosTypes.Deployment{10: oldDl},
+			nil,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, map[uint32]uint64{10: 100}, contracts)
+		assert.Equal(t, map[uint32]uint64{10: 100}, report.RestoredNodes)
+		assert.Equal(t, []uint64{200}, report.CanceledContracts)
+		assert.Equal(t, uint32(2), restoredDl.Version)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		dls := []zosTypes.Deployment{{ContractID: 100}, {}, {ContractID: 200}}
+
+		sub.EXPECT().BatchCancelContract(identity, []uint64{100, 200, 300}).Return(nil)
+
+		report, err := d.rollbackBatch(dls, []uint64{300, 0})
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{100, 200, 300}, report.CanceledContracts)
+		for _, dl := range dls {
+			assert.Zero(t, dl.ContractID)
+		}
+	})
+
+	t.Run("batch node client failure", func(t *testing.T) {
+		dl, err := deploymentWithNameGateway(identity, twinID, true, 0, backendURLWithTLSPassthrough)
+		require.NoError(t, err)
+
+		// the node client is loaded before creating the contracts then fails while deploying
+		gomock.InOrder(
+			ncPool.EXPECT().GetNodeClient(sub, uint32(30)).Return(client.NewNodeClient(33, cl, 10), nil),
+			ncPool.EXPECT().GetNodeClient(sub, uint32(30)).Return(nil, errors.New("node is down")),
+		)
+		sub.EXPECT().BatchCreateContract(identity, gomock.Any()).Return([]uint64{300}, nil, nil)
+		sub.EXPECT().BatchCancelContract(identity, []uint64{300}).Return(nil)
+
+		dls, err := d.BatchDeploy(context.Background(), map[uint32][]zosTypes.Deployment{30: {dl}}, nil)
+		var rollbackErr *RollbackError
+		require.True(t, errors.As(err, &rollbackErr))
+		assert.Equal(t, []uint64{300}, rollbackErr.Report.CanceledContracts)
+		assert.Zero(t, dls[30][0].ContractID)
+	})
+
+	t.Run("rollback error", func(t *testing.T) {
+		deployErr := errors.New("node is down")
+		var err error = &RollbackError{
+			Err:    deployErr,
+			Report: RollbackReport{CanceledContracts: []uint64{200}},
+		}
+
+		var rollbackErr *RollbackError
+		require.True(t, errors.As(err, &rollbackErr))
+		assert.Equal(t, []uint64{200}, rollbackErr.Report.CanceledContracts)
+		assert.ErrorIs(t, err, deployErr)
+	})
+}
@@ -0,0 +1,314 @@
+package deployer
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/graphql"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
+	proxyTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// RentContractsProject is the project of rent contracts as they have no deployment data
+	RentContractsProject = "rent contracts"
+	// UnknownProject is the project of contracts with no or invalid deployment data
+	UnknownProject = "unknown"
+
+	billsPageSize    = 100
+	billsWorkers     = 10
+	spendReportDay   = "2006-01-02"
+	tftUnitsPerToken = 1e7
+)
+
+// contractStates are all the contracts states, deleted contracts are included for their old bills
+var contractStates = []string{"Created", "GracePeriod", "OutOfFunds", "Deleted"}
+
+// SpendReportOptions filters the contracts and the bills of a spend report
+type SpendReportOptions struct {
+	// Projects are the reported projects, all projects are reported if empty
+	Projects []string
+	// Since and Until limit the bills time range, zero values are unbounded
+	Since time.Time
+	Until time.Time
+}
+
+// Spend is an amount billed in TFT and its USD value with the current TFT price
+type Spend struct {
+	TFT float64 `json:"tft"`
+	USD float64 `json:"usd"`
+}
+
+// NodeSpend is the spend of a project on a node
+type NodeSpend struct {
+	NodeID uint32 `json:"node_id"`
+	Spend
+}
+
+// DaySpend is the spend of a project in a day (UTC)
+type DaySpend struct {
+	Day string `json:"day"`
+	Spend
+}
+
+// ProjectSpend is the spend of a project with its per node and per day totals
+type ProjectSpend struct {
+	Project string `json:"project"`
+	Spend
+	Nodes []NodeSpend `json:"nodes"`
+	Days  []DaySpend  `json:"days"`
+}
+
+// SpendReport is the billing history of the twin contracts grouped by projects
+type SpendReport struct {
+	// TFTPrice is the USD price of TFT used for USD values
+	TFTPrice float64 `json:"tft_price"`
+	Spend
+	Projects []ProjectSpend `json:"projects"`
+}
+
+// reportedContract is a contract with its project and node
+type reportedContract struct {
+	id      uint64
+	project string
+	nodeID  uint32
+}
+
+// SpendReport builds a spend report of the twin contracts from their bills,
+// contracts are grouped by the project name in their deployment data
+func (t *TFPluginClient) SpendReport(ctx context.Context, opts SpendReportOptions) (SpendReport, error) {
+	contracts, err := t.ContractsGetter.ListContractsByTwinID(contractStates)
+	if err != nil {
+		return SpendReport{}, errors.Wrap(err, "failed to list contracts")
+	}
+
+	tftPrice, err := t.Calculator.TFTPrice()
+	if err != nil {
+		return SpendReport{}, errors.Wrap(err, "failed to get tft price")
+	}
+
+	reported := groupContractsByProject(contracts)
+	if len(opts.Projects) != 0 {
+		reported = slices.DeleteFunc(reported, func(c reportedContract) bool {
+			return !slices.Contains(opts.Projects, c.project)
+		})
+	}
+
+	bills := make([][]proxyTypes.ContractBilling, len(reported))
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(billsWorkers)
+	for i, contract := range reported {
+		i, contract := i, contract
+		group.Go(func() error {
+			contractBills, err := t.contractBills(ctx, contract.id)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get contract %d bills", contract.id)
+			}
+
+			bills[i] = contractBills
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return SpendReport{}, err
+	}
+
+	builder := newSpendReportBuilder(tftPrice)
+	for i, contract := range reported {
+		for _, bill := range bills[i] {
+			billed := time.Unix(int64(bill.Timestamp), 0).UTC()
+			if (!opts.Since.IsZero() && billed.Before(opts.Since)) || (!opts.Until.IsZero() && !billed.Before(opts.Until)) {
+				continue
+			}
+
+			builder.add(contract, billed, bill.AmountBilled)
+		}
+	}
+
+	return builder.report(), nil
+}
+
+// contractBills gets all the bills of a contract from the grid proxy
+func (t *TFPluginClient) contractBills(ctx context.Context, contractID uint64) ([]proxyTypes.ContractBilling, error) {
+	var bills []proxyTypes.ContractBilling
+	for page := uint64(1); ; page++ {
+		pageBills, total, err := t.GridProxyClient.ContractBills(ctx, uint32(contractID), proxyTypes.Limit{
+			Page:     page,
+			Size:     billsPageSize,
+			RetCount: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		bills = append(bills, pageBills...)
+		if len(pageBills) == 0 || uint(len(bills)) >= total {
+			return bills, nil
+		}
+	}
+}
+
+// groupContractsByProject assigns the contracts to projects, name contracts are assigned
+// to the project of the name gateway using them
+func groupContractsByProject(contracts graphql.Contracts) []reportedContract {
+	var reported []reportedContract
+	gatewayProjects := map[string]string{}
+
+	for _, contract := range contracts.NodeContracts {
+		id, err := strconv.ParseUint(contract.ContractID, 10, 64)
+		if err != nil {
+			log.Warn().Err(err).Str("id", contract.ContractID).Msg("got contract with invalid id")
+			continue
+		}
+
+		project := UnknownProject
+		if data, err := workloads.ParseDeploymentData(contract.DeploymentData); err == nil && data.ProjectName != "" {
+			project = data.ProjectName
+			if data.Type == "Gateway Name" {
+				gatewayProjects[data.Name] = project
+			}
+		}
+
+		reported = append(reported, reportedContract{id: id, project: project, nodeID: contract.NodeID})
+	}
+
+	for _, contract := range contracts.NameContracts {
+		id, err := strconv.ParseUint(contract.ContractID, 10, 64)
+		if err != nil {
+			log.Warn().Err(err).Str("id", contract.ContractID).Msg("got contract with invalid id")
+			continue
+		}
+
+		project, ok := gatewayProjects[contract.Name]
+		if !ok {
+			project = UnknownProject
+		}
+
+		reported = append(reported, reportedContract{id: id, project: project})
+	}
+
+	for _, contract := range contracts.RentContracts {
+		id, err := strconv.ParseUint(contract.ContractID, 10, 64)
+		if err != nil {
+			log.Warn().Err(err).Str("id", contract.ContractID).Msg("got contract with invalid id")
+			continue
+		}
+
+		reported = append(reported, reportedContract{id: id, project: RentContractsProject, nodeID: contract.NodeID})
+	}
+
+	return reported
+}
+
+type projectTotals struct {
+	total Spend
+	nodes map[uint32]*Spend
+	days  map[string]*Spend
+}
+
+type spendReportBuilder struct {
+	tftPrice float64
+	projects map[string]*projectTotals
+}
+
+func newSpendReportBuilder(tftPrice float64) *spendReportBuilder {
+	return &spendReportBuilder{tftPrice: tftPrice, projects: map[string]*projectTotals{}}
+}
+
+// add adds a bill amount in TFT units to the contract project totals
+func (b *spendReportBuilder) add(contract reportedContract, billed time.Time, amount uint64) {
+	tft := float64(amount) / tftUnitsPerToken
+	spend := Spend{TFT: tft, USD: tft * b.tftPrice}
+
+	project, ok := b.projects[contract.project]
+	if !ok {
+		project = &projectTotals{nodes: map[uint32]*Spend{}, days: map[string]*Spend{}}
+		b.projects[contract.project] = project
+	}
+
+	project.total.add(spend)
+
+	if contract.nodeID != 0 {
+		if _, ok := project.nodes[contract.nodeID]; !ok {
+			project.nodes[contract.nodeID] = &Spend{}
+		}
+		project.nodes[contract.nodeID].add(spend)
+	}
+
+	day := billed.Format(spendReportDay)
+	if _, ok := project.days[day]; !ok {
+		project.days[day] = &Spend{}
+	}
+	project.days[day].add(spend)
+}
+
+func (b *spendReportBuilder) report() SpendReport {
+	report := SpendReport{TFTPrice: b.tftPrice, Projects: []ProjectSpend{}}
+
+	for name, totals := range b.projects {
+		project := ProjectSpend{Project: name, Spend: totals.total, Nodes: []NodeSpend{}, Days: []DaySpend{}}
+
+		for nodeID, spend := range totals.nodes {
+			project.Nodes = append(project.Nodes, NodeSpend{NodeID: nodeID, Spend: *spend})
+		}
+		sort.Slice(project.Nodes, func(i, j int) bool { return project.Nodes[i].NodeID < project.Nodes[j].NodeID })
+
+		for day, spend := range totals.days {
+			project.Days = append(project.Days, DaySpend{Day: day, Spend: *spend})
+		}
+		sort.Slice(project.Days, func(i, j int) bool { return project.Days[i].Day < project.Days[j].Day })
+
+		report.Spend.add(totals.total)
+		report.Projects = append(report.Projects, project)
+	}
+
+	sort.Slice(report.Projects, func(i, j int) bool { return report.Projects[i].Project < report.Projects[j].Project })
+	return report
+}
+
+func (s *Spend) add(o Spend) {
+	s.TFT += o.TFT
+	s.USD += o.USD
+}
+
+// WriteCSV writes the report as csv rows of project, scope (total, node or day), key, tft and usd
+func (r *SpendReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	row := func(project, scope, key string, spend Spend) []string {
+		return []string{
+			project, scope, key,
+			strconv.FormatFloat(spend.TFT, 'f', 7, 64),
+			strconv.FormatFloat(spend.USD, 'f', 2, 64),
+		}
+	}
+
+	rows := [][]string{{"project", "scope", "key", "tft", "usd"}}
+	for _, project := range r.Projects {
+		rows = append(rows, row(project.Project, "total", "", project.Spend))
+		for _, node := range project.Nodes {
+			rows = append(rows, row(project.Project, "node", fmt.Sprint(node.NodeID), node.Spend))
+		}
+		for _, day := range project.Days {
+			rows = append(rows, row(project.Project, "day", day.Day, day.Spend))
+		}
+	}
+	rows = append(rows, row("", "total", "", r.Spend))
+
+	if err := writer.WriteAll(rows); err != nil {
+		return errors.Wrap(err, "failed to write spend report")
+	}
+
+	return nil
+}
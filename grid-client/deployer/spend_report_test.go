@@ -0,0 +1,108 @@
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/graphql"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+	proxyTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
+)
+
+func TestSpendReport(t *testing.T) {
+	contracts := graphql.Contracts{
+		NodeContracts: []graphql.Contract{
+			{ContractID: "1", NodeID: 10, DeploymentData: `{"type":"vm","name":"vm","projectName":"prod"}`},
+			{ContractID: "2", NodeID: 11, DeploymentData: `{"type":"Gateway Name","name":"web","projectName":"prod"}`},
+			{ContractID: "3", NodeID: 10, DeploymentData: `{"type":"vm","name":"vm","projectName":"staging"}`},
+			{ContractID: "4", NodeID: 12, DeploymentData: "invalid"},
+		},
+		NameContracts: []graphql.Contract{{ContractID: "5", Name: "web"}},
+		RentContracts: []graphql.Contract{{ContractID: "6", NodeID: 13}},
+	}
+
+	t.Run("group contracts by project", func(t *testing.T) {
+		assert.Equal(t, []reportedContract{
+			{id: 1, project: "prod", nodeID: 10},
+			{id: 2, project: "prod", nodeID: 11},
+			{id: 3, project: "staging", nodeID: 10},
+			{id: 4, project: UnknownProject, nodeID: 12},
+			{id: 5, project: "prod"},
+			{id: 6, project: RentContractsProject, nodeID: 13},
+		}, groupContractsByProject(contracts))
+	})
+
+	t.Run("build report", func(t *testing.T) {
+		day1 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+		day2 := day1.Add(24 * time.Hour)
+
+		builder := newSpendReportBuilder(0.5)
+		builder.add(reportedContract{id: 1, project: "prod", nodeID: 10}, day1, 2e7)
+		builder.add(reportedContract{id: 1, project: "prod", nodeID: 10}, day2, 1e7)
+		builder.add(reportedContract{id: 5, project: "prod"}, day2, 1e7)
+		builder.add(reportedContract{id: 3, project: "staging", nodeID: 10}, day1, 4e7)
+
+		report := builder.report()
+		assert.Equal(t, SpendReport{
+			TFTPrice: 0.5,
+			Spend:    Spend{TFT: 8, USD: 4},
+			Projects: []ProjectSpend{
+				{
+					Project: "prod",
+					Spend:   Spend{TFT: 4, USD: 2},
+					Nodes:   []NodeSpend{{NodeID: 10, Spend: Spend{TFT: 3, USD: 1.5}}},
+					Days: []DaySpend{
+						{Day: "2024-01-01", Spend: Spend{TFT: 2, USD: 1}},
+						{Day: "2024-01-02", Spend: Spend{TFT: 2, USD: 1}},
+					},
+				},
+				{
+					Project: "staging",
+					Spend:   Spend{TFT: 4, USD: 2},
+					Nodes:   []NodeSpend{{NodeID: 10, Spend: Spend{TFT: 4, USD: 2}}},
+					Days:    []DaySpend{{Day: "2024-01-01", Spend: Spend{TFT: 4, USD: 2}}},
+				},
+			},
+		}, report)
+
+		var buf bytes.Buffer
+		require.NoError(t, report.WriteCSV(&buf))
+		assert.Equal(t, `project,scope,key,tft,usd
+prod,total,,4.0000000,2.00
+prod,node,10,3.0000000,1.50
+prod,day,2024-01-01,2.0000000,1.00
+prod,day,2024-01-02,2.0000000,1.00
+staging,total,,4.0000000,2.00
+staging,node,10,4.0000000,2.00
+staging,day,2024-01-01,4.0000000,2.00
+,total,,8.0000000,4.00
+`, buf.String())
+	})
+
+	t.Run("contract bills pages", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		gridProxy := mocks.NewMockClient(ctrl)
+		tfPluginClient := TFPluginClient{GridProxyClient: gridProxy}
+
+		page := make([]proxyTypes.ContractBilling, billsPageSize)
+		gomock.InOrder(
+			gridProxy.EXPECT().
+				ContractBills(gomock.Any(), uint32(1), proxyTypes.Limit{Page: 1, Size: billsPageSize, RetCount: true}).
+				Return(page, uint(billsPageSize+1), nil),
+			gridProxy.EXPECT().
+				ContractBills(gomock.Any(), uint32(1), proxyTypes.Limit{Page: 2, Size: billsPageSize, RetCount: true}).
+				Return([]proxyTypes.ContractBilling{{AmountBilled: 1}}, uint(billsPageSize+1), nil),
+		)
+
+		bills, err := tfPluginClient.contractBills(context.Background(), 1)
+		require.NoError(t, err)
+		assert.Len(t, bills, billsPageSize+1)
+	})
+}
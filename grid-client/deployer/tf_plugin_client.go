@@ -23,6 +23,9 @@ import (
 	"github.com/vedhavyas/go-subkey"
 )
 
+// DefaultDeployWorkers is the default number of nodes a deployment is deployed on concurrently
+const DefaultDeployWorkers = 10
+
 // TFPluginClient is a Threefold plugin client
 type TFPluginClient struct {
 	TwinID         uint32
@@ -50,6 +53,7 @@ type TFPluginClient struct {
 	GatewayFQDNDeployer GatewayFQDNDeployer
 	GatewayNameDeployer GatewayNameDeployer
 	K8sDeployer         K8sDeployer
+	QSFSDeployer        QSFSDeployer
 
 	// state
 	State *state.State
@@ -61,19 +65,30 @@ type TFPluginClient struct {
 	// calculator
 	Calculator calculator.Calculator
 
+	eventSink          EventSink
+	deployWorkers      int
+	maxMonthlyCost     float64
+	replaceDataLoss    bool
 	cancelRelayContext context.CancelFunc
 }
 
 type pluginCfg struct {
-	keyType       string
-	network       string
-	substrateURLs []string
-	relayURLs     []string
-	proxyURLs     []string
-	graphqlURLs   []string
-	rmbTimeout    int
-	showLogs      bool
-	rmbInMemCache bool
+	keyType         string
+	network         string
+	substrateURLs   []string
+	relayURLs       []string
+	proxyURLs       []string
+	graphqlURLs     []string
+	rmbTimeout      int
+	showLogs        bool
+	rmbInMemCache   bool
+	stateStore      state.Store
+	eventSink       EventSink
+	deployWorkers   int
+	maxMonthlyCost  float64
+	replaceDataLoss bool
+	nodeCacheTTL    time.Duration
+	nodeCacheSize   int
 }
 
 type PluginOpt func(*pluginCfg)
@@ -132,6 +147,51 @@ func WithGraphQlURL(graphqlURLs ...string) PluginOpt {
 	}
 }
 
+// WithStateStore persists the client state in the given store so it survives restarts
+func WithStateStore(store state.Store) PluginOpt {
+	return func(p *pluginCfg) {
+		p.stateStore = store
+	}
+}
+
+// WithEventSink sends the deployments progress events to the given sink
+func WithEventSink(sink EventSink) PluginOpt {
+	return func(p *pluginCfg) {
+		p.eventSink = sink
+	}
+}
+
+// WithDeployWorkers sets the number of nodes a deployment is deployed on concurrently
+func WithDeployWorkers(workers int) PluginOpt {
+	return func(p *pluginCfg) {
+		p.deployWorkers = workers
+	}
+}
+
+// WithMaxMonthlyCost makes deployments refuse plans estimated to cost more than the given USD per month
+func WithMaxMonthlyCost(usd float64) PluginOpt {
+	return func(p *pluginCfg) {
+		p.maxMonthlyCost = usd
+	}
+}
+
+// WithReplaceDataLoss allows moving deployments to new contracts when their public ips count changes even
+// if the data of their disks, volumes and zdbs is lost, such deployments are refused otherwise
+func WithReplaceDataLoss() PluginOpt {
+	return func(p *pluginCfg) {
+		p.replaceDataLoss = true
+	}
+}
+
+// WithNodeCache sets the duration and maximum number of entries of the node twins and metadata cache,
+// a zero ttl disables caching
+func WithNodeCache(ttl time.Duration, size int) PluginOpt {
+	return func(p *pluginCfg) {
+		p.nodeCacheTTL = ttl
+		p.nodeCacheSize = size
+	}
+}
+
 func parsePluginOpts(opts ...PluginOpt) (pluginCfg, error) {
 	cfg := pluginCfg{
 		network:       "main",
@@ -143,6 +203,9 @@ func parsePluginOpts(opts ...PluginOpt) (pluginCfg, error) {
 		rmbTimeout:    60, // default rmbTimeout is 60
 		showLogs:      false,
 		rmbInMemCache: true,
+		deployWorkers: DefaultDeployWorkers,
+		nodeCacheTTL:  client.DefaultCacheTTL,
+		nodeCacheSize: client.DefaultCacheSize,
 	}
 
 	for _, o := range opts {
@@ -153,6 +216,18 @@ func parsePluginOpts(opts ...PluginOpt) (pluginCfg, error) {
 		return cfg, errors.Errorf("network must be one of %s, %s, %s, and %s not %s", DevNetwork, QaNetwork, TestNetwork, MainNetwork, cfg.network)
 	}
 
+	if cfg.deployWorkers <= 0 {
+		return cfg, errors.Errorf("deploy workers must be a positive number not %d", cfg.deployWorkers)
+	}
+
+	if cfg.nodeCacheTTL < 0 || cfg.nodeCacheSize < 0 {
+		return cfg, errors.Errorf("node cache ttl and size must not be negative, not %s and %d", cfg.nodeCacheTTL, cfg.nodeCacheSize)
+	}
+
+	if cfg.maxMonthlyCost < 0 {
+		return cfg, errors.Errorf("max monthly cost must not be negative, not %f", cfg.maxMonthlyCost)
+	}
+
 	if len(cfg.proxyURLs) == 0 {
 		cfg.proxyURLs = ProxyURLs[cfg.network]
 	}
@@ -245,6 +320,10 @@ func NewTFPluginClient(
 	tfPluginClient.proxyURLs = cfg.proxyURLs
 	tfPluginClient.graphqlURLs = cfg.graphqlURLs
 	tfPluginClient.relayURLs = cfg.relayURLs
+	tfPluginClient.eventSink = cfg.eventSink
+	tfPluginClient.deployWorkers = cfg.deployWorkers
+	tfPluginClient.maxMonthlyCost = cfg.maxMonthlyCost
+	tfPluginClient.replaceDataLoss = cfg.replaceDataLoss
 
 	manager := subi.NewManager(tfPluginClient.substrateURLs...)
 	sub, err := manager.SubstrateExt()
@@ -306,13 +385,19 @@ func NewTFPluginClient(
 	}
 	tfPluginClient.GridProxyClient = proxy.NewRetryingClient(gridProxyClient)
 
-	ncPool := client.NewNodeClientPool(tfPluginClient.RMB, tfPluginClient.RMBTimeout)
+	ncPool := client.NewNodeClientPool(
+		tfPluginClient.RMB,
+		tfPluginClient.RMBTimeout,
+		client.WithCacheTTL(cfg.nodeCacheTTL),
+		client.WithCacheSize(cfg.nodeCacheSize),
+	)
 	tfPluginClient.NcPool = ncPool
 
 	tfPluginClient.DeploymentDeployer = NewDeploymentDeployer(&tfPluginClient)
 	tfPluginClient.NetworkDeployer = NewNetworkDeployer(&tfPluginClient)
 	tfPluginClient.GatewayFQDNDeployer = NewGatewayFqdnDeployer(&tfPluginClient)
 	tfPluginClient.K8sDeployer = NewK8sDeployer(&tfPluginClient)
+	tfPluginClient.QSFSDeployer = NewQSFSDeployer(&tfPluginClient)
 	tfPluginClient.GatewayNameDeployer = NewGatewayNameDeployer(&tfPluginClient)
 
 	tfPluginClient.graphQl, err = graphql.NewGraphQl(tfPluginClient.graphqlURLs...)
@@ -323,8 +408,18 @@ func NewTFPluginClient(
 	tfPluginClient.ContractsGetter = graphql.NewContractsGetter(tfPluginClient.TwinID, tfPluginClient.graphQl, tfPluginClient.SubstrateConn, tfPluginClient.NcPool)
 
 	tfPluginClient.State = state.NewState(tfPluginClient.NcPool, tfPluginClient.SubstrateConn)
+	if cfg.stateStore != nil {
+		if err := tfPluginClient.State.SetStore(cfg.stateStore); err != nil {
+			return TFPluginClient{}, err
+		}
+
+		// drop contracts that were canceled since the state was saved
+		if err := tfPluginClient.State.ReconcileContracts(); err != nil {
+			return TFPluginClient{}, errors.Wrap(err, "could not reconcile stored state with the chain")
+		}
+	}
 
-	tfPluginClient.Calculator = calculator.NewCalculator(tfPluginClient.SubstrateConn, tfPluginClient.Identity)
+	tfPluginClient.Calculator = calculator.NewCalculator(tfPluginClient.SubstrateConn, tfPluginClient.Identity, tfPluginClient.GridProxyClient)
 
 	return tfPluginClient, nil
 }
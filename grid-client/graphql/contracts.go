@@ -3,10 +3,8 @@ package graphql
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -37,6 +35,9 @@ type Contract struct {
 	State          string `json:"state"`
 	DeploymentData string `json:"deploymentData"`
 
+	// CreatedAt is the contract creation time in seconds
+	CreatedAt string `json:"createdAt"`
+
 	// for node and rent contracts
 	NodeID uint32 `json:"nodeID"`
 	// for name contracts
@@ -57,63 +58,42 @@ func NewContractsGetter(twinID uint32, graphql GraphQl, substrateConn subi.Subst
 
 // ListContractsByTwinID returns contracts for a twinID
 func (c *ContractsGetter) ListContractsByTwinID(states []string) (Contracts, error) {
-	state := fmt.Sprintf(`[%v]`, strings.Join(states, ", "))
-	options := fmt.Sprintf(`(where: {twinID_eq: %v, state_in: %v}, orderBy: twinID_ASC)`, c.twinID, state)
+	ctx := context.Background()
+	filter := ContractsFilter{States: states}
 
-	nameContractsCount, err := c.graphql.GetItemTotalCount("nameContracts", options)
-	if err != nil {
-		return Contracts{}, err
-	}
+	var (
+		contracts Contracts
+		err       error
+	)
 
-	nodeContractsCount, err := c.graphql.GetItemTotalCount("nodeContracts", options)
+	contracts.NameContracts, err = c.ListContracts(ctx, NameContractType, filter)
 	if err != nil {
 		return Contracts{}, err
 	}
 
-	rentContractsCount, err := c.graphql.GetItemTotalCount("rentContracts", options)
+	contracts.NodeContracts, err = c.ListContracts(ctx, NodeContractType, filter)
 	if err != nil {
 		return Contracts{}, err
 	}
 
-	contractsData, err := c.graphql.Query(fmt.Sprintf(`query getContracts($nameContractsCount: Int!, $nodeContractsCount: Int!, $rentContractsCount: Int!){
-            nameContracts(where: {twinID_eq: %v, state_in: %v}, limit: $nameContractsCount) {
-              contractID
-              state
-              name
-            }
-            nodeContracts(where: {twinID_eq: %v, state_in: %v}, limit: $nodeContractsCount) {
-              contractID
-              deploymentData
-              state
-              nodeID
-            }
-            rentContracts(where: {twinID_eq: %v, state_in: %v}, limit: $rentContractsCount) {
-              contractID
-              state
-              nodeID
-            }
-          }`, c.twinID, state, c.twinID, state, c.twinID, state),
-		map[string]interface{}{
-			"nodeContractsCount": nodeContractsCount,
-			"nameContractsCount": nameContractsCount,
-			"rentContractsCount": rentContractsCount,
-		})
+	contracts.RentContracts, err = c.ListContracts(ctx, RentContractType, filter)
 	if err != nil {
 		return Contracts{}, err
 	}
 
-	contractsJSONData, err := json.Marshal(contractsData)
-	if err != nil {
-		return Contracts{}, err
-	}
+	return contracts, nil
+}
 
-	var listContracts Contracts
-	err = json.Unmarshal(contractsJSONData, &listContracts)
-	if err != nil {
-		return Contracts{}, err
-	}
+// StreamContracts streams the twin contracts of a contract type matching the filter
+func (c *ContractsGetter) StreamContracts(ctx context.Context, contractType ContractType, filter ContractsFilter) (<-chan Contract, <-chan error) {
+	filter.TwinID = c.twinID
+	return c.graphql.StreamContracts(ctx, ContractsQuery{Type: contractType, Filter: filter})
+}
 
-	return listContracts, nil
+// ListContracts returns the twin contracts of a contract type matching the filter
+func (c *ContractsGetter) ListContracts(ctx context.Context, contractType ContractType, filter ContractsFilter) ([]Contract, error) {
+	filter.TwinID = c.twinID
+	return c.graphql.ListContracts(ctx, ContractsQuery{Type: contractType, Filter: filter})
 }
 
 // ListContractsOfProjectName returns contracts for a project name
@@ -122,7 +102,7 @@ func (c *ContractsGetter) ListContractsOfProjectName(projectName string, noGatew
 		NodeContracts: make([]Contract, 0),
 		NameContracts: make([]Contract, 0),
 	}
-	contractsList, err := c.ListContractsByTwinID([]string{"Created, GracePeriod"})
+	contractsList, err := c.ListContractsByTwinID([]string{"Created", "GracePeriod"})
 	if err != nil {
 		return Contracts{}, err
 	}
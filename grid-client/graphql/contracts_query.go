@@ -0,0 +1,220 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ContractType is a graphql contracts entity
+type ContractType string
+
+// contract types
+const (
+	NodeContractType ContractType = "nodeContracts"
+	NameContractType ContractType = "nameContracts"
+	RentContractType ContractType = "rentContracts"
+)
+
+// DefaultContractsPageSize is the number of contracts fetched per page if no page size is set
+const DefaultContractsPageSize = 500
+
+// contractTypes are the graphql where input type and the queried fields of each contract type
+var contractTypes = map[ContractType]struct {
+	whereInput string
+	fields     string
+}{
+	NodeContractType: {whereInput: "NodeContractWhereInput", fields: "contractID state nodeID deploymentData createdAt"},
+	NameContractType: {whereInput: "NameContractWhereInput", fields: "contractID state name createdAt"},
+	RentContractType: {whereInput: "RentContractWhereInput", fields: "contractID state nodeID createdAt"},
+}
+
+// ContractsFilter filters queried contracts, zero values are not filtered
+type ContractsFilter struct {
+	TwinID uint32
+	States []string
+	// NodeIDs is only supported for node and rent contracts
+	NodeIDs []uint32
+	// CreatedAfter and CreatedBefore limit the contracts creation time range
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// SolutionType and ProjectName match the deployment data, only supported for node contracts
+	SolutionType string
+	ProjectName  string
+}
+
+// ContractsQuery is a typed graphql query of one contract type with cursor pagination
+type ContractsQuery struct {
+	Type     ContractType
+	Filter   ContractsFilter
+	PageSize int
+}
+
+// contractsPage is a page of a contracts connection
+type contractsPage struct {
+	Items struct {
+		Edges []struct {
+			Node Contract `json:"node"`
+		} `json:"edges"`
+		PageInfo struct {
+			HasNextPage bool   `json:"hasNextPage"`
+			EndCursor   string `json:"endCursor"`
+		} `json:"pageInfo"`
+	} `json:"items"`
+}
+
+// Build returns the query of the page after the given cursor with its variables,
+// filter values are passed as variables so they are never interpolated into the query
+func (q ContractsQuery) Build(after string) (string, map[string]interface{}, error) {
+	contractType, ok := contractTypes[q.Type]
+	if !ok {
+		return "", nil, errors.Errorf("invalid contract type %q", q.Type)
+	}
+
+	where, err := q.where()
+	if err != nil {
+		return "", nil, err
+	}
+
+	pageSize := q.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultContractsPageSize
+	}
+
+	query := fmt.Sprintf(`query getContracts($where: %s, $first: Int!, $after: String) {
+  items: %sConnection(where: $where, orderBy: contractID_ASC, first: $first, after: $after) {
+    edges { node { %s } }
+    pageInfo { hasNextPage endCursor }
+  }
+}`, contractType.whereInput, q.Type, contractType.fields)
+
+	variables := map[string]interface{}{
+		"where": where,
+		"first": pageSize,
+	}
+	if after != "" {
+		variables["after"] = after
+	}
+
+	return query, variables, nil
+}
+
+func (q ContractsQuery) where() (map[string]interface{}, error) {
+	f := q.Filter
+	where := map[string]interface{}{}
+
+	if f.TwinID != 0 {
+		where["twinID_eq"] = f.TwinID
+	}
+
+	if len(f.States) != 0 {
+		where["state_in"] = f.States
+	}
+
+	if len(f.NodeIDs) != 0 {
+		if q.Type == NameContractType {
+			return nil, errors.New("name contracts can not be filtered by node")
+		}
+		where["nodeID_in"] = f.NodeIDs
+	}
+
+	// createdAt is a BigInt of seconds which graphql takes as a string
+	if !f.CreatedAfter.IsZero() {
+		where["createdAt_gte"] = strconv.FormatInt(f.CreatedAfter.Unix(), 10)
+	}
+
+	if !f.CreatedBefore.IsZero() {
+		where["createdAt_lt"] = strconv.FormatInt(f.CreatedBefore.Unix(), 10)
+	}
+
+	var deploymentData []interface{}
+	for _, field := range []struct{ key, value string }{
+		{key: "type", value: f.SolutionType},
+		{key: "projectName", value: f.ProjectName},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if q.Type != NodeContractType {
+			return nil, errors.Errorf("only node contracts can be filtered by %s", field.key)
+		}
+
+		// json encoding matches the value as it is stored in the deployment data
+		encoded, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		deploymentData = append(deploymentData, map[string]interface{}{
+			"deploymentData_contains": fmt.Sprintf(`"%s":%s`, field.key, encoded),
+		})
+	}
+
+	if len(deploymentData) != 0 {
+		where["AND"] = deploymentData
+	}
+
+	return where, nil
+}
+
+// StreamContracts streams the contracts matching the query page by page, the contracts channel is closed
+// when all pages are fetched or on failure, in which case the error is sent on the errors channel
+func (g *GraphQl) StreamContracts(ctx context.Context, query ContractsQuery) (<-chan Contract, <-chan error) {
+	contracts := make(chan Contract)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(contracts)
+
+		var cursor string
+		for {
+			body, variables, err := query.Build(cursor)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			var page contractsPage
+			if err := g.queryInto(ctx, body, variables, &page); err != nil {
+				errs <- errors.Wrapf(err, "failed to query %s", query.Type)
+				return
+			}
+
+			for _, edge := range page.Items.Edges {
+				select {
+				case contracts <- edge.Node:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+
+			if !page.Items.PageInfo.HasNextPage {
+				return
+			}
+			cursor = page.Items.PageInfo.EndCursor
+		}
+	}()
+
+	return contracts, errs
+}
+
+// ListContracts returns all the contracts matching the query
+func (g *GraphQl) ListContracts(ctx context.Context, query ContractsQuery) ([]Contract, error) {
+	contracts, errs := g.StreamContracts(ctx, query)
+
+	result := []Contract{}
+	for contract := range contracts {
+		result = append(result, contract)
+	}
+
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
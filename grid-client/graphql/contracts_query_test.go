@@ -0,0 +1,121 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContractsQuery(t *testing.T) {
+	t.Run("build", func(t *testing.T) {
+		query := ContractsQuery{
+			Type: NodeContractType,
+			Filter: ContractsFilter{
+				TwinID:       1,
+				States:       []string{"Created", "GracePeriod"},
+				NodeIDs:      []uint32{11},
+				CreatedAfter: time.Unix(100, 0),
+				SolutionType: "vm",
+				ProjectName:  `my "project"`,
+			},
+			PageSize: 10,
+		}
+
+		body, variables, err := query.Build("cursor")
+		require.NoError(t, err)
+		assert.Contains(t, body, "$where: NodeContractWhereInput")
+		assert.Contains(t, body, "nodeContractsConnection(")
+		assert.NotContains(t, body, "project")
+
+		assert.Equal(t, map[string]interface{}{
+			"where": map[string]interface{}{
+				"twinID_eq":     uint32(1),
+				"state_in":      []string{"Created", "GracePeriod"},
+				"nodeID_in":     []uint32{11},
+				"createdAt_gte": "100",
+				"AND": []interface{}{
+					map[string]interface{}{"deploymentData_contains": `"type":"vm"`},
+					map[string]interface{}{"deploymentData_contains": `"projectName":"my \"project\""`},
+				},
+			},
+			"first": 10,
+			"after": "cursor",
+		}, variables)
+	})
+
+	t.Run("invalid filters", func(t *testing.T) {
+		_, _, err := ContractsQuery{Type: "contracts"}.Build("")
+		assert.Error(t, err)
+
+		_, _, err = ContractsQuery{Type: NameContractType, Filter: ContractsFilter{NodeIDs: []uint32{1}}}.Build("")
+		assert.Error(t, err)
+
+		_, _, err = ContractsQuery{Type: RentContractType, Filter: ContractsFilter{ProjectName: "project"}}.Build("")
+		assert.Error(t, err)
+	})
+
+	t.Run("stream pages", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var request struct {
+				Variables map[string]interface{} `json:"variables"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+			after, _ := request.Variables["after"].(string)
+			next := map[string]string{"": "2", "2": "4"}[after]
+			first, _ := request.Variables["first"].(float64)
+			assert.Equal(t, float64(2), first)
+
+			start := 1
+			if after != "" {
+				fmt.Sscan(after, &start)
+				start++
+			}
+
+			var edges []string
+			for id := start; id < start+2 && id <= 5; id++ {
+				edges = append(edges, fmt.Sprintf(`{"node": {"contractID": "%d", "state": "Created", "nodeID": 1}}`, id))
+			}
+
+			fmt.Fprintf(w, `{"data": {"items": {"edges": [%s], "pageInfo": {"hasNextPage": %t, "endCursor": "%s"}}}}`,
+				strings.Join(edges, ","), next != "", next)
+		}))
+		defer server.Close()
+
+		g, err := NewGraphQl(server.URL)
+		require.NoError(t, err)
+
+		contracts, err := g.ListContracts(context.Background(), ContractsQuery{Type: NodeContractType, PageSize: 2})
+		require.NoError(t, err)
+
+		var ids []string
+		for _, contract := range contracts {
+			ids = append(ids, contract.ContractID)
+		}
+		assert.Equal(t, []string{"1", "2", "3", "4", "5"}, ids)
+	})
+
+	t.Run("query errors", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"data": null, "errors": [{"message": "invalid where input"}]}`)
+		}))
+		defer server.Close()
+
+		g, err := NewGraphQl(server.URL)
+		require.NoError(t, err)
+
+		contracts, errs := g.StreamContracts(context.Background(), ContractsQuery{Type: RentContractType})
+		for range contracts {
+			t.Fatal("no contracts are expected")
+		}
+		assert.ErrorContains(t, <-errs, "invalid where input")
+	})
+}
@@ -3,6 +3,7 @@ package graphql
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -39,9 +40,7 @@ func (g *GraphQl) GetItemTotalCount(itemName string, options string) (float64, e
 		return 0, err
 	}
 
-	bodyReader := bytes.NewReader(jsonBody)
-
-	countResponse, err := g.httpPost(bodyReader)
+	countResponse, err := g.httpPost(context.Background(), jsonBody)
 	if err != nil {
 		return 0, err
 	}
@@ -68,9 +67,7 @@ func (g *GraphQl) Query(body string, variables map[string]interface{}) (map[stri
 		return result, err
 	}
 
-	bodyReader := bytes.NewReader(jsonBody)
-
-	resp, err := g.httpPost(bodyReader)
+	resp, err := g.httpPost(context.Background(), jsonBody)
 	if err != nil {
 		return result, err
 	}
@@ -84,6 +81,43 @@ func (g *GraphQl) Query(body string, variables map[string]interface{}) (map[stri
 	return result, nil
 }
 
+// graphqlResponse is a graphql response with its data kept raw for typed decoding
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// queryInto queries graphql and decodes the response data into result
+func (g *GraphQl) queryInto(ctx context.Context, body string, variables map[string]interface{}, result interface{}) error {
+	jsonBody, err := json.Marshal(map[string]interface{}{"query": body, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	resp, err := g.httpPost(ctx, jsonBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var response graphqlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return errors.Wrapf(err, "failed to decode response with status code: %d", resp.StatusCode)
+	}
+
+	if len(response.Errors) != 0 {
+		return errors.Errorf("query failed with error: %s", response.Errors[0].Message)
+	}
+
+	if resp.StatusCode >= 400 {
+		return errors.Errorf("request failed with status code: %d", resp.StatusCode)
+	}
+
+	return json.Unmarshal(response.Data, result)
+}
+
 func parseHTTPResponse(resp *http.Response) (map[string]interface{}, error) {
 	resBody, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -105,7 +139,7 @@ func parseHTTPResponse(resp *http.Response) (map[string]interface{}, error) {
 	return data, nil
 }
 
-func (g *GraphQl) httpPost(body io.Reader) (*http.Response, error) {
+func (g *GraphQl) httpPost(ctx context.Context, body []byte) (*http.Response, error) {
 	cl := &http.Client{
 		Timeout: 10 * time.Second,
 	}
@@ -125,7 +159,14 @@ func (g *GraphQl) httpPost(body io.Reader) (*http.Response, error) {
 		endpoint = g.urls[g.activeStackIdx]
 		log.Debug().Str("url", endpoint).Msg("checking")
 
-		resp, reqErr = cl.Post(endpoint, "application/json", body)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			reqErr = err
+			return backoff.Permanent(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, reqErr = cl.Do(req)
 		if reqErr != nil &&
 			(errors.Is(reqErr, http.ErrAbortHandler) ||
 				errors.Is(reqErr, http.ErrHandlerTimeout) ||
@@ -325,6 +325,21 @@ func (mr *MockSubstrateExtMockRecorder) GetContractIDByNameRegistration(name int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContractIDByNameRegistration", reflect.TypeOf((*MockSubstrateExt)(nil).GetContractIDByNameRegistration), name)
 }
 
+// GetCurrentHeight mocks base method.
+func (m *MockSubstrateExt) GetCurrentHeight() (uint32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCurrentHeight")
+	ret0, _ := ret[0].(uint32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCurrentHeight indicates an expected call of GetCurrentHeight.
+func (mr *MockSubstrateExtMockRecorder) GetCurrentHeight() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCurrentHeight", reflect.TypeOf((*MockSubstrateExt)(nil).GetCurrentHeight))
+}
+
 // GetNodeTwin mocks base method.
 func (m *MockSubstrateExt) GetNodeTwin(id uint32) (uint32, error) {
 	m.ctrl.T.Helper()
@@ -400,6 +415,20 @@ func (mr *MockSubstrateExtMockRecorder) IsValidContract(contractID interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsValidContract", reflect.TypeOf((*MockSubstrateExt)(nil).IsValidContract), contractID)
 }
 
+// Transfer mocks base method.
+func (m *MockSubstrateExt) Transfer(identity substrate.Identity, amount uint64, destination substrate.AccountID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", identity, amount, destination)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockSubstrateExtMockRecorder) Transfer(identity, amount, destination interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockSubstrateExt)(nil).Transfer), identity, amount, destination)
+}
+
 // UpdateNodeContract mocks base method.
 func (m *MockSubstrateExt) UpdateNodeContract(identity substrate.Identity, contract uint64, body, hash string) (uint64, error) {
 	m.ctrl.T.Helper()
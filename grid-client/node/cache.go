@@ -0,0 +1,166 @@
+package client
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is the default duration node metadata is cached for
+	DefaultCacheTTL = 5 * time.Minute
+	// DefaultCacheSize is the default maximum number of cached node metadata entries
+	DefaultCacheSize = 10000
+)
+
+// cacheKind is the kind of a cached node metadata
+type cacheKind string
+
+const (
+	twinCache         cacheKind = "twin"
+	publicConfigCache cacheKind = "public_config"
+	interfacesCache   cacheKind = "interfaces"
+	poolsCache        cacheKind = "pools"
+	featuresCache     cacheKind = "features"
+)
+
+// CacheStats are the counters of the node metadata cache
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
+
+type cacheKey struct {
+	nodeID uint32
+	kind   cacheKind
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	value   interface{}
+	expires time.Time
+}
+
+// nodeCache is a least recently used cache of node metadata with expiring entries
+type nodeCache struct {
+	ttl  time.Duration
+	size int
+
+	m       sync.Mutex
+	entries map[cacheKey]*list.Element
+	// order has the most recently used entries at its front
+	order *list.List
+
+	hits, misses, evictions atomic.Uint64
+}
+
+func newNodeCache(ttl time.Duration, size int) *nodeCache {
+	return &nodeCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[cacheKey]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *nodeCache) get(key cacheKey) (interface{}, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	element, ok := c.entries[key]
+	if ok && time.Now().After(element.Value.(*cacheEntry).expires) {
+		c.remove(element)
+		ok = false
+	}
+
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).value, true
+}
+
+func (c *nodeCache) set(key cacheKey, value interface{}) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expires: time.Now().Add(c.ttl)})
+
+	for c.size > 0 && c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// invalidate removes the given kinds of metadata of a node, or all of its metadata if no kinds are given
+func (c *nodeCache) invalidate(nodeID uint32, kinds ...cacheKind) {
+	if len(kinds) == 0 {
+		kinds = []cacheKind{twinCache, publicConfigCache, interfacesCache, poolsCache, featuresCache}
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for _, kind := range kinds {
+		if element, ok := c.entries[cacheKey{nodeID: nodeID, kind: kind}]; ok {
+			c.remove(element)
+		}
+	}
+}
+
+func (c *nodeCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+func (c *nodeCache) stats() CacheStats {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   c.order.Len(),
+	}
+}
+
+// cached returns the cached metadata of the node client or gets and caches it,
+// the node metadata is invalidated if getting it fails as the node could have changed.
+// cached values are shared between callers and must not be modified
+func cached[T any](n *NodeClient, kind cacheKind, get func() (T, error)) (T, error) {
+	if n.cache == nil {
+		return get()
+	}
+
+	key := cacheKey{nodeID: n.nodeID, kind: kind}
+	if value, ok := n.cache.get(key); ok {
+		return value.(T), nil
+	}
+
+	value, err := get()
+	if err != nil {
+		n.cache.invalidate(n.nodeID)
+		return value, err
+	}
+
+	n.cache.set(key, value)
+	return value, nil
+}
+
+// invalidate removes the given kinds of the node cached metadata after they are changed
+func (n *NodeClient) invalidate(kinds ...cacheKind) {
+	if n.cache != nil {
+		n.cache.invalidate(n.nodeID, kinds...)
+	}
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+// failingBus fails the calls of its commands while fail is set
+type failingBus struct {
+	testBus
+	fail bool
+}
+
+func (b *failingBus) Call(ctx context.Context, twin uint32, fn string, data interface{}, result interface{}) error {
+	if b.fail {
+		b.calls = append(b.calls, fn)
+		return errors.New("node is unreachable")
+	}
+
+	return b.testBus.Call(ctx, twin, fn, data, result)
+}
+
+func TestNodeCache(t *testing.T) {
+	t.Run("ttl and size", func(t *testing.T) {
+		cache := newNodeCache(50*time.Millisecond, 2)
+
+		cache.set(cacheKey{nodeID: 1, kind: twinCache}, uint32(10))
+		cache.set(cacheKey{nodeID: 2, kind: twinCache}, uint32(20))
+
+		value, ok := cache.get(cacheKey{nodeID: 1, kind: twinCache})
+		require.True(t, ok)
+		assert.Equal(t, uint32(10), value)
+
+		// node 2 is the least recently used
+		cache.set(cacheKey{nodeID: 3, kind: twinCache}, uint32(30))
+		_, ok = cache.get(cacheKey{nodeID: 2, kind: twinCache})
+		assert.False(t, ok)
+
+		time.Sleep(100 * time.Millisecond)
+		_, ok = cache.get(cacheKey{nodeID: 1, kind: twinCache})
+		assert.False(t, ok)
+
+		assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Evictions: 1, Entries: 1}, cache.stats())
+	})
+
+	t.Run("node metadata", func(t *testing.T) {
+		bus := &failingBus{testBus: testBus{responses: map[string]interface{}{
+			"zos.network.public_config_get": PublicConfig{Domain: "node.grid.tf"},
+			"zos.network.interfaces":        map[string][]string{"zos": {"185.206.122.1"}},
+			"zos.storage.pools":             []PoolMetrics{{Name: "pool", Size: 10}},
+			"zos.system.node_features_get":  []string{"zmachine-light"},
+		}}}
+
+		pool := NewNodeClientPool(bus, time.Minute, WithCacheSize(100))
+		sub := &twinsSubstrate{lookups: map[uint32]int{}}
+		ctx := context.Background()
+
+		cl, err := pool.GetNodeClient(sub, 1)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err = cl.GetNodeEndpoint(ctx)
+			require.NoError(t, err)
+			_, err = cl.Pools(ctx)
+			require.NoError(t, err)
+			features, err := cl.SystemGetNodeFeatures(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"zmachine-light"}, features)
+		}
+		assert.Equal(t, []string{"zos.network.public_config_get", "zos.network.interfaces", "zos.storage.pools", "zos.system.node_features_get"}, bus.calls)
+
+		// deploying changes the node pools
+		bus.calls = nil
+		require.NoError(t, cl.DeploymentDeploy(ctx, zosTypes.Deployment{}))
+		_, err = cl.Pools(ctx)
+		require.NoError(t, err)
+		_, err = cl.NetworkGetPublicConfig(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"zos.deployment.deploy", "zos.storage.pools"}, bus.calls)
+
+		// changing the node public config invalidates its network metadata
+		bus.calls = nil
+		require.NoError(t, cl.NetworkSetPublicConfig(ctx, PublicConfig{}))
+		_, err = cl.Pools(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"zos.network.public_config_set"}, bus.calls)
+
+		// a failure invalidates all the node metadata including its twin
+		bus.calls = nil
+		bus.fail = true
+		_, err = cl.NetworkGetPublicConfig(ctx)
+		require.Error(t, err)
+		_, err = cl.Pools(ctx)
+		require.Error(t, err)
+		assert.Equal(t, []string{"zos.network.public_config_get", "zos.storage.pools"}, bus.calls)
+
+		bus.fail = false
+		_, err = pool.GetNodeClient(sub, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 2, sub.lookups[1])
+
+		stats := pool.CacheStats()
+		assert.Equal(t, uint64(6), stats.Hits)
+		assert.Equal(t, uint64(9), stats.Misses)
+		assert.Equal(t, 1, stats.Entries)
+
+		pool.Invalidate(1)
+		assert.Zero(t, pool.CacheStats().Entries)
+	})
+}
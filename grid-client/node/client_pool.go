@@ -2,7 +2,6 @@
 package client
 
 import (
-	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,35 +14,87 @@ type NodeClientGetter interface {
 	GetNodeClient(sub subi.SubstrateExt, nodeID uint32) (*NodeClient, error)
 }
 
-// NodeClientPool is a pool for node clients and rmb
+// NodeClientPool is a pool for node clients and rmb,
+// it caches the node twins and metadata used by the node clients
 type NodeClientPool struct {
-	nodeClients sync.Map
-	rmb         rmb.Client
-	timeout     time.Duration
+	rmb     rmb.Client
+	timeout time.Duration
+	cache   *nodeCache
+}
+
+type poolCfg struct {
+	cacheTTL  time.Duration
+	cacheSize int
+}
+
+// PoolOpt is a node client pool option
+type PoolOpt func(*poolCfg)
+
+// WithCacheTTL sets the duration node twins and metadata are cached for, zero disables caching
+func WithCacheTTL(ttl time.Duration) PoolOpt {
+	return func(p *poolCfg) {
+		p.cacheTTL = ttl
+	}
+}
+
+// WithCacheSize sets the maximum number of cached node metadata entries, least recently used entries are evicted first
+func WithCacheSize(size int) PoolOpt {
+	return func(p *poolCfg) {
+		p.cacheSize = size
+	}
 }
 
 // NewNodeClientPool generates a new client pool
-func NewNodeClientPool(rmb rmb.Client, timeout time.Duration) *NodeClientPool {
-	return &NodeClientPool{
-		nodeClients: sync.Map{},
-		rmb:         rmb,
-		timeout:     timeout,
+func NewNodeClientPool(rmb rmb.Client, timeout time.Duration, opts ...PoolOpt) *NodeClientPool {
+	cfg := poolCfg{
+		cacheTTL:  DefaultCacheTTL,
+		cacheSize: DefaultCacheSize,
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	pool := &NodeClientPool{
+		rmb:     rmb,
+		timeout: timeout,
+	}
+
+	if cfg.cacheTTL > 0 {
+		pool.cache = newNodeCache(cfg.cacheTTL, cfg.cacheSize)
 	}
+
+	return pool
 }
 
 // GetNodeClient gets the node client according to node ID
 func (p *NodeClientPool) GetNodeClient(sub subi.SubstrateExt, nodeID uint32) (*NodeClient, error) {
-	cl, ok := p.nodeClients.Load(nodeID)
-	if ok {
-		return cl.(*NodeClient), nil
-	}
+	cl := NewNodeClient(0, p.rmb, p.timeout)
+	cl.nodeID = nodeID
+	cl.cache = p.cache
 
-	twinID, err := sub.GetNodeTwin(nodeID)
+	twinID, err := cached(cl, twinCache, func() (uint32, error) {
+		return sub.GetNodeTwin(nodeID)
+	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get node %d", nodeID)
 	}
 
-	cl, _ = p.nodeClients.LoadOrStore(nodeID, NewNodeClient(twinID, p.rmb, p.timeout))
+	cl.nodeTwin = twinID
+	return cl, nil
+}
+
+// Invalidate removes the cached twin and metadata of a node
+func (p *NodeClientPool) Invalidate(nodeID uint32) {
+	if p.cache != nil {
+		p.cache.invalidate(nodeID)
+	}
+}
+
+// CacheStats returns the counters of the pool cache
+func (p *NodeClientPool) CacheStats() CacheStats {
+	if p.cache == nil {
+		return CacheStats{}
+	}
 
-	return cl.(*NodeClient), nil
+	return p.cache.stats()
 }
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultFanOutWorkers is the default number of nodes called concurrently
+const DefaultFanOutWorkers = 20
+
+// FanOutOptions configures calling multiple nodes concurrently
+type FanOutOptions struct {
+	// Workers limits the nodes called concurrently, defaults to DefaultFanOutWorkers
+	Workers int
+	// Timeout limits the call of each node, the node clients timeout is used if zero
+	Timeout time.Duration
+}
+
+// ForEachNode runs fn concurrently with the clients of the given nodes and returns the errors of the failed nodes.
+// node clients are taken from the getter so the node twins cached by a pool are reused across calls
+func ForEachNode(
+	ctx context.Context,
+	nc NodeClientGetter,
+	sub subi.SubstrateExt,
+	nodeIDs []uint32,
+	opts FanOutOptions,
+	fn func(ctx context.Context, nodeID uint32, cl *NodeClient) error,
+) map[uint32]error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultFanOutWorkers
+	}
+
+	var mu sync.Mutex
+	errs := make(map[uint32]error)
+
+	var group errgroup.Group
+	group.SetLimit(workers)
+
+	visited := make(map[uint32]bool)
+	for _, nodeID := range nodeIDs {
+		if visited[nodeID] {
+			continue
+		}
+		visited[nodeID] = true
+
+		nodeID := nodeID
+		group.Go(func() error {
+			err := callNode(ctx, nc, sub, nodeID, opts.Timeout, fn)
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				errs[nodeID] = err
+			}
+			// errors are collected per node so a failing node doesn't cancel the others
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+	return errs
+}
+
+func callNode(
+	ctx context.Context,
+	nc NodeClientGetter,
+	sub subi.SubstrateExt,
+	nodeID uint32,
+	timeout time.Duration,
+	fn func(ctx context.Context, nodeID uint32, cl *NodeClient) error,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	cl, err := nc.GetNodeClient(sub, nodeID)
+	if err != nil {
+		return errors.Wrapf(err, "could not get node %d client", nodeID)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return fn(ctx, nodeID, cl)
+}
+
+// FanOut calls the rmb command with the same payload on the given nodes concurrently and returns
+// the responses of the succeeded nodes and the errors of the failed ones
+func FanOut[T any](
+	ctx context.Context,
+	nc NodeClientGetter,
+	sub subi.SubstrateExt,
+	nodeIDs []uint32,
+	cmd string,
+	payload interface{},
+	opts FanOutOptions,
+) (map[uint32]T, map[uint32]error) {
+	var mu sync.Mutex
+	results := make(map[uint32]T)
+
+	errs := ForEachNode(ctx, nc, sub, nodeIDs, opts, func(ctx context.Context, nodeID uint32, cl *NodeClient) error {
+		var result T
+		if err := cl.call(ctx, cmd, payload, &result); err != nil {
+			return errors.Wrapf(err, "failed to call %s on node %d", cmd, nodeID)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		results[nodeID] = result
+		return nil
+	})
+
+	return results, errs
+}
+
+// FanOut calls the rmb command with the same payload on the given nodes concurrently and returns
+// the raw responses of the succeeded nodes and the errors of the failed ones
+func (p *NodeClientPool) FanOut(
+	ctx context.Context,
+	sub subi.SubstrateExt,
+	nodeIDs []uint32,
+	cmd string,
+	payload interface{},
+	opts FanOutOptions,
+) (map[uint32]json.RawMessage, map[uint32]error) {
+	return FanOut[json.RawMessage](ctx, p, sub, nodeIDs, cmd, payload, opts)
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
+)
+
+// fanOutBus replies with the twin of the called node, twin 2 fails and twin 3 hangs until the call times out
+type fanOutBus struct {
+	running, maxRunning atomic.Int32
+}
+
+func (b *fanOutBus) Call(ctx context.Context, twin uint32, fn string, data interface{}, result interface{}) error {
+	running := b.running.Add(1)
+	defer b.running.Add(-1)
+	for {
+		max := b.maxRunning.Load()
+		if running <= max || b.maxRunning.CompareAndSwap(max, running) {
+			break
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	switch twin {
+	case 2:
+		return errors.New("node is down")
+	case 3:
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	response, err := json.Marshal(map[string]interface{}{"twin": twin, "cmd": fn, "payload": data})
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(response, result)
+}
+
+// twinsSubstrate uses node IDs as twin IDs and counts twin lookups, node 4 has no twin
+type twinsSubstrate struct {
+	subi.SubstrateExt
+
+	m       sync.Mutex
+	lookups map[uint32]int
+}
+
+func (s *twinsSubstrate) GetNodeTwin(nodeID uint32) (uint32, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.lookups[nodeID]++
+	if nodeID == 4 {
+		return 0, errors.New("node not found")
+	}
+
+	return nodeID, nil
+}
+
+func TestFanOut(t *testing.T) {
+	type response struct {
+		Twin    uint32            `json:"twin"`
+		Cmd     string            `json:"cmd"`
+		Payload map[string]string `json:"payload"`
+	}
+
+	bus := &fanOutBus{}
+	pool := NewNodeClientPool(bus, time.Minute)
+	sub := &twinsSubstrate{lookups: map[uint32]int{}}
+
+	nodes := []uint32{1, 2, 3, 4}
+	for i := uint32(5); i < 20; i++ {
+		nodes = append(nodes, i)
+	}
+	opts := FanOutOptions{Workers: 4, Timeout: 100 * time.Millisecond}
+
+	results, errs := FanOut[response](context.Background(), pool, sub, append(nodes, 1), "zos.system.version", map[string]string{"key": "value"}, opts)
+
+	require.Len(t, results, len(nodes)-3)
+	assert.Equal(t, response{Twin: 5, Cmd: "zos.system.version", Payload: map[string]string{"key": "value"}}, results[5])
+
+	require.Len(t, errs, 3)
+	assert.ErrorContains(t, errs[2], "node is down")
+	assert.ErrorIs(t, errs[3], context.DeadlineExceeded)
+	assert.ErrorContains(t, errs[4], "could not get node 4 client")
+	assert.LessOrEqual(t, bus.maxRunning.Load(), int32(opts.Workers))
+
+	t.Run("cached twins", func(t *testing.T) {
+		raw, errs := pool.FanOut(context.Background(), sub, []uint32{1, 5}, "zos.system.version", nil, opts)
+		require.Empty(t, errs)
+		assert.JSONEq(t, `{"twin": 1, "cmd": "zos.system.version", "payload": null}`, string(raw[1]))
+
+		_, errs = FanOut[response](context.Background(), pool, sub, nodes, "zos.system.version", nil, opts)
+		require.Len(t, errs, 3)
+
+		// failed lookups are retried
+		for _, nodeID := range nodes {
+			expected := 1
+			if nodeID == 4 {
+				expected = 2
+			}
+			assert.Equal(t, expected, sub.lookups[nodeID], fmt.Sprintf("node %d", nodeID))
+		}
+	})
+
+	t.Run("nodes up", func(t *testing.T) {
+		require.NoError(t, AreNodesUp(context.Background(), sub, []uint32{1, 5, 6}, pool))
+		assert.ErrorContains(t, AreNodesUp(context.Background(), sub, []uint32{1, 2, 4}, pool), "could not reach node 2")
+	})
+}
@@ -73,6 +73,7 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"math/rand"
 	"net"
 	"slices"
@@ -141,11 +142,86 @@ type Interface struct {
 	Mac string   `json:"mac"`
 }
 
+// ModuleStatus is the status of a zos module
+type ModuleStatus struct {
+	// Status is the zbus status of the module objects and workers
+	Status json.RawMessage `json:"status,omitempty"`
+	// Err is set if the module status could not be fetched
+	Err json.RawMessage `json:"error,omitempty"`
+}
+
+// Diagnostics is the health of the node and its zos modules
+type Diagnostics struct {
+	SystemStatusOk bool                    `json:"system_status_ok"`
+	ZosModules     map[string]ModuleStatus `json:"modules"`
+	Healthy        bool                    `json:"healthy"`
+}
+
+// Page selects a page of a list result. zos returns whole lists so pages are selected on the client,
+// a zero limit returns all the items after the offset
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// DeploymentFilter filters listed deployments, zero values are not filtered
+type DeploymentFilter struct {
+	// TwinID is the deployments owner, zos only lists the deployments of the calling twin
+	TwinID      uint32
+	ContractIDs []uint64
+	Page        Page
+}
+
+// NodeClientInterface is the zos API of a node
+type NodeClientInterface interface {
+	SystemGetNodeFeatures(ctx context.Context) ([]string, error)
+	SystemDMI(ctx context.Context) (dmi.DMI, error)
+	SystemHypervisor(ctx context.Context) (string, error)
+	SystemVersion(ctx context.Context) (Version, error)
+	SystemDiagnostics(ctx context.Context) (Diagnostics, error)
+	IsNodeUp(ctx context.Context) error
+
+	DeploymentDeploy(ctx context.Context, dl zosTypes.Deployment) error
+	DeploymentUpdate(ctx context.Context, dl zosTypes.Deployment) error
+	DeploymentGet(ctx context.Context, contractID uint64) (zosTypes.Deployment, error)
+	DeploymentDelete(ctx context.Context, contractID uint64) error
+	DeploymentList(ctx context.Context) ([]zosTypes.Deployment, error)
+	DeploymentListFiltered(ctx context.Context, filter DeploymentFilter) ([]zosTypes.Deployment, error)
+	DeploymentChanges(ctx context.Context, contractID uint64) ([]zosTypes.Workload, error)
+	DeploymentChangesPage(ctx context.Context, contractID uint64, page Page) ([]zosTypes.Workload, int, error)
+
+	Statistics(ctx context.Context) (gridtypes.Capacity, gridtypes.Capacity, error)
+	Pools(ctx context.Context) ([]PoolMetrics, error)
+	GPUs(ctx context.Context) ([]GPU, error)
+	GetPerfTestResults(ctx context.Context) ([]TaskResult, error)
+	GetPerfTestResult(ctx context.Context, testName string) (TaskResult, error)
+
+	NetworkListPrivateIPs(ctx context.Context, networkName string) ([]string, error)
+	NetworkListWGPorts(ctx context.Context) ([]uint16, error)
+	NetworkListInterfaces(ctx context.Context) (map[string][]net.IP, error)
+	NetworkListIPs(ctx context.Context) ([]string, error)
+	NetworkGetPublicConfig(ctx context.Context) (PublicConfig, error)
+	NetworkSetPublicConfig(ctx context.Context, cfg PublicConfig) error
+	HasPublicIPv6(ctx context.Context) (bool, error)
+	GetNodeFreeWGPort(ctx context.Context, nodeID uint32, usedPorts []uint16) (int, error)
+	GetNodeEndpoint(ctx context.Context) (net.IP, error)
+
+	NetworkListAllInterfaces(ctx context.Context) (map[string]Interface, error)
+	NetworkSetPublicExitDevice(ctx context.Context, iface string) error
+	NetworkGetPublicExitDevice(ctx context.Context) (ExitDevice, error)
+}
+
+var _ NodeClientInterface = (*NodeClient)(nil)
+
 // NodeClient struct
 type NodeClient struct {
 	nodeTwin uint32
 	bus      rmb.Client
 	timeout  time.Duration
+
+	// nodeID and cache are set for the clients of a node client pool
+	nodeID uint32
+	cache  *nodeCache
 }
 
 // rmbCmdArgs is a map of command line arguments
@@ -161,15 +237,25 @@ func NewNodeClient(nodeTwin uint32, bus rmb.Client, timeout time.Duration) *Node
 	}
 }
 
-// SystemGetNodeFeatures gets the supported nodes features.
-func (n *NodeClient) SystemGetNodeFeatures(ctx context.Context) (feat []string, err error) {
+// call sends a raw rmb command to the node
+func (n *NodeClient) call(ctx context.Context, cmd string, payload interface{}, result interface{}) error {
 	ctx, cancel := context.WithTimeout(ctx, n.timeout)
 	defer cancel()
 
-	const cmd = "zos.system.node_features_get"
+	return n.bus.Call(ctx, n.nodeTwin, cmd, payload, result)
+}
 
-	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &feat)
-	return
+// SystemGetNodeFeatures gets the supported nodes features.
+func (n *NodeClient) SystemGetNodeFeatures(ctx context.Context) ([]string, error) {
+	return cached(n, featuresCache, func() (feat []string, err error) {
+		ctx, cancel := context.WithTimeout(ctx, n.timeout)
+		defer cancel()
+
+		const cmd = "zos.system.node_features_get"
+
+		err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &feat)
+		return
+	})
 }
 
 // DeploymentDeploy sends the deployment to the node for processing.
@@ -177,6 +263,9 @@ func (n *NodeClient) DeploymentDeploy(ctx context.Context, dl zosTypes.Deploymen
 	ctx, cancel := context.WithTimeout(ctx, n.timeout)
 	defer cancel()
 
+	// the node storage changes after the call
+	defer n.invalidate(poolsCache)
+
 	const cmd = "zos.deployment.deploy"
 	return n.bus.Call(ctx, n.nodeTwin, cmd, dl, nil)
 }
@@ -187,6 +276,9 @@ func (n *NodeClient) DeploymentUpdate(ctx context.Context, dl zosTypes.Deploymen
 	ctx, cancel := context.WithTimeout(ctx, n.timeout)
 	defer cancel()
 
+	// the node storage changes after the call
+	defer n.invalidate(poolsCache)
+
 	const cmd = "zos.deployment.update"
 	return n.bus.Call(ctx, n.nodeTwin, cmd, dl, nil)
 }
@@ -214,6 +306,9 @@ func (n *NodeClient) DeploymentDelete(ctx context.Context, contractID uint64) er
 	ctx, cancel := context.WithTimeout(ctx, n.timeout)
 	defer cancel()
 
+	// the node storage changes after the call
+	defer n.invalidate(poolsCache)
+
 	const cmd = "zos.deployment.delete"
 	in := rmbCmdArgs{
 		"contract_id": contractID,
@@ -233,6 +328,29 @@ func (n *NodeClient) DeploymentList(ctx context.Context) (dls []zosTypes.Deploym
 	return
 }
 
+// DeploymentListFiltered gets the deployments of the twin matching the filter
+func (n *NodeClient) DeploymentListFiltered(ctx context.Context, filter DeploymentFilter) ([]zosTypes.Deployment, error) {
+	dls, err := n.DeploymentList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]zosTypes.Deployment, 0, len(dls))
+	for _, dl := range dls {
+		if filter.TwinID != 0 && dl.TwinID != filter.TwinID {
+			continue
+		}
+
+		if len(filter.ContractIDs) != 0 && !slices.Contains(filter.ContractIDs, dl.ContractID) {
+			continue
+		}
+
+		filtered = append(filtered, dl)
+	}
+
+	return paginate(filtered, filter.Page), nil
+}
+
 // Statistics returns some node statistics. Including total and available cpu, memory, storage, etc...
 func (n *NodeClient) Statistics(ctx context.Context) (total gridtypes.Capacity, used gridtypes.Capacity, err error) {
 	ctx, cancel := context.WithTimeout(ctx, n.timeout)
@@ -298,17 +416,19 @@ func (n *NodeClient) NetworkListWGPorts(ctx context.Context) ([]uint16, error) {
 
 // NetworkListInterfaces return a map of all interfaces and their ips
 func (n *NodeClient) NetworkListInterfaces(ctx context.Context) (map[string][]net.IP, error) {
-	ctx, cancel := context.WithTimeout(ctx, n.timeout)
-	defer cancel()
+	return cached(n, interfacesCache, func() (map[string][]net.IP, error) {
+		ctx, cancel := context.WithTimeout(ctx, n.timeout)
+		defer cancel()
 
-	const cmd = "zos.network.interfaces"
-	var result map[string][]net.IP
+		const cmd = "zos.network.interfaces"
+		var result map[string][]net.IP
 
-	if err := n.bus.Call(ctx, n.nodeTwin, cmd, nil, &result); err != nil {
-		return nil, err
-	}
+		if err := n.bus.Call(ctx, n.nodeTwin, cmd, nil, &result); err != nil {
+			return nil, err
+		}
 
-	return result, nil
+		return result, nil
+	})
 }
 
 // DeploymentChanges return changes of a deployment via contract ID
@@ -328,6 +448,16 @@ func (n *NodeClient) DeploymentChanges(ctx context.Context, contractID uint64) (
 	return changes, nil
 }
 
+// DeploymentChangesPage returns a page of the changes of a deployment with the total number of changes
+func (n *NodeClient) DeploymentChangesPage(ctx context.Context, contractID uint64, page Page) ([]zosTypes.Workload, int, error) {
+	changes, err := n.DeploymentChanges(ctx, contractID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return paginate(changes, page), len(changes), nil
+}
+
 // NetworkListIPs list taken public IPs on the node
 func (n *NodeClient) NetworkListIPs(ctx context.Context) ([]string, error) {
 	ctx, cancel := context.WithTimeout(ctx, n.timeout)
@@ -345,17 +475,16 @@ func (n *NodeClient) NetworkListIPs(ctx context.Context) ([]string, error) {
 
 // NetworkGetPublicConfig returns the current public node network configuration. A node with a
 // public config can be used as an access node for wireguard.
-func (n *NodeClient) NetworkGetPublicConfig(ctx context.Context) (cfg PublicConfig, err error) {
-	ctx, cancel := context.WithTimeout(ctx, n.timeout)
-	defer cancel()
+func (n *NodeClient) NetworkGetPublicConfig(ctx context.Context) (PublicConfig, error) {
+	return cached(n, publicConfigCache, func() (cfg PublicConfig, err error) {
+		ctx, cancel := context.WithTimeout(ctx, n.timeout)
+		defer cancel()
 
-	const cmd = "zos.network.public_config_get"
+		const cmd = "zos.network.public_config_get"
 
-	if err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &cfg); err != nil {
+		err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &cfg)
 		return
-	}
-
-	return
+	})
 }
 
 // NetworkSetPublicConfig sets the current public node network configuration. A node with a
@@ -364,6 +493,9 @@ func (n *NodeClient) NetworkSetPublicConfig(ctx context.Context, cfg PublicConfi
 	ctx, cancel := context.WithTimeout(ctx, n.timeout)
 	defer cancel()
 
+	// the node network changes after the call
+	defer n.invalidate(publicConfigCache, interfacesCache)
+
 	const cmd = "zos.network.public_config_set"
 	return n.bus.Call(ctx, n.nodeTwin, cmd, cfg, nil)
 }
@@ -416,6 +548,17 @@ func (n *NodeClient) SystemVersion(ctx context.Context) (ver Version, err error)
 	return
 }
 
+// SystemDiagnostics gets the health of the node and its zos modules
+func (n *NodeClient) SystemDiagnostics(ctx context.Context) (result Diagnostics, err error) {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	const cmd = "zos.system.diagnostics"
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &result)
+
+	return
+}
+
 // TaskResult holds the perf test result
 type TaskResult struct {
 	Name        string      `json:"name"`
@@ -460,13 +603,16 @@ func (n *NodeClient) IsNodeUp(ctx context.Context) error {
 
 // AreNodesUp checks if nodes are up
 func AreNodesUp(ctx context.Context, sub subi.SubstrateExt, nodes []uint32, nc NodeClientGetter) error {
-	for _, node := range nodes {
-		cl, err := nc.GetNodeClient(sub, node)
-		if err != nil {
-			return errors.Wrapf(err, "could not get node %d client", node)
-		}
+	errs := ForEachNode(ctx, nc, sub, nodes, FanOutOptions{}, func(ctx context.Context, nodeID uint32, cl *NodeClient) error {
 		if err := cl.IsNodeUp(ctx); err != nil {
-			return errors.Wrapf(err, "could not reach node %d", node)
+			return errors.Wrapf(err, "could not reach node %d", nodeID)
+		}
+		return nil
+	})
+
+	for _, node := range nodes {
+		if err, ok := errs[node]; ok {
+			return err
 		}
 	}
 	return nil
@@ -529,13 +675,15 @@ func (n *NodeClient) GetNodeEndpoint(ctx context.Context) (net.IP, error) {
 }
 
 // Pools returns statistics of separate pools
-func (n *NodeClient) Pools(ctx context.Context) (pools []PoolMetrics, err error) {
-	ctx, cancel := context.WithTimeout(ctx, n.timeout)
-	defer cancel()
+func (n *NodeClient) Pools(ctx context.Context) ([]PoolMetrics, error) {
+	return cached(n, poolsCache, func() (pools []PoolMetrics, err error) {
+		ctx, cancel := context.WithTimeout(ctx, n.timeout)
+		defer cancel()
 
-	const cmd = "zos.storage.pools"
-	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &pools)
-	return
+		const cmd = "zos.storage.pools"
+		err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &pools)
+		return
+	})
 }
 
 type GPU struct {
@@ -575,7 +723,7 @@ func (n *NodeClient) NetworkListAllInterfaces(ctx context.Context) (result map[s
 	ctx, cancel := context.WithTimeout(ctx, n.timeout)
 	defer cancel()
 
-	const cmd = "zos.network.admin.interfaces"
+	const cmd = "zos.admin.interfaces"
 	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &result)
 
 	return
@@ -587,7 +735,10 @@ func (n *NodeClient) NetworkSetPublicExitDevice(ctx context.Context, iface strin
 	ctx, cancel := context.WithTimeout(ctx, n.timeout)
 	defer cancel()
 
-	const cmd = "zos.network.admin.set_public_nic"
+	// the node network changes after the call
+	defer n.invalidate(publicConfigCache, interfacesCache)
+
+	const cmd = "zos.admin.set_public_nic"
 	return n.bus.Call(ctx, n.nodeTwin, cmd, iface, nil)
 }
 
@@ -596,11 +747,24 @@ func (n *NodeClient) NetworkGetPublicExitDevice(ctx context.Context) (exit ExitD
 	ctx, cancel := context.WithTimeout(ctx, n.timeout)
 	defer cancel()
 
-	const cmd = "zos.network.admin.get_public_nic"
+	const cmd = "zos.admin.get_public_nic"
 	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &exit)
 	return
 }
 
+func paginate[T any](items []T, page Page) []T {
+	if page.Offset >= len(items) {
+		return []T{}
+	}
+
+	items = items[max(page.Offset, 0):]
+	if page.Limit > 0 && page.Limit < len(items) {
+		items = items[:page.Limit]
+	}
+
+	return items
+}
+
 func contains[T comparable](elements []T, element T) bool {
 	for _, e := range elements {
 		if element == e {
@@ -0,0 +1,99 @@
+// Package client for node client
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+// testBus replies to rmb calls with the json encoded responses of their commands
+type testBus struct {
+	responses map[string]interface{}
+	calls     []string
+}
+
+func (b *testBus) Call(ctx context.Context, twin uint32, fn string, data interface{}, result interface{}) error {
+	b.calls = append(b.calls, fn)
+	if result == nil {
+		return nil
+	}
+
+	response, err := json.Marshal(b.responses[fn])
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(response, result)
+}
+
+func TestNodeClientCommands(t *testing.T) {
+	bus := &testBus{responses: map[string]interface{}{
+		"zos.deployment.list": []zosTypes.Deployment{
+			{TwinID: 1, ContractID: 1},
+			{TwinID: 1, ContractID: 2},
+			{TwinID: 2, ContractID: 3},
+			{TwinID: 1, ContractID: 4},
+		},
+		"zos.deployment.changes": []zosTypes.Workload{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+		"zos.system.diagnostics": map[string]interface{}{
+			"system_status_ok": true,
+			"healthy":          true,
+			"modules": map[string]interface{}{
+				"storage": map[string]interface{}{"status": map[string]interface{}{"objects": []string{}}},
+				"network": map[string]interface{}{"error": map[string]interface{}{}},
+			},
+		},
+	}}
+	cl := NewNodeClient(1, bus, time.Minute)
+
+	t.Run("deployment list filtered", func(t *testing.T) {
+		dls, err := cl.DeploymentListFiltered(context.Background(), DeploymentFilter{TwinID: 1, Page: Page{Offset: 1, Limit: 1}})
+		require.NoError(t, err)
+		require.Len(t, dls, 1)
+		assert.Equal(t, uint64(2), dls[0].ContractID)
+
+		dls, err = cl.DeploymentListFiltered(context.Background(), DeploymentFilter{ContractIDs: []uint64{3, 4}})
+		require.NoError(t, err)
+		require.Len(t, dls, 2)
+		assert.Equal(t, uint64(3), dls[0].ContractID)
+	})
+
+	t.Run("deployment changes page", func(t *testing.T) {
+		changes, total, err := cl.DeploymentChangesPage(context.Background(), 1, Page{Offset: 2, Limit: 5})
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "c", changes[0].Name)
+
+		changes, _, err = cl.DeploymentChangesPage(context.Background(), 1, Page{Offset: 3})
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("system diagnostics", func(t *testing.T) {
+		diagnostics, err := cl.SystemDiagnostics(context.Background())
+		require.NoError(t, err)
+		assert.True(t, diagnostics.Healthy)
+		assert.True(t, diagnostics.SystemStatusOk)
+		assert.Len(t, diagnostics.ZosModules, 2)
+		assert.NotEmpty(t, diagnostics.ZosModules["network"].Err)
+	})
+
+	t.Run("admin commands", func(t *testing.T) {
+		bus.calls = nil
+
+		_, err := cl.NetworkListAllInterfaces(context.Background())
+		require.NoError(t, err)
+		_, err = cl.NetworkGetPublicExitDevice(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, cl.NetworkSetPublicExitDevice(context.Background(), "zos"))
+
+		assert.Equal(t, []string{"zos.admin.interfaces", "zos.admin.get_public_nic", "zos.admin.set_public_nic"}, bus.calls)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+	"golang.org/x/exp/maps"
 )
 
 // NetworkState is a struct of networks names and their networks and mutex to protect the state
@@ -15,7 +16,7 @@ type NetworkState struct {
 
 // Network struct includes Subnets and node IPs
 type Network struct {
-	Subnets map[uint32]string
+	Subnets map[uint32]string `json:"subnets"`
 }
 
 // NewNetwork creates a new Network
@@ -50,6 +51,24 @@ func (nm *NetworkState) UpdateNetworkSubnets(networkName string, ipRange map[uin
 	nm.State[networkName] = network
 }
 
+func (nm *NetworkState) setNetwork(networkName string, network Network) {
+	nm.stateLock.Lock()
+	defer nm.stateLock.Unlock()
+	nm.State[networkName] = network
+}
+
+// copyState returns a copy of the networks state
+func (nm *NetworkState) copyState() map[string]Network {
+	nm.stateLock.Lock()
+	defer nm.stateLock.Unlock()
+
+	networks := make(map[string]Network, len(nm.State))
+	for name, network := range nm.State {
+		networks[name] = Network{Subnets: maps.Clone(network.Subnets)}
+	}
+	return networks
+}
+
 // DeleteNetwork deletes a Network using its name
 func (nm *NetworkState) DeleteNetwork(networkName string) {
 	nm.stateLock.Lock()
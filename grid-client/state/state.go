@@ -5,11 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"reflect"
 	"slices"
 	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
 	client "github.com/threefoldtech/tfgrid-sdk-go/grid-client/node"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/workloads"
@@ -31,6 +34,8 @@ type State struct {
 
 	NcPool    client.NodeClientGetter
 	Substrate subi.SubstrateExt
+
+	store Store
 }
 
 // ErrNotFound for state not found instances
@@ -46,18 +51,118 @@ func NewState(ncPool client.NodeClientGetter, substrate subi.SubstrateExt) *Stat
 	}
 }
 
+// SetStore sets the store used to persist the state and loads the previously saved state from it
+func (st *State) SetStore(store Store) error {
+	stored, err := store.Load()
+	if err != nil {
+		return errors.Wrap(err, "could not load state from store")
+	}
+
+	for nodeID, contractIDs := range stored.CurrentNodeDeployments {
+		for _, contractID := range contractIDs {
+			if !slices.Contains(st.CurrentNodeDeployments[nodeID], contractID) {
+				st.CurrentNodeDeployments[nodeID] = append(st.CurrentNodeDeployments[nodeID], contractID)
+			}
+		}
+	}
+
+	for name, network := range stored.Networks {
+		st.Networks.setNetwork(name, network)
+	}
+
+	st.store = store
+	return nil
+}
+
+// Save persists the state in its store if one is set
+func (st *State) Save() error {
+	if st.store == nil {
+		return nil
+	}
+
+	return st.store.Save(StoredState{
+		CurrentNodeDeployments: maps.Clone(st.CurrentNodeDeployments),
+		Networks:               st.Networks.copyState(),
+	})
+}
+
+// ReconcileContracts removes the contracts that are deleted or no longer exist on the chain from the state,
+// contracts in grace period are kept so they can still be recovered or canceled
+func (st *State) ReconcileContracts() error {
+	for nodeID, contractIDs := range st.CurrentNodeDeployments {
+		validContractIDs := ContractIDs{}
+		for _, contractID := range contractIDs {
+			contract, err := st.Substrate.GetContract(contractID)
+			if errors.Is(err, substrate.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return errors.Wrapf(err, "could not check contract %d on node %d", contractID, nodeID)
+			}
+
+			if !contract.IsDeleted() {
+				validContractIDs = append(validContractIDs, contractID)
+			}
+		}
+
+		if len(validContractIDs) == 0 {
+			delete(st.CurrentNodeDeployments, nodeID)
+			continue
+		}
+		st.CurrentNodeDeployments[nodeID] = validContractIDs
+	}
+
+	return st.Save()
+}
+
+// StoreContractIDs adds contract IDs to the node deployments of the state
 func (st *State) StoreContractIDs(nodeID uint32, contractIDs ...uint64) {
 	for _, contractID := range contractIDs {
 		if !slices.Contains(st.CurrentNodeDeployments[nodeID], contractID) {
 			st.CurrentNodeDeployments[nodeID] = append(st.CurrentNodeDeployments[nodeID], contractID)
 		}
 	}
+	st.persist()
 }
 
+// RemoveContractIDs removes contract IDs from the node deployments of the state
 func (st *State) RemoveContractIDs(nodeID uint32, contractIDs ...uint64) {
 	for _, contractID := range contractIDs {
 		st.CurrentNodeDeployments[nodeID] = workloads.Delete(st.CurrentNodeDeployments[nodeID], contractID)
 	}
+	st.persist()
+}
+
+// DeleteNetwork deletes a network from the networks state
+func (st *State) DeleteNetwork(networkName string) {
+	st.Networks.DeleteNetwork(networkName)
+	st.persist()
+}
+
+// persist saves the state, failures are only logged as the state can always be rebuilt from the grid
+func (st *State) persist() {
+	if err := st.Save(); err != nil {
+		log.Warn().Err(err).Msg("failed to persist state")
+	}
+}
+
+// LoadWorkloadFromGrid loads any registered workload type from grid
+func (st *State) LoadWorkloadFromGrid(ctx context.Context, nodeID uint32, name string, deploymentName string) (workloads.Workload, error) {
+	wl, dl, err := st.GetWorkloadInDeployment(ctx, nodeID, name, deploymentName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get workload from node %d within deployment %v", nodeID, dl)
+	}
+
+	workload, ok, err := workloads.NewWorkloadFromZosWorkload(&wl, &dl, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, errors.Errorf("workload %s has unsupported type %s", name, wl.Type)
+	}
+
+	return workload, nil
 }
 
 // LoadDiskFromGrid loads a disk from grid
@@ -199,10 +304,14 @@ func (st *State) LoadK8sFromGrid(ctx context.Context, nodeIDs []uint32, deployme
 				return workloads.K8sCluster{}, errors.Wrapf(err, "could not generate node data for %s", workload.Name)
 			}
 
-			isMaster, err := isMasterNode(*workload.Workload3())
+			isMaster, joins, err := isMasterNode(*workload.Workload3())
 			if err != nil {
 				return workloads.K8sCluster{}, err
 			}
+			if isMaster && joins {
+				cluster.Masters = append(cluster.Masters, node)
+				continue
+			}
 			if isMaster {
 				cluster.Master = &node
 				deploymentData, err := workloads.ParseDeploymentData(deployment.Metadata)
@@ -218,10 +327,14 @@ func (st *State) LoadK8sFromGrid(ctx context.Context, nodeIDs []uint32, deployme
 	if cluster.Master == nil {
 		return workloads.K8sCluster{}, errors.Wrapf(ErrNotFound, "failed to get master node for k8s cluster %s", deploymentName)
 	}
+	slices.SortFunc(cluster.Masters, func(a, b workloads.K8sNode) int {
+		return strings.Compare(a.Name, b.Name)
+	})
 	cluster.NodeDeploymentID = nodeDeploymentID
 	cluster.NetworkName = cluster.Master.NetworkName
 	cluster.SSHKey = cluster.Master.EnvVars["SSH_KEY"]
-	cluster.Token = cluster.Master.EnvVars["K3S_TOKEN"]
+	cluster.Token = workloads.K8sClusterToken(cluster.Master.EnvVars)
+	cluster.APIServer = k8sAPIServer(cluster.Workers)
 	cluster.Flist = cluster.Master.Flist
 	cluster.FlistChecksum = cluster.Master.FlistChecksum
 	cluster.Entrypoint = cluster.Master.Entrypoint
@@ -240,19 +353,35 @@ func (st *State) LoadK8sFromGrid(ctx context.Context, nodeIDs []uint32, deployme
 	return cluster, nil
 }
 
-func isMasterNode(workload gridtypes.Workload) (bool, error) {
+// isMasterNode returns if the k8s node workload is a master and if it joins the first master of the cluster
+func isMasterNode(workload gridtypes.Workload) (isMaster bool, joins bool, err error) {
 	dataI, err := workload.WorkloadData()
 	if err != nil {
-		return false, errors.Wrapf(err, "could not get workload %s data", workload.Name)
+		return false, false, errors.Wrapf(err, "could not get workload %s data", workload.Name)
 	}
 	data, ok := dataI.(*zos.ZMachine)
 	if !ok {
-		return false, errors.Wrapf(err, "could not create vm workload from data %v", dataI)
+		return false, false, errors.Wrapf(err, "could not create vm workload from data %v", dataI)
 	}
 	if data.Env["K3S_URL"] == "" {
-		return true, nil
+		return true, false, nil
+	}
+	if workloads.IsK8sJoiningMaster(data.Env) {
+		return true, true, nil
 	}
-	return false, nil
+	return false, true, nil
+}
+
+// k8sAPIServer returns the domain of the gateway the workers reach the api servers on, if any
+func k8sAPIServer(workers []workloads.K8sNode) string {
+	for _, worker := range workers {
+		u, err := url.Parse(worker.EnvVars["K3S_URL"])
+		if err == nil && u.Port() == "443" {
+			return u.Hostname()
+		}
+	}
+
+	return ""
 }
 
 func (st *State) computeK8sDeploymentResources(dl zosTypes.Deployment) (
@@ -490,6 +619,12 @@ func (st *State) LoadDeploymentFromGrid(ctx context.Context, nodeID uint32, name
 		return d, nil
 	}
 
+	if network, ok := d.Network(); ok {
+		st.Networks.UpdateNetworkSubnets(network.GetName(), network.GetNodesIPRange())
+		d.IPrange = network.GetNodesIPRange()[nodeID].String()
+		return d, nil
+	}
+
 	_, err = st.LoadNetworkFromGrid(ctx, d.NetworkName)
 	if err != nil {
 		_, err = st.LoadNetworkLightFromGrid(ctx, d.NetworkName)
@@ -561,6 +696,12 @@ func (st *State) AssignNodesIPRange(k *workloads.K8sCluster) (err error) {
 	if err != nil {
 		return errors.Wrap(err, "could not parse master node ip range")
 	}
+	for _, master := range k.Masters {
+		nodesIPRange[master.NodeID], err = gridtypes.ParseIPNet(network.GetNodeSubnet(master.NodeID))
+		if err != nil {
+			return errors.Wrapf(err, "could not parse master node (%d) ip range", master.NodeID)
+		}
+	}
 	for _, worker := range k.Workers {
 		nodesIPRange[worker.NodeID], err = gridtypes.ParseIPNet(network.GetNodeSubnet(worker.NodeID))
 		if err != nil {
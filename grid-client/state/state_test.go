@@ -364,6 +364,41 @@ func TestLoadK8sFromGrid(t *testing.T) {
 	})
 }
 
+func TestIsMasterNode(t *testing.T) {
+	node := func(name string, nodeID uint32) workloads.K8sNode {
+		return workloads.K8sNode{VM: &workloads.VM{Name: name, NodeID: nodeID, IP: "10.20.2.2"}}
+	}
+
+	master := node("master", 1)
+	cluster := workloads.K8sCluster{
+		Master:      &master,
+		Masters:     []workloads.K8sNode{node("master1", 2), node("master2", 3)},
+		NetworkName: "network",
+	}
+	worker := node("worker", 1)
+
+	for _, tc := range []struct {
+		wls      []gridtypes.Workload
+		isMaster bool
+		joins    bool
+	}{
+		{wls: master.MasterZosWorkload(&cluster), isMaster: true},
+		{wls: cluster.Masters[0].JoiningMasterZosWorkload(&cluster), isMaster: true, joins: true},
+		{wls: worker.WorkerZosWorkload(&cluster), joins: true},
+	} {
+		isMaster, joins, err := isMasterNode(tc.wls[1])
+		assert.NoError(t, err)
+		assert.Equal(t, tc.isMaster, isMaster, tc.wls[1].Name)
+		assert.Equal(t, tc.joins, joins, tc.wls[1].Name)
+	}
+
+	k8sWorker := func(url string) workloads.K8sNode {
+		return workloads.K8sNode{VM: &workloads.VM{EnvVars: map[string]string{"K3S_URL": url}}}
+	}
+	assert.Empty(t, k8sAPIServer([]workloads.K8sNode{k8sWorker("https://10.20.2.2:6443")}))
+	assert.Equal(t, "k8sapi.gent01.dev.grid.tf", k8sAPIServer([]workloads.K8sNode{k8sWorker("https://k8sapi.gent01.dev.grid.tf:443")}))
+}
+
 func TestLoadNetworkFromGrid(t *testing.T) {
 	ipRange, err := zosTypes.ParseIPNet("1.1.1.1/24")
 	assert.NoError(t, err)
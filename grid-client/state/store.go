@@ -0,0 +1,93 @@
+// Package state for grid state
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Store is an interface for persisting the grid state between runs
+type Store interface {
+	Load() (StoredState, error)
+	Save(stored StoredState) error
+}
+
+// StoredState is the part of the state that is persisted in a store
+type StoredState struct {
+	CurrentNodeDeployments map[uint32]ContractIDs `json:"current_node_deployments"`
+	Networks               map[string]Network     `json:"networks"`
+}
+
+// FileStore is a store that keeps the state as a json file on disk
+type FileStore struct {
+	path string
+	lock sync.Mutex
+}
+
+// NewFileStore generates a new file store given the path of the state file
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load loads the stored state from the state file, a missing file results in an empty state
+func (f *FileStore) Load() (StoredState, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	stored := StoredState{
+		CurrentNodeDeployments: make(map[uint32]ContractIDs),
+		Networks:               make(map[string]Network),
+	}
+
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return stored, nil
+	}
+	if err != nil {
+		return stored, errors.Wrapf(err, "could not read state file %s", f.path)
+	}
+
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return stored, errors.Wrapf(err, "could not parse state file %s", f.path)
+	}
+
+	if stored.CurrentNodeDeployments == nil {
+		stored.CurrentNodeDeployments = make(map[uint32]ContractIDs)
+	}
+	if stored.Networks == nil {
+		stored.Networks = make(map[string]Network)
+	}
+
+	return stored, nil
+}
+
+// Save writes the stored state to the state file
+func (f *FileStore) Save(stored StoredState) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not encode state")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return errors.Wrapf(err, "could not create state directory for %s", f.path)
+	}
+
+	// write to a temporary file first so a crash never leaves a half written state file
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return errors.Wrapf(err, "could not write state file %s", tmp)
+	}
+
+	if err := os.Rename(tmp, f.path); err != nil {
+		return errors.Wrapf(err, "could not replace state file %s", f.path)
+	}
+
+	return nil
+}
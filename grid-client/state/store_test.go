@@ -0,0 +1,85 @@
+// Package state for grid state
+package state
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/mocks"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+	store := NewFileStore(path)
+
+	t.Run("missing file", func(t *testing.T) {
+		stored, err := store.Load()
+		require.NoError(t, err)
+		assert.Empty(t, stored.CurrentNodeDeployments)
+		assert.Empty(t, stored.Networks)
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		stored := StoredState{
+			CurrentNodeDeployments: map[uint32]ContractIDs{1: {10, 11}},
+			Networks: map[string]Network{
+				"net": {Subnets: map[uint32]string{1: "10.1.2.0/24"}},
+			},
+		}
+		require.NoError(t, store.Save(stored))
+
+		loaded, err := store.Load()
+		require.NoError(t, err)
+		assert.Equal(t, stored, loaded)
+	})
+}
+
+func TestStateStore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sub := mocks.NewMockSubstrateExt(ctrl)
+	ncPool := mocks.NewMockNodeClientGetter(ctrl)
+
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state := NewState(ncPool, sub)
+	require.NoError(t, state.SetStore(NewFileStore(path)))
+
+	state.Networks.UpdateNetworkSubnets("net", nil)
+	state.StoreContractIDs(1, 10, 11)
+	state.StoreContractIDs(2, 20)
+
+	t.Run("load saved state", func(t *testing.T) {
+		newState := NewState(ncPool, sub)
+		require.NoError(t, newState.SetStore(NewFileStore(path)))
+
+		assert.Equal(t, state.CurrentNodeDeployments, newState.CurrentNodeDeployments)
+		assert.Contains(t, newState.Networks.State, "net")
+	})
+
+	t.Run("reconcile contracts", func(t *testing.T) {
+		contract := func(state substrate.ContractState) subi.Contract {
+			return subi.Contract{Contract: &substrate.Contract{State: state}}
+		}
+		// contract 10 is in grace period, 11 is deleted and 20 does not exist anymore
+		sub.EXPECT().GetContract(uint64(10)).Return(contract(substrate.ContractState{IsGracePeriod: true}), nil)
+		sub.EXPECT().GetContract(uint64(11)).Return(contract(substrate.ContractState{IsDeleted: true}), nil)
+		sub.EXPECT().GetContract(uint64(20)).Return(subi.Contract{}, substrate.ErrNotFound)
+
+		newState := NewState(ncPool, sub)
+		require.NoError(t, newState.SetStore(NewFileStore(path)))
+		require.NoError(t, newState.ReconcileContracts())
+
+		assert.Equal(t, map[uint32]ContractIDs{1: {10}}, newState.CurrentNodeDeployments)
+
+		stored, err := NewFileStore(path).Load()
+		require.NoError(t, err)
+		assert.Equal(t, newState.CurrentNodeDeployments, stored.CurrentNodeDeployments)
+	})
+}
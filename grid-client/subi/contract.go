@@ -18,6 +18,11 @@ func (c *Contract) IsCreated() bool {
 	return c.Contract.State.IsCreated
 }
 
+// IsGracePeriod checks if contract is in grace period
+func (c *Contract) IsGracePeriod() bool {
+	return c.Contract.State.IsGracePeriod
+}
+
 // TwinID returns contract's twin ID
 func (c *Contract) TwinID() uint32 {
 	return uint32(c.Contract.TwinID)
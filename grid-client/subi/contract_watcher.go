@@ -0,0 +1,375 @@
+// Package subi for substrate client
+package subi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+)
+
+const (
+	watcherEventsBuffer = 100
+	watcherMinBackoff   = time.Second
+	watcherMaxBackoff   = time.Minute
+)
+
+// ContractEventType is the type of a contract lifecycle event
+type ContractEventType string
+
+const (
+	// ContractEventCreated is emitted when a contract of the twin is created
+	ContractEventCreated ContractEventType = "contract_created"
+	// ContractEventUpdated is emitted when a watched contract is updated
+	ContractEventUpdated ContractEventType = "contract_updated"
+	// ContractEventCanceled is emitted when a watched contract is canceled, including cancellations by other clients
+	ContractEventCanceled ContractEventType = "contract_canceled"
+	// ContractEventGracePeriodStarted is emitted when a watched contract enters grace period
+	ContractEventGracePeriodStarted ContractEventType = "grace_period_started"
+	// ContractEventGracePeriodEnded is emitted when a watched contract gets out of grace period
+	ContractEventGracePeriodEnded ContractEventType = "grace_period_ended"
+	// ContractEventGracePeriodElapsed is emitted when the grace period of a watched contract elapses and it gets deleted
+	ContractEventGracePeriodElapsed ContractEventType = "grace_period_elapsed"
+	// ContractEventBilled is emitted when a watched contract is billed
+	ContractEventBilled ContractEventType = "contract_billed"
+	// ContractEventPaymentOverdrawn is emitted when the twin balance can not cover a watched contract bill
+	ContractEventPaymentOverdrawn ContractEventType = "payment_overdrawn"
+	// ContractEventNodePoweredDown is emitted when the farmerbot powers down the node of a watched contract,
+	// node outages are not reported as they are not recorded on chain
+	ContractEventNodePoweredDown ContractEventType = "node_powered_down"
+	// ContractEventNodePoweredUp is emitted when the farmerbot powers up the node of a watched contract
+	ContractEventNodePoweredUp ContractEventType = "node_powered_up"
+)
+
+// ContractEvent is a lifecycle event of a twin contract
+type ContractEvent struct {
+	Type  ContractEventType `json:"type"`
+	Block uint32            `json:"block"`
+	// ContractID is not set for node events as they affect all the contracts on the node
+	ContractID uint64 `json:"contract_id,omitempty"`
+	// NodeID is set for node and rent contracts and for node events
+	NodeID uint32 `json:"node_id,omitempty"`
+
+	// Amount is the billed amount of billing events or the overdraft of overdrawn payments in TFT units
+	Amount uint64 `json:"amount,omitempty"`
+}
+
+// EventsSource provides the finalized blocks and their events to the contract watcher
+type EventsSource interface {
+	// SubscribeBlocks sends the numbers of new finalized blocks, the blocks channel is closed
+	// when the subscription fails with the failure sent on the errors channel or when ctx is done
+	SubscribeBlocks(ctx context.Context) (<-chan uint32, <-chan error, error)
+	// BlockEvents returns the events of a block
+	BlockEvents(block uint32) (*substrate.EventRecords, error)
+}
+
+// managerEventsSource subscribes to the chain of a substrate manager, reconnecting on each subscription
+type managerEventsSource struct {
+	manager substrate.Manager
+
+	m   sync.Mutex
+	sub *substrate.Substrate
+}
+
+// NewEventsSource returns an events source of the finalized blocks of the manager chain
+func NewEventsSource(manager substrate.Manager) EventsSource {
+	return &managerEventsSource{manager: manager}
+}
+
+// SubscribeBlocks opens a new connection and subscribes to its finalized heads
+func (s *managerEventsSource) SubscribeBlocks(ctx context.Context) (<-chan uint32, <-chan error, error) {
+	sub, err := s.manager.Substrate()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to connect to substrate")
+	}
+
+	cl, _, err := sub.GetClient()
+	if err != nil {
+		sub.Close()
+		return nil, nil, err
+	}
+
+	subscription, err := cl.RPC.Chain.SubscribeFinalizedHeads()
+	if err != nil {
+		sub.Close()
+		return nil, nil, errors.Wrap(err, "failed to subscribe to finalized heads")
+	}
+
+	s.m.Lock()
+	if s.sub != nil {
+		s.sub.Close()
+	}
+	s.sub = sub
+	s.m.Unlock()
+
+	blocks := make(chan uint32)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(blocks)
+		defer subscription.Unsubscribe()
+
+		for {
+			select {
+			case head, ok := <-subscription.Chan():
+				if !ok {
+					errs <- errors.New("finalized heads subscription is closed")
+					return
+				}
+
+				select {
+				case blocks <- uint32(head.Number):
+				case <-ctx.Done():
+					return
+				}
+			case err := <-subscription.Err():
+				errs <- err
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return blocks, errs, nil
+}
+
+// BlockEvents returns the events of a block using the last subscription connection
+func (s *managerEventsSource) BlockEvents(block uint32) (*substrate.EventRecords, error) {
+	s.m.Lock()
+	sub := s.sub
+	s.m.Unlock()
+
+	if sub == nil {
+		return nil, errors.New("events source is not subscribed")
+	}
+
+	return sub.GetEventsForBlock(block)
+}
+
+// Close closes the last subscription connection
+func (s *managerEventsSource) Close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.sub != nil {
+		s.sub.Close()
+		s.sub = nil
+	}
+}
+
+// ContractWatcher watches the chain events of a twin contracts and reconnects on failures,
+// blocks missed while disconnected are processed after reconnecting
+type ContractWatcher struct {
+	source EventsSource
+	twinID uint32
+
+	m sync.Mutex
+	// contracts are the watched contracts with their nodes, name contracts have no node
+	contracts map[uint64]uint32
+}
+
+// NewContractWatcher creates a watcher of the twin contracts, contracts created after the watcher
+// starts are watched automatically while existing ones are added with Watch
+func NewContractWatcher(source EventsSource, twinID uint32) *ContractWatcher {
+	return &ContractWatcher{
+		source:    source,
+		twinID:    twinID,
+		contracts: map[uint64]uint32{},
+	}
+}
+
+// Watch adds an existing contract of the twin to the watched contracts, nodeID is 0 for name contracts
+func (w *ContractWatcher) Watch(contractID uint64, nodeID uint32) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.contracts[contractID] = nodeID
+}
+
+// Start watches the contracts until ctx is done, the returned channel is closed after ctx is done
+func (w *ContractWatcher) Start(ctx context.Context) <-chan ContractEvent {
+	events := make(chan ContractEvent, watcherEventsBuffer)
+
+	go func() {
+		defer close(events)
+		// sources holding a connection are closed once ctx is done
+		if closer, ok := w.source.(interface{ Close() }); ok {
+			defer closer.Close()
+		}
+
+		var last uint32
+		backoff := watcherMinBackoff
+		for {
+			processed := last
+			err := w.watch(ctx, &last, events)
+			if ctx.Err() != nil {
+				return
+			}
+
+			// the backoff is only increased while reconnecting fails without processing any block
+			if last != processed {
+				backoff = watcherMinBackoff
+			}
+
+			log.Warn().Err(err).Dur("retry_in", backoff).Msg("contract watcher disconnected")
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+
+			backoff = min(backoff*2, watcherMaxBackoff)
+		}
+	}()
+
+	return events
+}
+
+// watch processes the blocks of one subscription, last is the last processed block
+func (w *ContractWatcher) watch(ctx context.Context, last *uint32, events chan<- ContractEvent) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	blocks, errs, err := w.source.SubscribeBlocks(ctx)
+	if err != nil {
+		return err
+	}
+
+	for block := range blocks {
+		from := block
+		if *last != 0 {
+			// skip blocks already processed before reconnecting
+			if block <= *last {
+				continue
+			}
+			from = *last + 1
+		}
+
+		for number := from; number <= block; number++ {
+			records, err := w.source.BlockEvents(number)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get events of block %d", number)
+			}
+
+			for _, event := range w.contractEvents(number, records) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			*last = number
+		}
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+// contractEvents returns the events of the watched contracts in a block and updates the watched contracts
+func (w *ContractWatcher) contractEvents(block uint32, records *substrate.EventRecords) []ContractEvent {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	var events []ContractEvent
+	add := func(eventType ContractEventType, contractID uint64, amount uint64) {
+		nodeID, ok := w.contracts[contractID]
+		if !ok {
+			return
+		}
+
+		events = append(events, ContractEvent{Type: eventType, Block: block, ContractID: contractID, NodeID: nodeID, Amount: amount})
+	}
+
+	for _, e := range records.SmartContractModule_ContractCreated {
+		if uint32(e.Contract.TwinID) != w.twinID {
+			continue
+		}
+
+		w.contracts[uint64(e.Contract.ContractID)] = contractNode(e.Contract)
+		add(ContractEventCreated, uint64(e.Contract.ContractID), 0)
+	}
+
+	for _, e := range records.SmartContractModule_ContractUpdated {
+		add(ContractEventUpdated, uint64(e.Contract.ContractID), 0)
+	}
+
+	var canceled []uint64
+	for _, e := range records.SmartContractModule_NodeContractCanceled {
+		canceled = append(canceled, uint64(e.ContractID))
+	}
+	for _, e := range records.SmartContractModule_NameContractCanceled {
+		canceled = append(canceled, uint64(e.ContractID))
+	}
+	for _, e := range records.SmartContractModule_RentContractCanceled {
+		canceled = append(canceled, uint64(e.ContractID))
+	}
+
+	for _, e := range records.SmartContractModule_ContractGracePeriodStarted {
+		add(ContractEventGracePeriodStarted, uint64(e.ContractID), 0)
+	}
+
+	for _, e := range records.SmartContractModule_ContractGracePeriodEnded {
+		add(ContractEventGracePeriodEnded, uint64(e.ContractID), 0)
+	}
+
+	for _, e := range records.SmartContractModule_ContractGracePeriodElapsed {
+		add(ContractEventGracePeriodElapsed, uint64(e.ContractID), 0)
+	}
+
+	for _, e := range records.SmartContractModule_ContractBilled {
+		add(ContractEventBilled, uint64(e.ContractBill.ContractID), e.ContractBill.AmountBilled.Uint64())
+	}
+
+	for _, e := range records.SmartContractModule_ContractPaymentOverdrawn {
+		add(ContractEventPaymentOverdrawn, uint64(e.ContractID), e.Overdraft.Uint64())
+	}
+
+	// canceled contracts are removed after their last bills are reported
+	for _, contractID := range canceled {
+		add(ContractEventCanceled, contractID, 0)
+		delete(w.contracts, contractID)
+	}
+
+	nodes := map[uint32]bool{}
+	for _, nodeID := range w.contracts {
+		if nodeID != 0 {
+			nodes[nodeID] = true
+		}
+	}
+
+	for _, e := range records.TfgridModule_PowerStateChanged {
+		nodeID := uint32(e.Node)
+		if !nodes[nodeID] {
+			continue
+		}
+
+		eventType := ContractEventNodePoweredUp
+		if e.PowerState.IsDown {
+			eventType = ContractEventNodePoweredDown
+		}
+		events = append(events, ContractEvent{Type: eventType, Block: block, NodeID: nodeID})
+	}
+
+	return events
+}
+
+// contractNode returns the node of node and rent contracts
+func contractNode(contract substrate.Contract) uint32 {
+	switch {
+	case contract.ContractType.IsNodeContract:
+		return uint32(contract.ContractType.NodeContract.Node)
+	case contract.ContractType.IsRentContract:
+		return uint32(contract.ContractType.RentContract.Node)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,150 @@
+package subi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+)
+
+// testEventsSource sends its subscriptions blocks then fails them
+type testEventsSource struct {
+	m             sync.Mutex
+	subscriptions [][]uint32
+	events        map[uint32]*substrate.EventRecords
+	closed        bool
+}
+
+func (s *testEventsSource) SubscribeBlocks(ctx context.Context) (<-chan uint32, <-chan error, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if len(s.subscriptions) == 0 {
+		return nil, nil, errors.New("no more subscriptions")
+	}
+
+	numbers := s.subscriptions[0]
+	s.subscriptions = s.subscriptions[1:]
+
+	blocks := make(chan uint32)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(blocks)
+		for _, block := range numbers {
+			select {
+			case blocks <- block:
+			case <-ctx.Done():
+				return
+			}
+		}
+		errs <- errors.New("connection closed")
+	}()
+
+	return blocks, errs, nil
+}
+
+func (s *testEventsSource) BlockEvents(block uint32) (*substrate.EventRecords, error) {
+	if events, ok := s.events[block]; ok {
+		return events, nil
+	}
+
+	return &substrate.EventRecords{}, nil
+}
+
+func (s *testEventsSource) Close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.closed = true
+}
+
+func TestContractWatcher(t *testing.T) {
+	nodeContract := func(id uint64, twin, node uint32) substrate.Contract {
+		return substrate.Contract{
+			ContractID: types.U64(id),
+			TwinID:     types.U32(twin),
+			ContractType: substrate.ContractType{
+				IsNodeContract: true,
+				NodeContract:   substrate.NodeContract{Node: types.U32(node)},
+			},
+		}
+	}
+
+	source := &testEventsSource{
+		// the second subscription starts after missing block 4
+		subscriptions: [][]uint32{{1, 2, 3}, {3, 5}},
+		events: map[uint32]*substrate.EventRecords{
+			1: {
+				SmartContractModule_ContractCreated: []substrate.ContractCreated{
+					{Contract: nodeContract(10, 1, 5)},
+					{Contract: nodeContract(11, 2, 5)},
+				},
+			},
+			2: {
+				SmartContractModule_ContractBilled: []substrate.ContractBilled{
+					{ContractBill: substrate.ContractBill{ContractID: 10, AmountBilled: types.NewU128(*big.NewInt(100))}},
+					{ContractBill: substrate.ContractBill{ContractID: 11, AmountBilled: types.NewU128(*big.NewInt(100))}},
+				},
+				SmartContractModule_ContractGracePeriodStarted: []substrate.ContractGracePeriodStarted{{ContractID: 20}},
+			},
+			3: {
+				TfgridModule_PowerStateChanged: []substrate.PowerStateChanged{
+					{Node: 5, PowerState: substrate.PowerState{IsDown: true}},
+					{Node: 6, PowerState: substrate.PowerState{IsDown: true}},
+				},
+			},
+			4: {
+				SmartContractModule_ContractGracePeriodEnded: []substrate.ContractGracePeriodEnded{{ContractID: 20}},
+			},
+			5: {
+				SmartContractModule_NodeContractCanceled: []substrate.NodeContractCanceled{{ContractID: 10}},
+				SmartContractModule_NameContractCanceled: []substrate.NameContractCanceled{{ContractID: 20}},
+			},
+		},
+	}
+
+	watcher := NewContractWatcher(source, 1)
+	watcher.Watch(20, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := watcher.Start(ctx)
+
+	expected := []ContractEvent{
+		{Type: ContractEventCreated, Block: 1, ContractID: 10, NodeID: 5},
+		{Type: ContractEventGracePeriodStarted, Block: 2, ContractID: 20},
+		{Type: ContractEventBilled, Block: 2, ContractID: 10, NodeID: 5, Amount: 100},
+		{Type: ContractEventNodePoweredDown, Block: 3, NodeID: 5},
+		{Type: ContractEventGracePeriodEnded, Block: 4, ContractID: 20},
+		{Type: ContractEventCanceled, Block: 5, ContractID: 10, NodeID: 5},
+		{Type: ContractEventCanceled, Block: 5, ContractID: 20},
+	}
+
+	var got []ContractEvent
+	for len(got) < len(expected) {
+		select {
+		case event := <-events:
+			got = append(got, event)
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "timeout waiting for events", "got %v", got)
+		}
+	}
+	assert.Equal(t, expected, got)
+
+	cancel()
+	for range events {
+	}
+
+	// the source is closed once the watcher stops
+	source.m.Lock()
+	defer source.m.Unlock()
+	assert.True(t, source.closed)
+}
@@ -57,6 +57,8 @@ type SubstrateExt interface {
 	GetBalance(identity substrate.Identity) (balance substrate.Balance, err error)
 	GetTFTPrice() (balance types.U32, err error)
 	GetPricingPolicy(policyID uint32) (pricingPolicy substrate.PricingPolicy, err error)
+	GetCurrentHeight() (uint32, error)
+	Transfer(identity substrate.Identity, amount uint64, destination substrate.AccountID) error
 	GetTwinPK(twinID uint32) ([]byte, error)
 	GetContractIDByNameRegistration(name string) (uint64, error)
 	BatchCreateContract(identity substrate.Identity, contractsData []substrate.BatchCreateContractData) ([]uint64, *int, error)
@@ -99,6 +101,14 @@ func (s *SubstrateImpl) GetPricingPolicy(policyID uint32) (pricingPolicy substra
 	return pricingPolicy, normalizeNotFoundErrors(err)
 }
 
+// Transfer transfers an amount in TFT units from the identity account to the destination account
+func (s *SubstrateImpl) Transfer(identity substrate.Identity, amount uint64, destination substrate.AccountID) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return normalizeNotFoundErrors(s.Substrate.Transfer(identity, amount, destination))
+}
+
 // GetNodeTwin returns the twin ID for a node ID
 func (s *SubstrateImpl) GetNodeTwin(nodeID uint32) (uint32, error) {
 	node, err := s.Substrate.GetNode(nodeID)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"regexp"
+	"slices"
 	"sort"
 
 	"github.com/pkg/errors"
@@ -29,6 +30,9 @@ type Deployment struct {
 	VmsLight []VMLight
 	QSFS     []QSFS
 	Volumes  []Volume
+	// Workloads are the deployment workloads with no typed list, e.g. networks or registered workload types.
+	// AllWorkloads returns them with the typed ones
+	Workloads []Workload
 
 	// computed
 	NodeDeploymentID map[uint32]uint64
@@ -36,10 +40,6 @@ type Deployment struct {
 	IPrange          string
 }
 
-// TODO: NewDeployment should take a list of Workload interface instead of defining
-// each type as an argument. That way it is cleaner and also allow for networks to
-// be created with VMs in the same deployment.
-
 // NewDeployment generates a new deployment
 func NewDeployment(name string, nodeID uint32,
 	solutionType string, solutionProvider *uint64,
@@ -66,6 +66,58 @@ func NewDeployment(name string, nodeID uint32,
 	}
 }
 
+// NewDeploymentWithWorkloads generates a new deployment holding the given workloads
+func NewDeploymentWithWorkloads(name string, nodeID uint32,
+	solutionType string, solutionProvider *uint64,
+	networkName string,
+	workloads ...Workload,
+) Deployment {
+	d := Deployment{
+		Name:             name,
+		NodeID:           nodeID,
+		SolutionType:     solutionType,
+		SolutionProvider: solutionProvider,
+		NetworkName:      networkName,
+	}
+
+	for _, workload := range workloads {
+		d.AddWorkload(workload)
+	}
+
+	return d
+}
+
+// AddWorkload adds a workload to the deployment
+func (d *Deployment) AddWorkload(workload Workload) {
+	switch w := workload.(type) {
+	case *Disk:
+		d.Disks = append(d.Disks, *w)
+	case *Volume:
+		d.Volumes = append(d.Volumes, *w)
+	case *ZDB:
+		d.Zdbs = append(d.Zdbs, *w)
+	case *QSFS:
+		d.QSFS = append(d.QSFS, *w)
+	case *VM:
+		d.Vms = append(d.Vms, *w)
+	case *VMLight:
+		d.VmsLight = append(d.VmsLight, *w)
+	default:
+		d.Workloads = append(d.Workloads, workload)
+	}
+}
+
+// Network returns the network deployed within the deployment if any
+func (d *Deployment) Network() (Network, bool) {
+	for _, workload := range d.Workloads {
+		if network, ok := workload.(Network); ok && network.GetName() == d.NetworkName {
+			return network, true
+		}
+	}
+
+	return nil, false
+}
+
 // Validate validates a deployment
 func (d *Deployment) Validate() error {
 	if err := validateName(d.Name); err != nil {
@@ -120,6 +172,12 @@ func (d *Deployment) Validate() error {
 		}
 	}
 
+	for idx, workload := range d.Workloads {
+		if err := workload.Validate(); err != nil {
+			return errors.Wrapf(err, "workload %d is invalid", idx)
+		}
+	}
+
 	return nil
 }
 
@@ -146,9 +204,9 @@ func (d *Deployment) GenerateMetadata() (string, error) {
 		d.SolutionType = fmt.Sprintf("vm/%s", d.Name)
 	}
 
-	typ := "vm"
-	if len(d.VmsLight) > 0 {
-		typ = "vm-light"
+	typ, err := d.deploymentType()
+	if err != nil {
+		return "", err
 	}
 
 	deploymentData := DeploymentData{
@@ -166,6 +224,33 @@ func (d *Deployment) GenerateMetadata() (string, error) {
 	return string(deploymentDataBytes), nil
 }
 
+// deploymentType returns the type of the deployment from the metadata of its workloads,
+// deployments with vms are typed after them and the others after their first workload
+func (d *Deployment) deploymentType() (string, error) {
+	var types []string
+	for _, workload := range d.AllWorkloads() {
+		metadata, err := workload.GenerateMetadata()
+		if err != nil {
+			return "", err
+		}
+
+		data, err := ParseDeploymentData(metadata)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to parse workload metadata")
+		}
+		types = append(types, data.Type)
+	}
+
+	switch {
+	case slices.Contains(types, "vm-light"):
+		return "vm-light", nil
+	case len(types) == 0 || slices.Contains(types, "vm"):
+		return "vm", nil
+	default:
+		return types[0], nil
+	}
+}
+
 // Nullify resets deployment
 func (d *Deployment) Nullify() {
 	d.Vms = nil
@@ -174,6 +259,7 @@ func (d *Deployment) Nullify() {
 	d.Disks = nil
 	d.Zdbs = nil
 	d.Volumes = nil
+	d.Workloads = nil
 	d.ContractID = 0
 }
 
@@ -236,37 +322,51 @@ func (d *Deployment) Match(disks []Disk, QSFS []QSFS, zdbs []ZDB, vms []VM, vmsL
 	}
 }
 
-// ZosDeployment generates a new zos deployment from a deployment
-func (d *Deployment) ZosDeployment(twin uint32) (zos.Deployment, error) {
-	wls := []zos.Workload{}
-
-	for _, d := range d.Disks {
-		wls = append(wls, d.ZosWorkload())
+// AllWorkloads returns the workloads of the deployment, the typed ones first. the order is the one
+// deployments were always generated with, as changing it changes the hash of deployed deployments
+func (d *Deployment) AllWorkloads() []Workload {
+	var workloads []Workload
+	for i := range d.Disks {
+		workloads = append(workloads, &d.Disks[i])
 	}
-
-	for _, z := range d.Zdbs {
-		wls = append(wls, z.ZosWorkload())
+	for i := range d.Volumes {
+		workloads = append(workloads, &d.Volumes[i])
 	}
-
-	for _, v := range d.Vms {
-		vmWls := v.ZosWorkload()
-		wls = append(wls, vmWls...)
+	for i := range d.Zdbs {
+		workloads = append(workloads, &d.Zdbs[i])
 	}
-
-	for _, v := range d.VmsLight {
-		vmLightWls := v.ZosWorkload()
-		wls = append(wls, vmLightWls...)
+	for i := range d.Vms {
+		workloads = append(workloads, &d.Vms[i])
 	}
+	for i := range d.VmsLight {
+		workloads = append(workloads, &d.VmsLight[i])
+	}
+	for i := range d.QSFS {
+		workloads = append(workloads, &d.QSFS[i])
+	}
+
+	return append(workloads, d.Workloads...)
+}
 
-	for _, q := range d.QSFS {
-		qWls, err := q.ZosWorkload()
+// ZosWorkloads generates the zos workloads of all the deployment workloads
+func (d *Deployment) ZosWorkloads() ([]zos.Workload, error) {
+	wls := []zos.Workload{}
+	for _, workload := range d.AllWorkloads() {
+		zosWls, err := workload.ZosWorkloads()
 		if err != nil {
-			return zos.Deployment{}, err
+			return nil, err
 		}
-		wls = append(wls, qWls)
+		wls = append(wls, zosWls...)
 	}
-	for _, v := range d.Volumes {
-		wls = append(wls, v.ZosWorkload())
+
+	return wls, nil
+}
+
+// ZosDeployment generates a new zos deployment from a deployment
+func (d *Deployment) ZosDeployment(twin uint32) (zos.Deployment, error) {
+	wls, err := d.ZosWorkloads()
+	if err != nil {
+		return zos.Deployment{}, err
 	}
 
 	return NewGridDeployment(twin, d.ContractID, wls), nil
@@ -329,70 +429,37 @@ func NewDeploymentFromZosDeployment(d zos.Deployment, nodeID uint32) (Deployment
 		return Deployment{}, errors.Wrap(err, "failed to parse deployment data")
 	}
 
-	vms := make([]VM, 0)
-	vmsLight := make([]VMLight, 0)
-	disks := make([]Disk, 0)
-	qs := make([]QSFS, 0)
-	zdbs := make([]ZDB, 0)
-	volumes := make([]Volume, 0)
+	deployment := Deployment{
+		Name:             deploymentData.Name,
+		SolutionType:     deploymentData.ProjectName,
+		Vms:              make([]VM, 0),
+		VmsLight:         make([]VMLight, 0),
+		Disks:            make([]Disk, 0),
+		QSFS:             make([]QSFS, 0),
+		Zdbs:             make([]ZDB, 0),
+		Volumes:          make([]Volume, 0),
+		NodeID:           nodeID,
+		NodeDeploymentID: map[uint32]uint64{nodeID: d.ContractID},
+		ContractID:       d.ContractID,
+	}
 
-	var networkName string
 	for _, workload := range d.Workloads {
-		switch workload.Type {
-		case zos.ZMachineType:
-			vm, err := NewVMFromWorkload(&workload, &d, nodeID)
-			if err != nil {
-				return Deployment{}, errors.Wrap(err, "failed to get vm workload")
-			}
-			vms = append(vms, vm)
-			networkName = vm.NetworkName
-		case zos.ZMachineLightType:
-			vmLight, err := NewVMLightFromWorkload(&workload, &d, nodeID)
-			if err != nil {
-				return Deployment{}, errors.Wrap(err, "failed to get vm-light workload")
-			}
-			vmsLight = append(vmsLight, vmLight)
-			networkName = vmLight.NetworkName
-		case zos.ZDBType:
-			zdb, err := NewZDBFromWorkload(&workload)
-			if err != nil {
-				return Deployment{}, errors.Wrap(err, "failed to get zdb workload")
-			}
-			zdbs = append(zdbs, zdb)
-		case zos.QuantumSafeFSType:
-			q, err := NewQSFSFromWorkload(&workload)
-			if err != nil {
-				return Deployment{}, errors.Wrap(err, "failed to get qsfs workload")
-			}
-			qs = append(qs, q)
-		case zos.ZMountType:
-			disk, err := NewDiskFromWorkload(&workload)
-			if err != nil {
-				return Deployment{}, errors.Wrap(err, "failed to get disk workload")
-			}
-			disks = append(disks, disk)
-		case zos.VolumeType:
+		w, ok, err := NewWorkloadFromZosWorkload(&workload, &d, nodeID)
+		if err != nil {
+			return Deployment{}, err
+		}
+		if !ok {
+			continue
+		}
 
-			volume, err := NewVolumeFromWorkload(&workload)
-			if err != nil {
-				return Deployment{}, errors.Wrap(err, "failed to get volume workload")
-			}
-			volumes = append(volumes, volume)
+		switch vm := w.(type) {
+		case *VM:
+			deployment.NetworkName = vm.NetworkName
+		case *VMLight:
+			deployment.NetworkName = vm.NetworkName
 		}
+		deployment.AddWorkload(w)
 	}
 
-	return Deployment{
-		Name:             deploymentData.Name,
-		SolutionType:     deploymentData.ProjectName,
-		NetworkName:      networkName,
-		Vms:              vms,
-		VmsLight:         vmsLight,
-		Disks:            disks,
-		QSFS:             qs,
-		Zdbs:             zdbs,
-		Volumes:          volumes,
-		NodeID:           nodeID,
-		NodeDeploymentID: map[uint32]uint64{nodeID: d.ContractID},
-		ContractID:       d.ContractID,
-	}, nil
+	return deployment, nil
 }
@@ -30,11 +30,11 @@ func TestNewDeployment(t *testing.T) {
 		zosDeployment, err = deployment.ZosDeployment(1)
 		assert.NoError(t, err)
 
-		workloads := []zos.Workload{DiskWorkload.ZosWorkload(), ZDBWorkload.ZosWorkload()}
+		workloads := []zos.Workload{DiskWorkload.ZosWorkload(), volumeWorkload.ZosWorkload(), ZDBWorkload.ZosWorkload()}
 		workloads = append(workloads, VMWorkload.ZosWorkload()...)
 		QSFS, err := QSFSWorkload.ZosWorkload()
 		assert.NoError(t, err)
-		workloads = append(workloads, QSFS, volumeWorkload.ZosWorkload())
+		workloads = append(workloads, QSFS)
 
 		newZosDeployment := NewGridDeployment(1, 0, workloads)
 		assert.Equal(t, newZosDeployment, zosDeployment)
@@ -47,7 +47,7 @@ func TestNewDeployment(t *testing.T) {
 
 		res, err := json.Marshal(zos.ZMachineResult{})
 		assert.NoError(t, err)
-		zosDeployment.Workloads[3].Result.Data = res
+		zosDeployment.Workloads[4].Result.Data = res
 
 		usedIPs, err := GetUsedIPs(zosDeployment, 1)
 		assert.NoError(t, err)
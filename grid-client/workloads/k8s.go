@@ -7,10 +7,14 @@ import (
 	"net"
 	"reflect"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/subi"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-proxy/pkg/types"
 	"github.com/threefoldtech/zos/pkg/gridtypes"
 	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
 )
@@ -18,6 +22,17 @@ import (
 // old: https://hub.grid.tf/tf-official-apps/threefoldtech-k3s-latest.flist
 var K8sFlist = "https://hub.grid.tf/tf-official-apps/threefolddev-k3s-v1.31.0.flist"
 
+const (
+	// K8sClusterInitEnv is set on the first master of a highly available cluster
+	K8sClusterInitEnv = "K3S_CLUSTER_INIT"
+	// K8sServerTokenPrefix prefixes the K3S_TOKEN of the masters joining the first master of a highly
+	// available cluster, k3s starts a server joining K3S_URL for a server token and an agent otherwise
+	K8sServerTokenPrefix = "K10::server:"
+	// K8sMinHAMasters is the minimum number of masters of a highly available cluster,
+	// its etcd keeps a quorum after losing one of them
+	K8sMinHAMasters = 3
+)
+
 // K8sNode kubernetes data
 type K8sNode struct {
 	*VM
@@ -26,7 +41,9 @@ type K8sNode struct {
 
 // K8sCluster struct for k8s cluster
 type K8sCluster struct {
-	Master      *K8sNode
+	Master *K8sNode
+	// Masters are the masters joining the first master in a highly available cluster, each on a different node
+	Masters     []K8sNode `json:"masters"`
 	Workers     []K8sNode
 	Token       string
 	NetworkName string
@@ -38,12 +55,30 @@ type K8sCluster struct {
 	// optional
 	SolutionType string
 	SSHKey       string
+	// APIServer is the domain of the gateway fronting the api servers of the masters (see APIGateway),
+	// workers and the kubeconfig reach the api servers through it instead of the first master if set
+	APIServer string `json:"api_server"`
+	// NodePools are not stored on the grid and are not loaded with the cluster, only their workers are
+	NodePools []K8sNodePool `json:"node_pools"`
 
 	// computed
 	NodesIPRange     map[uint32]gridtypes.IPNet
 	NodeDeploymentID map[uint32]uint64
 }
 
+// K8sNodePool is a named pool of k8s workers with the same resources, its workers are named after
+// the pool followed by their index so the pool of a deployed worker is known from its name
+type K8sNodePool struct {
+	Name    string `json:"name"`
+	MinSize int    `json:"min_size"`
+	// MaxSize is the maximum number of workers of the pool, zero means no limit
+	MaxSize int `json:"max_size"`
+	// Worker is the spec of the pool workers, its name, node and ips are set for each worker
+	Worker K8sNode `json:"worker"`
+	// NodeFilter filters the nodes of new workers, the workers resources are added to it if not set
+	NodeFilter types.NodeFilter `json:"node_filter"`
+}
+
 // NewK8sNodeFromWorkload generates a new k8s from a workload
 func NewK8sNodeFromWorkload(wl gridtypes.Workload, nodeID uint32, diskSize uint64, computedIP string, computedIP6 string) (K8sNode, error) {
 	var k K8sNode
@@ -104,12 +139,17 @@ func NewK8sNodeFromWorkload(wl gridtypes.Workload, nodeID uint32, diskSize uint6
 
 // MasterZosWorkload generates a k8s master workload from a k8s node
 func (k *K8sNode) MasterZosWorkload(cluster *K8sCluster) (K8sWorkloads []gridtypes.Workload) {
-	return k.zosWorkload(cluster, false)
+	return k.zosWorkload(cluster, false, false)
+}
+
+// JoiningMasterZosWorkload generates the workload of a master joining the first master of a highly available cluster
+func (k *K8sNode) JoiningMasterZosWorkload(cluster *K8sCluster) (K8sWorkloads []gridtypes.Workload) {
+	return k.zosWorkload(cluster, true, true)
 }
 
 // WorkerZosWorkload generates a k8s worker workload from a k8s node
 func (k *K8sNode) WorkerZosWorkload(cluster *K8sCluster) (K8sWorkloads []gridtypes.Workload) {
-	return k.zosWorkload(cluster, true)
+	return k.zosWorkload(cluster, true, false)
 }
 
 // ZosWorkloads generates k8s workloads from a k8s cluster
@@ -117,6 +157,10 @@ func (k *K8sCluster) ZosWorkloads() ([]gridtypes.Workload, error) {
 	k8sWorkloads := []gridtypes.Workload{}
 	k8sWorkloads = append(k8sWorkloads, k.Master.MasterZosWorkload(k)...)
 
+	for _, master := range k.Masters {
+		k8sWorkloads = append(k8sWorkloads, master.JoiningMasterZosWorkload(k)...)
+	}
+
 	for _, worker := range k.Workers {
 		k8sWorkloads = append(k8sWorkloads, worker.WorkerZosWorkload(k)...)
 	}
@@ -153,6 +197,21 @@ func (k *K8sCluster) Validate() error {
 	names := make(map[string]bool)
 	names[k.Master.Name] = true
 
+	if err := k.ValidateMasters(); err != nil {
+		return err
+	}
+
+	for _, m := range k.Masters {
+		if _, ok := names[m.Name]; ok {
+			return errors.Errorf("k8s workers and master must have unique names: %s occurred more than once", m.Name)
+		}
+		names[m.Name] = true
+
+		if err := m.Validate(); err != nil {
+			return errors.Wrap(err, "master is invalid")
+		}
+	}
+
 	for _, w := range k.Workers {
 		if _, ok := names[w.Name]; ok {
 			return errors.Errorf("k8s workers and master must have unique names: %s occurred more than once", w.Name)
@@ -168,6 +227,18 @@ func (k *K8sCluster) Validate() error {
 		return errors.Wrap(err, "master name is invalid")
 	}
 
+	pools := make(map[string]bool)
+	for _, pool := range k.NodePools {
+		if pools[pool.Name] {
+			return errors.Errorf("k8s node pools must have unique names: %s occurred more than once", pool.Name)
+		}
+		pools[pool.Name] = true
+
+		if err := pool.Validate(); err != nil {
+			return errors.Wrapf(err, "node pool %s is invalid", pool.Name)
+		}
+	}
+
 	if err := k.ValidateToken(); err != nil {
 		return err
 	}
@@ -181,6 +252,182 @@ func (k *K8sCluster) Validate() error {
 	return nil
 }
 
+// APIGateway returns a name gateway on the given node passing the tls traffic of the name to the api
+// servers of the cluster masters. the masters public ipv4 are used if all of them have one, otherwise their
+// network ips are used and the gateway node must be in the cluster network. the gateway fqdn should be set
+// as the cluster APIServer once deployed so workers and the kubeconfig use it
+func (k *K8sCluster) APIGateway(name string, nodeID uint32) (GatewayNameProxy, error) {
+	masters := append([]K8sNode{*k.Master}, k.Masters...)
+
+	public := true
+	for _, m := range masters {
+		if m.ComputedIP == "" {
+			public = false
+		}
+	}
+
+	gw := GatewayNameProxy{
+		NodeID:         nodeID,
+		Name:           name,
+		TLSPassthrough: true,
+		Description:    fmt.Sprintf("kubernetes %s api server", k.Master.Name),
+		SolutionType:   k.SolutionType,
+	}
+	if !public {
+		gw.Network = k.NetworkName
+	}
+
+	for _, m := range masters {
+		ip := net.ParseIP(m.IP)
+		if public {
+			ip, _, _ = net.ParseCIDR(m.ComputedIP)
+		}
+
+		if ip == nil {
+			return GatewayNameProxy{}, errors.Errorf("master %s has no ip, the cluster should be deployed first", m.Name)
+		}
+
+		gw.Backends = append(gw.Backends, zos.Backend(net.JoinHostPort(ip.String(), "6443")))
+	}
+
+	return gw, nil
+}
+
+// apiServerURL returns the url workers reach the api servers on
+func (k *K8sCluster) apiServerURL() string {
+	if k.APIServer != "" {
+		return fmt.Sprintf("https://%s", net.JoinHostPort(k.APIServer, "443"))
+	}
+
+	return fmt.Sprintf("https://%s", net.JoinHostPort(k.Master.IP, "6443"))
+}
+
+// IsK8sJoiningMaster returns if the env vars of a k8s node are the ones of a master joining the first master
+func IsK8sJoiningMaster(envVars map[string]string) bool {
+	return envVars["K3S_URL"] != "" && strings.HasPrefix(envVars["K3S_TOKEN"], K8sServerTokenPrefix)
+}
+
+// K8sClusterToken returns the cluster token from the K3S_TOKEN of a k8s node
+func K8sClusterToken(envVars map[string]string) string {
+	return strings.TrimPrefix(envVars["K3S_TOKEN"], K8sServerTokenPrefix)
+}
+
+// NodePool returns the node pool of the cluster with the given name
+func (k *K8sCluster) NodePool(name string) (*K8sNodePool, error) {
+	for i := range k.NodePools {
+		if k.NodePools[i].Name == name {
+			return &k.NodePools[i], nil
+		}
+	}
+
+	return nil, errors.Errorf("could not find node pool %s in cluster %s", name, k.Master.Name)
+}
+
+// PoolWorkers returns the workers of the node pool with the given name ordered by their index
+func (k *K8sCluster) PoolWorkers(name string) []K8sNode {
+	pool := K8sNodePool{Name: name}
+
+	var workers []K8sNode
+	for _, w := range k.Workers {
+		if _, ok := pool.WorkerIndex(w.Name); ok {
+			workers = append(workers, w)
+		}
+	}
+
+	slices.SortFunc(workers, func(a, b K8sNode) int {
+		i, _ := pool.WorkerIndex(a.Name)
+		j, _ := pool.WorkerIndex(b.Name)
+		return i - j
+	})
+
+	return workers
+}
+
+// Validate validates the node pool sizes and its workers spec
+func (p *K8sNodePool) Validate() error {
+	if err := validateName(p.Name); err != nil {
+		return errors.Wrap(err, "node pool name is invalid")
+	}
+
+	// workers of a pool named after another pool followed by digits could belong to both
+	if strings.TrimRight(p.Name, "0123456789") != p.Name {
+		return errors.New("node pool name can't end with a digit")
+	}
+
+	if p.MinSize < 0 {
+		return errors.New("node pool min size can't be negative")
+	}
+
+	if p.MaxSize != 0 && p.MaxSize < p.MinSize {
+		return errors.New("node pool max size can't be less than its min size")
+	}
+
+	if p.Worker.VM == nil {
+		return errors.New("node pool worker spec is missing")
+	}
+
+	if p.Worker.CPU < 1 || p.Worker.CPU > 32 {
+		return errors.New("CPUs must be more than or equal to 1 and less than or equal to 32")
+	}
+
+	if gridtypes.Unit(p.Worker.MemoryMB) < 250 {
+		return errors.New("memory capacity can't be less that 250 MB")
+	}
+
+	return nil
+}
+
+// ValidateSize validates that the pool can have the given number of workers
+func (p *K8sNodePool) ValidateSize(size int) error {
+	if size < p.MinSize {
+		return errors.Errorf("node pool %s can't have less than %d workers", p.Name, p.MinSize)
+	}
+
+	if p.MaxSize != 0 && size > p.MaxSize {
+		return errors.Errorf("node pool %s can't have more than %d workers", p.Name, p.MaxSize)
+	}
+
+	return nil
+}
+
+// WorkerName returns the name of the pool worker with the given index
+func (p *K8sNodePool) WorkerName(idx int) string {
+	return fmt.Sprintf("%s%d", p.Name, idx)
+}
+
+// WorkerIndex returns the index of a worker of the pool from its name
+func (p *K8sNodePool) WorkerIndex(name string) (int, bool) {
+	suffix, ok := strings.CutPrefix(name, p.Name)
+	if !ok || suffix == "" || strings.Trim(suffix, "0123456789") != "" {
+		return 0, false
+	}
+
+	idx, err := strconv.Atoi(suffix)
+	if err != nil {
+		return 0, false
+	}
+
+	return idx, true
+}
+
+// ValidateMasters validates that a highly available cluster has enough masters on different nodes
+func (k *K8sCluster) ValidateMasters() error {
+	if len(k.Masters) != 0 && len(k.Masters)+1 < K8sMinHAMasters {
+		return errors.Errorf("highly available k8s clusters must have at least %d masters", K8sMinHAMasters)
+	}
+
+	// a node failure must not take down more than one master
+	masterNodes := map[uint32]bool{k.Master.NodeID: true}
+	for _, m := range k.Masters {
+		if masterNodes[m.NodeID] {
+			return errors.Errorf("k8s masters must be on different nodes: node %d has more than one master", m.NodeID)
+		}
+		masterNodes[m.NodeID] = true
+	}
+
+	return nil
+}
+
 // ValidateToken validate cluster token
 func (k *K8sCluster) ValidateToken() error {
 	if len(k.Token) < 6 {
@@ -198,12 +445,18 @@ func (k *K8sCluster) ValidateToken() error {
 	return nil
 }
 
-// ValidateIPranges validates NodesIPRange of master && workers of k8s cluster
+// ValidateIPranges validates NodesIPRange of masters && workers of k8s cluster
 func (k *K8sCluster) ValidateIPranges() error {
 	if _, ok := k.NodesIPRange[k.Master.NodeID]; !ok {
 		return errors.Errorf("the master node %d does not exist in the network's ip ranges", k.Master.NodeID)
 	}
 
+	for _, m := range k.Masters {
+		if _, ok := k.NodesIPRange[m.NodeID]; !ok {
+			return errors.Errorf("the node with id %d in master %s does not exist in the network's ip ranges", m.NodeID, m.Name)
+		}
+	}
+
 	for _, w := range k.Workers {
 		if _, ok := k.NodesIPRange[w.NodeID]; !ok {
 			return errors.Errorf("the node with id %d in worker %s does not exist in the network's ip ranges", w.NodeID, w.Name)
@@ -237,7 +490,7 @@ func (k *K8sCluster) InvalidateBrokenAttributes(sub subi.SubstrateExt) error {
 	return nil
 }
 
-func (k *K8sNode) zosWorkload(cluster *K8sCluster, isWorker bool) (K8sWorkloads []gridtypes.Workload) {
+func (k *K8sNode) zosWorkload(cluster *K8sCluster, joins, isMaster bool) (K8sWorkloads []gridtypes.Workload) {
 	diskName := fmt.Sprintf("%sdisk", k.Name)
 	diskWorkload := gridtypes.Workload{
 		Name:        gridtypes.Name(diskName),
@@ -263,9 +516,17 @@ func (k *K8sNode) zosWorkload(cluster *K8sCluster, isWorker bool) (K8sWorkloads
 		"K3S_NODE_NAME":     k.Name,
 		"K3S_URL":           "",
 	}
-	if isWorker {
-		// K3S_URL marks where to find the master node
+	switch {
+	case joins && isMaster:
+		// joining masters join the embedded etcd of the first master as k3s servers
 		envVars["K3S_URL"] = fmt.Sprintf("https://%s:6443", cluster.Master.IP)
+		envVars["K3S_TOKEN"] = K8sServerTokenPrefix + cluster.Token
+	case joins:
+		// K3S_URL marks where to find the api server
+		envVars["K3S_URL"] = cluster.apiServerURL()
+	case len(cluster.Masters) != 0:
+		// the first master of a highly available cluster initializes its embedded etcd
+		envVars[K8sClusterInitEnv] = "true"
 	}
 	var myceliumIP *zos.MyceliumIP
 	if len(k.MyceliumIPSeed) != 0 {
@@ -87,3 +87,158 @@ func TestK8sNodeData(t *testing.T) {
 		assert.Equal(t, len(k8sWorkloads), 2)
 	})
 }
+
+func TestK8sNodePool(t *testing.T) {
+	pool := K8sNodePool{
+		Name:    "gpu",
+		MinSize: 1,
+		MaxSize: 3,
+		Worker:  K8sNode{VM: &VM{CPU: 2, MemoryMB: 1024}, DiskSizeGB: 5},
+	}
+
+	t.Run("validate", func(t *testing.T) {
+		assert.NoError(t, pool.Validate())
+		assert.NoError(t, pool.ValidateSize(3))
+		assert.Error(t, pool.ValidateSize(0))
+		assert.Error(t, pool.ValidateSize(4))
+
+		invalid := pool
+		invalid.Name = "gpu1"
+		assert.Error(t, invalid.Validate())
+
+		invalid = pool
+		invalid.MaxSize = 0
+		assert.NoError(t, invalid.Validate())
+		assert.NoError(t, invalid.ValidateSize(100))
+
+		invalid.MinSize = 4
+		invalid.MaxSize = 3
+		assert.Error(t, invalid.Validate())
+
+		invalid = pool
+		invalid.Worker = K8sNode{VM: &VM{CPU: 2, MemoryMB: 100}}
+		assert.Error(t, invalid.Validate())
+	})
+
+	t.Run("workers", func(t *testing.T) {
+		assert.Equal(t, "gpu10", pool.WorkerName(10))
+
+		idx, ok := pool.WorkerIndex("gpu10")
+		assert.True(t, ok)
+		assert.Equal(t, 10, idx)
+
+		for _, name := range []string{"gpu", "gpux1", "worker0", "gp1"} {
+			_, ok := pool.WorkerIndex(name)
+			assert.False(t, ok, name)
+		}
+
+		cluster := K8sCluster{
+			Master:    &K8sNode{VM: &VM{Name: "master"}},
+			NodePools: []K8sNodePool{pool},
+			Workers: []K8sNode{
+				{VM: &VM{Name: "gpu10"}},
+				{VM: &VM{Name: "worker0"}},
+				{VM: &VM{Name: "gpu2"}},
+			},
+		}
+
+		var names []string
+		for _, w := range cluster.PoolWorkers("gpu") {
+			names = append(names, w.Name)
+		}
+		assert.Equal(t, []string{"gpu2", "gpu10"}, names)
+
+		_, err := cluster.NodePool("gpu")
+		assert.NoError(t, err)
+		_, err = cluster.NodePool("cpu")
+		assert.Error(t, err)
+	})
+}
+
+func TestK8sHACluster(t *testing.T) {
+	master := func(name string, nodeID uint32, ip, computedIP string) K8sNode {
+		return K8sNode{VM: &VM{Name: name, NodeID: nodeID, IP: ip, ComputedIP: computedIP}}
+	}
+
+	cluster := K8sCluster{
+		Master:      &[]K8sNode{master("master", 1, "10.20.2.2", "185.206.122.31/24")}[0],
+		Masters:     []K8sNode{master("master1", 2, "10.20.3.2", "185.206.122.32/24"), master("master2", 3, "10.20.4.2", "")},
+		Workers:     []K8sNode{{VM: &VM{Name: "worker0", NodeID: 1}}},
+		Token:       "testToken",
+		NetworkName: "network",
+	}
+
+	t.Run("validate masters", func(t *testing.T) {
+		assert.NoError(t, cluster.ValidateMasters())
+
+		invalid := cluster
+		invalid.Masters = cluster.Masters[:1]
+		assert.Error(t, invalid.ValidateMasters())
+
+		invalid.Masters = []K8sNode{cluster.Masters[0], master("master2", 2, "", "")}
+		assert.Error(t, invalid.ValidateMasters())
+
+		invalid.Masters = nil
+		assert.NoError(t, invalid.ValidateMasters())
+	})
+
+	t.Run("workloads", func(t *testing.T) {
+		k8sWorkloads, err := cluster.ZosWorkloads()
+		assert.NoError(t, err)
+		assert.Len(t, k8sWorkloads, 8)
+
+		env := func(wl gridtypes.Workload) map[string]string {
+			data, err := wl.WorkloadData()
+			assert.NoError(t, err)
+			return data.(*zos.ZMachine).Env
+		}
+
+		masterEnv := env(k8sWorkloads[1])
+		assert.Empty(t, masterEnv["K3S_URL"])
+		assert.Equal(t, "testToken", masterEnv["K3S_TOKEN"])
+		assert.Equal(t, "true", masterEnv[K8sClusterInitEnv])
+		assert.False(t, IsK8sJoiningMaster(masterEnv))
+
+		joiningEnv := env(k8sWorkloads[3])
+		assert.Equal(t, "https://10.20.2.2:6443", joiningEnv["K3S_URL"])
+		assert.Equal(t, "K10::server:testToken", joiningEnv["K3S_TOKEN"])
+		assert.NotContains(t, joiningEnv, K8sClusterInitEnv)
+		assert.True(t, IsK8sJoiningMaster(joiningEnv))
+		assert.Equal(t, "testToken", K8sClusterToken(joiningEnv))
+
+		workerEnv := env(k8sWorkloads[7])
+		assert.Equal(t, "https://10.20.2.2:6443", workerEnv["K3S_URL"])
+		assert.Equal(t, "testToken", workerEnv["K3S_TOKEN"])
+		assert.NotContains(t, workerEnv, K8sClusterInitEnv)
+		assert.False(t, IsK8sJoiningMaster(workerEnv))
+	})
+
+	t.Run("workers behind the api gateway", func(t *testing.T) {
+		gateway := cluster
+		gateway.APIServer = "k8sapi.gent01.dev.grid.tf"
+
+		k8sWorkloads, err := gateway.ZosWorkloads()
+		assert.NoError(t, err)
+
+		for i, url := range map[int]string{3: "https://10.20.2.2:6443", 7: "https://k8sapi.gent01.dev.grid.tf:443"} {
+			data, err := k8sWorkloads[i].WorkloadData()
+			assert.NoError(t, err)
+			assert.Equal(t, url, data.(*zos.ZMachine).Env["K3S_URL"])
+		}
+	})
+
+	t.Run("api gateway", func(t *testing.T) {
+		gw, err := cluster.APIGateway("k8sapi", 10)
+		assert.NoError(t, err)
+		assert.True(t, gw.TLSPassthrough)
+		assert.Equal(t, "network", gw.Network)
+		assert.Equal(t, []zos.Backend{"10.20.2.2:6443", "10.20.3.2:6443", "10.20.4.2:6443"}, gw.Backends)
+
+		public := cluster
+		public.Masters = []K8sNode{master("master1", 2, "10.20.3.2", "185.206.122.32/24"), master("master2", 3, "10.20.4.2", "185.206.122.33/24")}
+		gw, err = public.APIGateway("k8sapi", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, gw.Network)
+		assert.Equal(t, []zos.Backend{"185.206.122.31:6443", "185.206.122.32:6443", "185.206.122.33:6443"}, gw.Backends)
+	})
+}
@@ -0,0 +1,158 @@
+package workloads
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	k8sKubeconfigPath = "/etc/rancher/k3s/k3s.yaml"
+	// k8sAPIServerName is in the api server certificate whatever address the api server is reached on
+	k8sAPIServerName = "kubernetes"
+	k8sSSHTimeout    = 10 * time.Second
+	// k8sKubeconfigTimeout limits fetching the kubeconfig from each address if ctx has no deadline
+	k8sKubeconfigTimeout = time.Minute
+)
+
+// Kubeconfig fetches the kubeconfig of the cluster from its master over ssh with the private key of the
+// cluster ssh key, the master host key is verified with hostKeyCallback. only the master mycelium, planetary
+// and wireguard ips are tried, in order, and the kubeconfig server is set to the cluster APIServer gateway
+// if set or to the first reachable ip otherwise
+func (k *K8sCluster) Kubeconfig(ctx context.Context, privateKey string, hostKeyCallback ssh.HostKeyCallback) (string, error) {
+	if k.Master == nil || k.Master.VM == nil {
+		return "", errors.New("k8s cluster has no master")
+	}
+
+	if hostKeyCallback == nil {
+		return "", errors.New("a host key callback is required to verify the master host key")
+	}
+
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	if err != nil {
+		return "", errors.Wrap(err, "could not parse ssh private key")
+	}
+
+	addresses := k8sMasterAddresses(*k.Master)
+	if len(addresses) == 0 {
+		return "", errors.Errorf("master %s has no ip, the cluster should be deployed first", k.Master.Name)
+	}
+
+	var errs error
+	for _, address := range addresses {
+		config, err := fetchKubeconfig(ctx, address, signer, hostKeyCallback)
+		if err != nil {
+			errs = multierror.Append(errs, errors.Wrapf(err, "could not fetch kubeconfig from %s", address))
+			continue
+		}
+
+		if k.APIServer != "" {
+			return rewriteKubeconfig(config, k.APIServer, "443", "")
+		}
+
+		return rewriteKubeconfig(config, address, "6443", k8sAPIServerName)
+	}
+
+	return "", errs
+}
+
+// k8sMasterAddresses returns the overlay ips of the master in the order they are tried,
+// public ips are not used so the kubeconfig is never fetched over the public internet
+func k8sMasterAddresses(master K8sNode) []string {
+	var addresses []string
+	for _, ip := range []string{master.MyceliumIP, master.PlanetaryIP, master.IP} {
+		if net.ParseIP(ip) != nil {
+			addresses = append(addresses, ip)
+		}
+	}
+
+	return addresses
+}
+
+func fetchKubeconfig(ctx context.Context, address string, signer ssh.Signer, hostKeyCallback ssh.HostKeyCallback) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, k8sKubeconfigTimeout)
+		defer cancel()
+	}
+
+	addr := net.JoinHostPort(address, "22")
+	dialer := net.Dialer{Timeout: k8sSSHTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// the ssh handshake and session don't take a context so the connection is closed once ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            "root",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         k8sSSHTimeout,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not start ssh connection")
+	}
+
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create ssh session")
+	}
+	defer session.Close()
+
+	config, err := session.Output(fmt.Sprintf("cat %s", k8sKubeconfigPath))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read %s", k8sKubeconfigPath)
+	}
+
+	return config, nil
+}
+
+// rewriteKubeconfig sets the server of the kubeconfig clusters to the api server on the given address and port,
+// the api server certificate is verified with serverName if set as master ips are not in the certificate.
+// the gateway domain is used as is since the gateway routes the tls traffic by its server name
+func rewriteKubeconfig(config []byte, address, port, serverName string) (string, error) {
+	var kubeconfig map[string]interface{}
+	if err := yaml.Unmarshal(config, &kubeconfig); err != nil {
+		return "", errors.Wrap(err, "invalid kubeconfig")
+	}
+
+	clusters, _ := kubeconfig["clusters"].([]interface{})
+	if len(clusters) == 0 {
+		return "", errors.New("kubeconfig has no clusters")
+	}
+
+	for _, c := range clusters {
+		cluster, _ := c.(map[string]interface{})
+		server, ok := cluster["cluster"].(map[string]interface{})
+		if !ok {
+			return "", errors.New("invalid kubeconfig cluster")
+		}
+
+		server["server"] = fmt.Sprintf("https://%s", net.JoinHostPort(address, port))
+		delete(server, "tls-server-name")
+		if serverName != "" {
+			server["tls-server-name"] = serverName
+		}
+	}
+
+	out, err := yaml.Marshal(kubeconfig)
+	if err != nil {
+		return "", errors.Wrap(err, "could not encode kubeconfig")
+	}
+
+	return string(out), nil
+}
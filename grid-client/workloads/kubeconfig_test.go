@@ -0,0 +1,88 @@
+package workloads
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+const k3sKubeconfig = `apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: Y2VydA==
+    server: https://127.0.0.1:6443
+  name: default
+contexts:
+- context:
+    cluster: default
+    user: default
+  name: default
+current-context: default
+kind: Config
+preferences: {}
+users:
+- name: default
+  user:
+    client-certificate-data: Y2VydA==
+    client-key-data: a2V5
+`
+
+func TestKubeconfig(t *testing.T) {
+	t.Run("master addresses", func(t *testing.T) {
+		master := K8sNode{VM: &VM{
+			MyceliumIP:  "5ff:1c8a:b4d4:e3ba:ff0f:1f2d:e2d3:4a2a",
+			PlanetaryIP: "",
+			ComputedIP:  "185.206.122.31/24",
+			IP:          "10.20.2.2",
+		}}
+
+		// public ips are not used
+		assert.Equal(t, []string{"5ff:1c8a:b4d4:e3ba:ff0f:1f2d:e2d3:4a2a", "10.20.2.2"}, k8sMasterAddresses(master))
+		assert.Empty(t, k8sMasterAddresses(K8sNode{VM: &VM{}}))
+	})
+
+	t.Run("rewrite server", func(t *testing.T) {
+		config, err := rewriteKubeconfig([]byte(k3sKubeconfig), "5ff:1c8a:b4d4:e3ba:ff0f:1f2d:e2d3:4a2a", "6443", k8sAPIServerName)
+		require.NoError(t, err)
+
+		var kubeconfig struct {
+			Clusters []struct {
+				Cluster map[string]string `yaml:"cluster"`
+			} `yaml:"clusters"`
+			Users []interface{} `yaml:"users"`
+		}
+		require.NoError(t, yaml.Unmarshal([]byte(config), &kubeconfig))
+		require.Len(t, kubeconfig.Clusters, 1)
+
+		assert.Equal(t, "https://[5ff:1c8a:b4d4:e3ba:ff0f:1f2d:e2d3:4a2a]:6443", kubeconfig.Clusters[0].Cluster["server"])
+		assert.Equal(t, "kubernetes", kubeconfig.Clusters[0].Cluster["tls-server-name"])
+		assert.Equal(t, "Y2VydA==", kubeconfig.Clusters[0].Cluster["certificate-authority-data"])
+		assert.Len(t, kubeconfig.Users, 1)
+
+		config, err = rewriteKubeconfig([]byte(config), "k8sapi.gent01.dev.grid.tf", "443", "")
+		require.NoError(t, err)
+		kubeconfig.Clusters = nil
+		require.NoError(t, yaml.Unmarshal([]byte(config), &kubeconfig))
+		assert.Equal(t, "https://k8sapi.gent01.dev.grid.tf:443", kubeconfig.Clusters[0].Cluster["server"])
+		assert.NotContains(t, kubeconfig.Clusters[0].Cluster, "tls-server-name")
+
+		_, err = rewriteKubeconfig([]byte("kind: Config\n"), "10.20.2.2", "6443", k8sAPIServerName)
+		assert.Error(t, err)
+	})
+
+	t.Run("no master", func(t *testing.T) {
+		cluster := K8sCluster{}
+		_, err := cluster.Kubeconfig(context.Background(), "", ssh.InsecureIgnoreHostKey())
+		assert.Error(t, err)
+	})
+
+	t.Run("no host key callback", func(t *testing.T) {
+		cluster := K8sCluster{Master: &K8sNode{VM: &VM{IP: "10.20.2.2"}}}
+		_, err := cluster.Kubeconfig(context.Background(), "", nil)
+		assert.ErrorContains(t, err, "host key callback")
+	})
+}
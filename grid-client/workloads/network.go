@@ -518,39 +518,33 @@ func (znet *ZNet) GenerateVersionlessDeployments(
 ) (map[uint32]zos.Deployment, error) {
 	var multiErr error
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-
+	nodes := make([]uint32, 0, len(allNodes)+1)
 	for nodeID := range allNodes {
-		wg.Add(1)
-		go func(nodeID uint32) {
-			defer wg.Done()
-			endpoint, usedPorts, err := getNodeEndpointAndPorts(ctx, ncPool, subConn, nodeID)
-			mu.Lock()
-			defer mu.Unlock()
-			if err != nil {
-				multiErr = multierror.Append(multiErr, err)
-				return
-			}
-			endpoints[nodeID] = endpoint
-			nodeUsedPorts[nodeID] = usedPorts
-		}(nodeID)
+		nodes = append(nodes, nodeID)
+	}
 
+	// public node could be 0 if we got an error while getting it,
+	// then we skip getting its data.
+	if publicNode != 0 {
+		nodes = append(nodes, publicNode)
 	}
-	wg.Wait()
 
-	// here we check that the we managed to get a public node
-	// and that public node wasn't already processed. if we got
-	// an error while getting public node, then node id will be 0
-	// and we will skip getting its data.
-	if _, ok := endpoints[publicNode]; !ok && publicNode != 0 {
-		endpoint, usedPorts, err := getNodeEndpointAndPorts(ctx, ncPool, subConn, publicNode)
+	var mu sync.Mutex
+	errs := client.ForEachNode(ctx, ncPool, subConn, nodes, client.FanOutOptions{}, func(ctx context.Context, nodeID uint32, nodeClient *client.NodeClient) error {
+		endpoint, usedPorts, err := getNodeEndpointAndPorts(ctx, nodeClient, nodeID)
 		if err != nil {
-			multiErr = multierror.Append(multiErr, err)
-		} else {
-			endpoints[publicNode] = endpoint
-			nodeUsedPorts[publicNode] = usedPorts
+			return err
 		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		endpoints[nodeID] = endpoint
+		nodeUsedPorts[nodeID] = usedPorts
+		return nil
+	})
+
+	for _, err := range errs {
+		multiErr = multierror.Append(multiErr, err)
 	}
 
 	dls, err := znet.generateDeployments(endpoints, nodeUsedPorts, publicNode, twinID)
@@ -779,12 +773,7 @@ func (znet *ZNet) generateDeployments(endpointIPs map[uint32]net.IP, usedPorts m
 	return deployments, nil
 }
 
-func getNodeEndpointAndPorts(ctx context.Context, ncPool client.NodeClientGetter, subConn subi.SubstrateExt, nodeID uint32) (net.IP, []uint16, error) {
-	nodeClient, err := ncPool.GetNodeClient(subConn, nodeID)
-	if err != nil {
-		return nil, nil, fmt.Errorf("could not get node %d client: %w", nodeID, err)
-	}
-
+func getNodeEndpointAndPorts(ctx context.Context, nodeClient *client.NodeClient, nodeID uint32) (net.IP, []uint16, error) {
 	endpoint, err := nodeClient.GetNodeEndpoint(ctx)
 	if err != nil && !errors.Is(err, client.ErrNoAccessibleInterfaceFound) {
 		return nil, nil, fmt.Errorf("failed to get node %d endpoint: %w", nodeID, err)
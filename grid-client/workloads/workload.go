@@ -0,0 +1,262 @@
+// Package workloads includes workloads types (vm, zdb, QSFS, public IP, gateway name, gateway fqdn, disk)
+package workloads
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+// Workload is a grid client workload that is deployed on a node as one or more zos workloads
+type Workload interface {
+	// Validate validates the workload
+	Validate() error
+	// ZosWorkloads generates the zos workloads of the workload
+	ZosWorkloads() ([]zos.Workload, error)
+	// FromZosWorkload loads the workload from its zos workload within a node deployment
+	FromZosWorkload(wl *zos.Workload, dl *zos.Deployment, nodeID uint32) error
+	// GenerateMetadata generates the metadata of a deployment holding the workload
+	GenerateMetadata() (string, error)
+}
+
+var (
+	workloadTypesLock sync.RWMutex
+	workloadTypes     = map[string]func() Workload{}
+)
+
+func init() {
+	RegisterWorkload(zos.ZMountType, func() Workload { return &Disk{} })
+	RegisterWorkload(zos.VolumeType, func() Workload { return &Volume{} })
+	RegisterWorkload(zos.ZDBType, func() Workload { return &ZDB{} })
+	RegisterWorkload(zos.QuantumSafeFSType, func() Workload { return &QSFS{} })
+	RegisterWorkload(zos.ZMachineType, func() Workload { return &VM{} })
+	RegisterWorkload(zos.ZMachineLightType, func() Workload { return &VMLight{} })
+	RegisterWorkload(zos.NetworkType, func() Workload { return &ZNet{} })
+	RegisterWorkload(zos.NetworkLightType, func() Workload { return &ZNetLight{} })
+}
+
+// RegisterWorkload registers the constructor of the workload loaded from zos workloads of the given type,
+// zos workloads that are part of another workload (e.g. public ips of vms) are not registered
+func RegisterWorkload(zosType string, newWorkload func() Workload) {
+	workloadTypesLock.Lock()
+	defer workloadTypesLock.Unlock()
+
+	workloadTypes[zosType] = newWorkload
+}
+
+// NewWorkloadFromZosWorkload loads a workload from a zos workload using the registered workload types,
+// it returns false if the zos workload type is not registered
+func NewWorkloadFromZosWorkload(wl *zos.Workload, dl *zos.Deployment, nodeID uint32) (Workload, bool, error) {
+	workloadTypesLock.RLock()
+	newWorkload, ok := workloadTypes[wl.Type]
+	workloadTypesLock.RUnlock()
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	workload := newWorkload()
+	if err := workload.FromZosWorkload(wl, dl, nodeID); err != nil {
+		return nil, true, errors.Wrapf(err, "failed to get %s workload %s", wl.Type, wl.Name)
+	}
+
+	return workload, true, nil
+}
+
+// generateWorkloadMetadata generates the metadata of a deployment holding a single workload
+func generateWorkloadMetadata(name, typ string) (string, error) {
+	deploymentData := DeploymentData{
+		Version:     int(Version3),
+		Name:        name,
+		Type:        typ,
+		ProjectName: fmt.Sprintf("%s/%s", typ, name),
+	}
+
+	deploymentDataBytes, err := json.Marshal(deploymentData)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse deployment data %v", deploymentData)
+	}
+
+	return string(deploymentDataBytes), nil
+}
+
+// ZosWorkloads generates the zos workloads of a disk
+func (d *Disk) ZosWorkloads() ([]zos.Workload, error) {
+	return []zos.Workload{d.ZosWorkload()}, nil
+}
+
+// FromZosWorkload loads a disk from its zos workload
+func (d *Disk) FromZosWorkload(wl *zos.Workload, _ *zos.Deployment, _ uint32) (err error) {
+	*d, err = NewDiskFromWorkload(wl)
+	return err
+}
+
+// GenerateMetadata generates the metadata of a deployment holding the disk
+func (d *Disk) GenerateMetadata() (string, error) {
+	return generateWorkloadMetadata(d.Name, "vm")
+}
+
+// ZosWorkloads generates the zos workloads of a volume
+func (v *Volume) ZosWorkloads() ([]zos.Workload, error) {
+	return []zos.Workload{v.ZosWorkload()}, nil
+}
+
+// FromZosWorkload loads a volume from its zos workload
+func (v *Volume) FromZosWorkload(wl *zos.Workload, _ *zos.Deployment, _ uint32) (err error) {
+	*v, err = NewVolumeFromWorkload(wl)
+	return err
+}
+
+// GenerateMetadata generates the metadata of a deployment holding the volume
+func (v *Volume) GenerateMetadata() (string, error) {
+	return generateWorkloadMetadata(v.Name, "vm")
+}
+
+// ZosWorkloads generates the zos workloads of a zdb
+func (z *ZDB) ZosWorkloads() ([]zos.Workload, error) {
+	return []zos.Workload{z.ZosWorkload()}, nil
+}
+
+// FromZosWorkload loads a zdb from its zos workload
+func (z *ZDB) FromZosWorkload(wl *zos.Workload, _ *zos.Deployment, _ uint32) (err error) {
+	*z, err = NewZDBFromWorkload(wl)
+	return err
+}
+
+// GenerateMetadata generates the metadata of a deployment holding the zdb
+func (z *ZDB) GenerateMetadata() (string, error) {
+	return generateWorkloadMetadata(z.Name, "vm")
+}
+
+// ZosWorkloads generates the zos workloads of a qsfs
+func (q *QSFS) ZosWorkloads() ([]zos.Workload, error) {
+	wl, err := q.ZosWorkload()
+	if err != nil {
+		return nil, err
+	}
+
+	return []zos.Workload{wl}, nil
+}
+
+// FromZosWorkload loads a qsfs from its zos workload
+func (q *QSFS) FromZosWorkload(wl *zos.Workload, _ *zos.Deployment, _ uint32) (err error) {
+	*q, err = NewQSFSFromWorkload(wl)
+	return err
+}
+
+// GenerateMetadata generates the metadata of a deployment holding the qsfs
+func (q *QSFS) GenerateMetadata() (string, error) {
+	return generateWorkloadMetadata(q.Name, "vm")
+}
+
+// ZosWorkloads generates the zos workloads of a vm and its public ips
+func (vm *VM) ZosWorkloads() ([]zos.Workload, error) {
+	return vm.ZosWorkload(), nil
+}
+
+// FromZosWorkload loads a vm from its zos workload and the public ips in its deployment
+func (vm *VM) FromZosWorkload(wl *zos.Workload, dl *zos.Deployment, nodeID uint32) (err error) {
+	*vm, err = NewVMFromWorkload(wl, dl, nodeID)
+	return err
+}
+
+// GenerateMetadata generates the metadata of a deployment holding the vm
+func (vm *VM) GenerateMetadata() (string, error) {
+	return generateWorkloadMetadata(vm.Name, "vm")
+}
+
+// ZosWorkloads generates the zos workloads of a vm-light
+func (vm *VMLight) ZosWorkloads() ([]zos.Workload, error) {
+	return vm.ZosWorkload(), nil
+}
+
+// FromZosWorkload loads a vm-light from its zos workload
+func (vm *VMLight) FromZosWorkload(wl *zos.Workload, dl *zos.Deployment, nodeID uint32) (err error) {
+	*vm, err = NewVMLightFromWorkload(wl, dl, nodeID)
+	return err
+}
+
+// GenerateMetadata generates the metadata of a deployment holding the vm-light
+func (vm *VMLight) GenerateMetadata() (string, error) {
+	return generateWorkloadMetadata(vm.Name, "vm-light")
+}
+
+// ZosWorkloads generates the zos workload of a network deployed within a node deployment,
+// the network must be on a single node with its ip range, wireguard key and port assigned
+func (znet *ZNet) ZosWorkloads() ([]zos.Workload, error) {
+	if len(znet.Nodes) != 1 {
+		return nil, errors.Errorf("network %s within a deployment must have exactly one node", znet.Name)
+	}
+
+	if znet.AddWGAccess {
+		return nil, errors.Errorf("network %s within a deployment cannot have wireguard access", znet.Name)
+	}
+
+	node := znet.Nodes[0]
+	subnet, ok := znet.NodesIPRange[node]
+	if !ok {
+		return nil, errors.Errorf("network %s has no ip range assigned for node %d", znet.Name, node)
+	}
+
+	key, ok := znet.Keys[node]
+	if !ok {
+		return nil, errors.Errorf("network %s has no wireguard key assigned for node %d", znet.Name, node)
+	}
+
+	metadata, err := json.Marshal(NetworkMetaData{Version: int(Version3)})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse network metadata")
+	}
+
+	return []zos.Workload{
+		znet.ZosWorkload(subnet, key.String(), uint16(znet.WGPort[node]), nil, string(metadata), znet.MyceliumKeys[node]),
+	}, nil
+}
+
+// FromZosWorkload loads a network from its zos workload on a node
+func (znet *ZNet) FromZosWorkload(wl *zos.Workload, dl *zos.Deployment, nodeID uint32) (err error) {
+	*znet, err = NewNetworkFromWorkload(*wl, nodeID)
+	if err != nil {
+		return err
+	}
+
+	znet.NodeDeploymentID = map[uint32]uint64{nodeID: dl.ContractID}
+	return nil
+}
+
+// ZosWorkloads generates the zos workload of a network light deployed within a node deployment,
+// the network must be on a single node with its ip range assigned
+func (znet *ZNetLight) ZosWorkloads() ([]zos.Workload, error) {
+	if len(znet.Nodes) != 1 {
+		return nil, errors.Errorf("network %s within a deployment must have exactly one node", znet.Name)
+	}
+
+	node := znet.Nodes[0]
+	subnet, ok := znet.NodesIPRange[node]
+	if !ok {
+		return nil, errors.Errorf("network %s has no ip range assigned for node %d", znet.Name, node)
+	}
+
+	metadata, err := json.Marshal(NetworkMetaData{Version: int(Version4)})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse network metadata")
+	}
+
+	return []zos.Workload{
+		znet.ZosWorkload(subnet, "", 0, nil, string(metadata), znet.MyceliumKeys[node]),
+	}, nil
+}
+
+// FromZosWorkload loads a network light from its zos workload on a node
+func (znet *ZNetLight) FromZosWorkload(wl *zos.Workload, dl *zos.Deployment, nodeID uint32) (err error) {
+	*znet, err = NewNetworkLightFromWorkload(*wl, nodeID)
+	if err != nil {
+		return err
+	}
+
+	znet.NodeDeploymentID = map[uint32]uint64{nodeID: dl.ContractID}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package workloads includes workloads types (vm, zdb, QSFS, public IP, gateway name, gateway fqdn, disk)
+package workloads
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
+)
+
+// testWorkload is a workload type registered by tests
+type testWorkload struct {
+	Name  string
+	Value string
+}
+
+const testWorkloadType = "test-workload"
+
+func (w *testWorkload) Validate() error {
+	return validateName(w.Name)
+}
+
+func (w *testWorkload) ZosWorkloads() ([]zos.Workload, error) {
+	return []zos.Workload{{
+		Name: w.Name,
+		Type: testWorkloadType,
+		Data: zos.MustMarshal(w.Value),
+	}}, nil
+}
+
+func (w *testWorkload) GenerateMetadata() (string, error) {
+	return generateWorkloadMetadata(w.Name, "test")
+}
+
+func (w *testWorkload) FromZosWorkload(wl *zos.Workload, _ *zos.Deployment, _ uint32) error {
+	w.Name = wl.Name
+	return json.Unmarshal(wl.Data, &w.Value)
+}
+
+func TestWorkloadRegistry(t *testing.T) {
+	RegisterWorkload(testWorkloadType, func() Workload { return &testWorkload{} })
+
+	t.Run("test_registered_types", func(t *testing.T) {
+		for _, workload := range []Workload{&DiskWorkload, &volumeWorkload, &testWorkload{Name: "test", Value: "value"}} {
+			zosWorkloads, err := workload.ZosWorkloads()
+			require.NoError(t, err)
+			require.Len(t, zosWorkloads, 1)
+
+			loaded, ok, err := NewWorkloadFromZosWorkload(&zosWorkloads[0], &zos.Deployment{}, 1)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, workload, loaded)
+		}
+	})
+
+	t.Run("test_unregistered_type", func(t *testing.T) {
+		wl := zos.Workload{Name: "ip", Type: zos.PublicIPType}
+
+		_, ok, err := NewWorkloadFromZosWorkload(&wl, &zos.Deployment{}, 1)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("test_deployment_with_network", func(t *testing.T) {
+		network := &ZNetLight{
+			Name:         "network",
+			Nodes:        []uint32{1},
+			IPRange:      IPNet(10, 20, 0, 0, 16),
+			NodesIPRange: map[uint32]zos.IPNet{1: IPNet(10, 20, 2, 0, 24)},
+			MyceliumKeys: map[uint32][]byte{},
+		}
+
+		dl := NewDeploymentWithWorkloads("test", 1, "", nil, "network",
+			&DiskWorkload,
+			network,
+			&testWorkload{Name: "custom", Value: "value"},
+		)
+		assert.Equal(t, []Disk{DiskWorkload}, dl.Disks)
+		assert.Len(t, dl.Workloads, 2)
+		assert.Equal(t, []Workload{&dl.Disks[0], network, &testWorkload{Name: "custom", Value: "value"}}, dl.AllWorkloads())
+
+		embedded, ok := dl.Network()
+		require.True(t, ok)
+		assert.Equal(t, network, embedded)
+
+		zosDl, err := dl.ZosDeployment(1)
+		require.NoError(t, err)
+		require.Len(t, zosDl.Workloads, 3)
+
+		zosDl.ContractID = 10
+		zosDl.Metadata, err = dl.GenerateMetadata()
+		require.NoError(t, err)
+
+		loaded, err := NewDeploymentFromZosDeployment(zosDl, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []Disk{DiskWorkload}, loaded.Disks)
+		require.Len(t, loaded.Workloads, 2)
+
+		loadedNetwork, ok := loaded.Workloads[0].(*ZNetLight)
+		require.True(t, ok)
+		assert.Equal(t, network.NodesIPRange, loadedNetwork.NodesIPRange)
+		assert.Equal(t, map[uint32]uint64{1: 10}, loadedNetwork.NodeDeploymentID)
+		assert.Equal(t, &testWorkload{Name: "custom", Value: "value"}, loaded.Workloads[1])
+	})
+
+	t.Run("test_deployment_metadata", func(t *testing.T) {
+		for _, tc := range []struct {
+			workloads []Workload
+			typ       string
+		}{
+			{workloads: nil, typ: "vm"},
+			{workloads: []Workload{&testWorkload{Name: "custom"}}, typ: "test"},
+			{workloads: []Workload{&testWorkload{Name: "custom"}, &DiskWorkload}, typ: "vm"},
+			{workloads: []Workload{&DiskWorkload, &VMLight{Name: "vm"}}, typ: "vm-light"},
+		} {
+			dl := NewDeploymentWithWorkloads("test", 1, "", nil, "", tc.workloads...)
+			metadata, err := dl.GenerateMetadata()
+			require.NoError(t, err)
+
+			data, err := ParseDeploymentData(metadata)
+			require.NoError(t, err)
+			assert.Equal(t, tc.typ, data.Type)
+			assert.Equal(t, "vm/test", data.ProjectName)
+		}
+	})
+
+	t.Run("test_zos_workloads_order", func(t *testing.T) {
+		dl := NewDeployment("test", 1, "", nil, "", []Disk{DiskWorkload}, nil, []VM{{Name: "vm", Flist: "flist"}}, nil, nil, []Volume{volumeWorkload})
+
+		zosWorkloads, err := dl.ZosWorkloads()
+		require.NoError(t, err)
+
+		var types []string
+		for _, wl := range zosWorkloads {
+			types = append(types, wl.Type)
+		}
+		// the volume stays next to the disk so the hash of deployed deployments does not change
+		assert.Equal(t, []string{zos.ZMountType, zos.VolumeType, zos.ZMachineType}, types)
+	})
+
+	t.Run("test_network_within_deployment_failures", func(t *testing.T) {
+		network := &ZNetLight{Name: "network", Nodes: []uint32{1, 2}}
+		_, err := network.ZosWorkloads()
+		assert.Error(t, err)
+
+		network.Nodes = []uint32{1}
+		_, err = network.ZosWorkloads()
+		assert.Error(t, err)
+	})
+}
@@ -4,12 +4,17 @@ package workloads
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"net/url"
+	"slices"
 
 	"github.com/pkg/errors"
 	zosTypes "github.com/threefoldtech/tfgrid-sdk-go/grid-client/zos"
 	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
 )
 
+// zlogOutputSchemes are the output schemes supported by zos
+var zlogOutputSchemes = []string{"redis", "ws", "wss"}
+
 // Zlog logger struct
 type Zlog struct {
 	Zmachine string `json:"zmachine"`
@@ -71,5 +76,14 @@ func (z *Zlog) Validate() error {
 		return errors.Wrap(err, "zmachine name is invalid")
 	}
 
+	output, err := url.Parse(z.Output)
+	if err != nil {
+		return errors.Wrap(err, "output is invalid")
+	}
+
+	if !slices.Contains(zlogOutputSchemes, output.Scheme) {
+		return errors.Errorf("output scheme %q is not supported, supported schemes are %v", output.Scheme, zlogOutputSchemes)
+	}
+
 	return nil
 }
@@ -11,7 +11,7 @@ import (
 // ZlogWorkload for tests
 var ZlogWorkload = Zlog{
 	Zmachine: "test",
-	Output:   "output",
+	Output:   "redis://codescalers1.com",
 }
 
 func TestZLog(t *testing.T) {
@@ -24,4 +24,15 @@ func TestZLog(t *testing.T) {
 		zlogs := zlogs(&deployment, ZlogWorkload.Zmachine)
 		assert.Equal(t, zlogs, []Zlog{ZlogWorkload})
 	})
+
+	t.Run("test_zLog_validate", func(t *testing.T) {
+		assert.NoError(t, ZlogWorkload.Validate())
+
+		zlog := ZlogWorkload
+		zlog.Output = "ws://[300:1::1]:8090/logs"
+		assert.NoError(t, zlog.Validate())
+
+		zlog.Output = "http://codescalers1.com"
+		assert.Error(t, zlog.Validate())
+	})
 }
@@ -3,6 +3,7 @@ package tfplugin
 
 import (
 	"context"
+	"slices"
 
 	gridDeployer "github.com/threefoldtech/tfgrid-sdk-go/grid-client/deployer"
 	"github.com/threefoldtech/tfgrid-sdk-go/grid-client/graphql"
@@ -91,5 +92,7 @@ func (t *TFPluginClient) GetGridNetwork() string {
 
 // SetState set the state of tf plugin client
 func (t *TFPluginClient) SetState(nodeID uint32, contractIDs []uint64) {
-	t.tfPluginClient.State.CurrentNodeDeployments[nodeID] = contractIDs
+	st := t.tfPluginClient.State
+	st.RemoveContractIDs(nodeID, slices.Clone(st.CurrentNodeDeployments[nodeID])...)
+	st.StoreContractIDs(nodeID, contractIDs...)
 }